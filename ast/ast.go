@@ -3,6 +3,8 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"strconv"
+	"strings"
 )
 
 // Program
@@ -18,14 +20,17 @@ import (
 // │
 // └── Statement 3 (AssignStatement) <------ Node#3
 //	   ├── Token: ASSIGN
-//	   ├── Name: Identifier ("z")		<-- *identifier
+//	   ├── Target: Identifier ("z")		<-- *identifier (or index, like z[0])
 //	   └── expression: Add					<-- expression (composite)
 //		   ├── Left: Identifier ("x")
 //		   └── Right: Identifier ("y")
 
+// Pos and End are computed from the tokens a node is made of, a statement ends before its optional semicolon
 type Node interface {
 	TokenLiteral() string
 	ToString() string
+	Pos() token.Position // where the node starts in the source
+	End() token.Position // right after where it ends, the source of a node is source[Pos().Offset:End().Offset]
 }
 
 // Program = Sequence of Statements, This will be the top-level
@@ -33,7 +38,7 @@ type Node interface {
 //	A program is a series of statements.
 type Program struct {
 	Statements []Statement
-	Errors     []error
+	Errors     []error `json:"-"`
 }
 
 // Statement = representation of each Node
@@ -61,6 +66,14 @@ type Expression interface {
 type Identifier struct {
 	Token token.Token
 	Value string
+
+	// filled in by the resolver: the binding is Depth scopes up from where the identifier is, at index Slot there
+	// without Resolved the name has to be looked up by walking the scopes
+	// Slot is only an annotation for now, environments are keyed by name and the evaluator goes Depth scopes up
+	// and looks the name up there
+	Resolved bool `json:"-"`
+	Depth    int  `json:"-"`
+	Slot     int  `json:"-"`
 }
 
 func (id *Identifier) expressionNode() {}
@@ -70,22 +83,42 @@ func (id *Identifier) TokenLiteral() string {
 func (id *Identifier) ToString() string {
 	return id.Value
 }
+func (id *Identifier) Pos() token.Position { return id.Token.Pos }
+func (id *Identifier) End() token.Position { return id.Token.End }
 
 // LetStatement Structural representation of let statement
 // Since statement=node, it must implement node methods
+// const x = 5 is a LetStatement too, only the token differs
 type LetStatement struct {
-	Token token.Token // LET
-	Name  *Identifier // x
-	Value Expression  // 5
+	Token token.Token     // LET or CONST
+	Name  *Identifier     // x
+	Type  *TypeExpression // the int of let x: int = 5, nil without an annotation
+	Value Expression      // 5
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	switch {
+	case ls.Value != nil:
+		return ls.Value.End()
+	case ls.Type != nil:
+		return ls.Type.End()
+	}
+	return ls.Name.End()
+}
+
+// IsConstant whether the binding was declared with const and cannot be reassigned
+func (ls *LetStatement) IsConstant() bool { return ls.Token.Type == token.CONST }
 func (ls *LetStatement) ToString() string {
 	//fmt.Println(ls, ls.Name, ls.Value)
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.ToString())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.ToString())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.ToString())
@@ -103,6 +136,13 @@ type SendStatement struct {
 
 func (ss *SendStatement) statementNode()       {}
 func (ss *SendStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *SendStatement) Pos() token.Position  { return ss.Token.Pos }
+func (ss *SendStatement) End() token.Position {
+	if ss.Value == nil {
+		return ss.Token.End
+	}
+	return ss.Value.End()
+}
 func (ss *SendStatement) ToString() string {
 	var out bytes.Buffer
 	out.WriteString(ss.TokenLiteral() + " ")
@@ -113,6 +153,17 @@ func (ss *SendStatement) ToString() string {
 	return out.String()
 }
 
+// BadStatement placeholder for a statement that failed to parse, the matching entry in Program.Errors says why
+type BadStatement struct {
+	Token token.Token // first token of the statement
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BadStatement) End() token.Position  { return bs.Token.End }
+func (bs *BadStatement) ToString() string     { return "<bad statement>" }
+
 // ExpressionStatement these are the statements without any LEFT, entire statement is an expression
 // ExpressionStatement is like a wrapper, which contains only single expression
 type ExpressionStatement struct {
@@ -127,6 +178,13 @@ func (es *ExpressionStatement) expressionNode() {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression == nil {
+		return es.Token.End
+	}
+	return es.Expression.End()
+}
 func (es *ExpressionStatement) ToString() string {
 	var out bytes.Buffer
 	out.WriteString(es.TokenLiteral() + " ")
@@ -145,15 +203,31 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 func (il *IntegerLiteral) ToString() string     { return il.Token.Literal }
 
-// func (p *Program) TokenLiteral() string {
-// 	if len(p.Statements) > 0 {
-// 		return p.Statements[0].TokenLiteral()
-// 	} else {
-// 		return ""
-// 	}
-// }
+func (p *Program) TokenLiteral() string {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].TokenLiteral()
+	} else {
+		return ""
+	}
+}
+
+// Pos start of the first statement, the zero Position for an empty program
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[0].Pos()
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) == 0 {
+		return token.Position{}
+	}
+	return p.Statements[len(p.Statements)-1].End()
+}
 
 func (p *Program) ToString() string {
 	var out bytes.Buffer
@@ -173,6 +247,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Right.End() }
 func (pe *PrefixExpression) ToString() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -181,3 +257,419 @@ func (pe *PrefixExpression) ToString() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+// InfixExpression operator sitting between two expressions, like 5 + 5 or x ** 2
+type InfixExpression struct {
+	Token    token.Token // the operator token, like +
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (ie *InfixExpression) expressionNode()      {}
+func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position  { return ie.Right.End() }
+func (ie *InfixExpression) ToString() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.ToString())
+	out.WriteString(" " + ie.Operator + " ")
+	out.WriteString(ie.Right.ToString())
+	out.WriteString(")")
+	return out.String()
+}
+
+// AssignStatement Structural representation of reassignment, like z = x + y, z += 1 or arr[0] = 5
+// unlike let it does not introduce a binding, the target has to exist already
+type AssignStatement struct {
+	Token    token.Token // ASSIGN, or a compound form like PLUS_ASSIGN
+	Target   Expression  // *Identifier or *IndexExpression
+	Operator string      // =, +=, -=, *=, /=
+	Value    Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) Pos() token.Position  { return as.Target.Pos() }
+func (as *AssignStatement) End() token.Position {
+	if as.Value == nil {
+		return as.Token.End
+	}
+	return as.Value.End()
+}
+func (as *AssignStatement) ToString() string {
+	var out bytes.Buffer
+	out.WriteString(as.Target.ToString())
+	out.WriteString(" " + as.Operator + " ")
+	if as.Value != nil {
+		out.WriteString(as.Value.ToString())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// ArrayLiteral like [1, 2 * 2, x]
+type ArrayLiteral struct {
+	Token    token.Token // [
+	Elements []Expression
+	Rbracket token.Position // the closing ]
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return after(al.Rbracket) }
+func (al *ArrayLiteral) ToString() string {
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.ToString())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashLiteral like {1: 2, x: y}
+// keys and values are kept as two lists instead of a map, so the source order survives
+type HashLiteral struct {
+	Token  token.Token // {
+	Keys   []Expression
+	Values []Expression   // Values[i] belongs to Keys[i]
+	Rbrace token.Position // the closing }
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return after(hl.Rbrace) }
+func (hl *HashLiteral) ToString() string {
+	pairs := []string{}
+	for i, key := range hl.Keys {
+		pairs = append(pairs, key.ToString()+": "+hl.Values[i].ToString())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// IndexExpression like arr[0] or hash[key], Left is anything that evaluates to a collection
+type IndexExpression struct {
+	Token    token.Token // [
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position // the closing ]
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return after(ie.Rbracket) }
+func (ie *IndexExpression) ToString() string {
+	return "(" + ie.Left.ToString() + "[" + ie.Index.ToString() + "])"
+}
+
+// Boolean literal yes/no
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (b *Boolean) expressionNode()      {}
+func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
+func (b *Boolean) ToString() string     { return b.Token.Literal }
+
+// StringLiteral like "hello", Value is the unescaped content
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) ToString() string     { return strconv.Quote(sl.Value) }
+
+// BlockStatement statements between { and }, body of when/otherwise, functions and loops
+type BlockStatement struct {
+	Token      token.Token // {
+	Statements []Statement
+	Rbrace     token.Position // the closing }
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return after(bs.Rbrace) }
+func (bs *BlockStatement) ToString() string {
+	var out bytes.Buffer
+	out.WriteString("{ ")
+	for _, s := range bs.Statements {
+		out.WriteString(s.ToString())
+	}
+	out.WriteString(" }")
+	return out.String()
+}
+
+// WhenExpression when (condition) { consequence } otherwise { alternative }
+// it is an expression, the value is whatever the chosen block ends with
+type WhenExpression struct {
+	Token       token.Token // WHEN
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement // nil without otherwise
+}
+
+func (we *WhenExpression) expressionNode()      {}
+func (we *WhenExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WhenExpression) Pos() token.Position  { return we.Token.Pos }
+func (we *WhenExpression) End() token.Position {
+	if we.Alternative != nil {
+		return we.Alternative.End()
+	}
+	return we.Consequence.End()
+}
+func (we *WhenExpression) ToString() string {
+	var out bytes.Buffer
+	out.WriteString("when " + we.Condition.ToString() + " ")
+	out.WriteString(we.Consequence.ToString())
+	if we.Alternative != nil {
+		out.WriteString(" otherwise ")
+		out.WriteString(we.Alternative.ToString())
+	}
+	return out.String()
+}
+
+// FunctionLiteral fn(x, y) { body }
+type FunctionLiteral struct {
+	Token      token.Token // FUNCTION
+	Name       string      // the name it is bound to by let, empty when anonymous
+	Parameters []*Identifier
+	ParamTypes []*TypeExpression // one per parameter, nil entries for the ones without an annotation
+	ReturnType *TypeExpression   // the int of fn() -> int, nil without an annotation
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
+func (fl *FunctionLiteral) ToString() string {
+	params := []string{}
+	for i, p := range fl.Parameters {
+		if i < len(fl.ParamTypes) && fl.ParamTypes[i] != nil {
+			params = append(params, p.ToString()+": "+fl.ParamTypes[i].ToString())
+		} else {
+			params = append(params, p.ToString())
+		}
+	}
+	result := ""
+	if fl.ReturnType != nil {
+		result = "-> " + fl.ReturnType.ToString() + " "
+	}
+	return fl.TokenLiteral() + "(" + strings.Join(params, ", ") + ") " + result + fl.Body.ToString()
+}
+
+// CallExpression add(1, 2), Function is an identifier or a function literal
+type CallExpression struct {
+	Token     token.Token // (
+	Function  Expression
+	Arguments []Expression
+	Rparen    token.Position // the closing )
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position  { return after(ce.Rparen) }
+func (ce *CallExpression) ToString() string {
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, a.ToString())
+	}
+	return ce.Function.ToString() + "(" + strings.Join(args, ", ") + ")"
+}
+
+// WhileStatement while (condition) { body }
+type WhileStatement struct {
+	Token     token.Token // WHILE
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return ws.Body.End() }
+func (ws *WhileStatement) ToString() string {
+	return "while " + ws.Condition.ToString() + " " + ws.Body.ToString()
+}
+
+// ForStatement for (x in collection) { body }, collection is an array, a string or a hash
+type ForStatement struct {
+	Token    token.Token // FOR
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position  { return fs.Body.End() }
+func (fs *ForStatement) ToString() string {
+	return "for (" + fs.Variable.ToString() + " in " + fs.Iterable.ToString() + ") " + fs.Body.ToString()
+}
+
+// BreakStatement leaves the innermost loop
+type BreakStatement struct {
+	Token token.Token // BREAK
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) ToString() string     { return bs.Token.Literal + ";" }
+
+// ContinueStatement skips to the next iteration of the innermost loop
+type ContinueStatement struct {
+	Token token.Token // CONTINUE
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) ToString() string     { return cs.Token.Literal + ";" }
+
+// ThrowStatement throw "reason", fails the same way a runtime error does until a try catches it
+type ThrowStatement struct {
+	Token token.Token // THROW
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) End() token.Position {
+	if ts.Value == nil {
+		return ts.Token.End
+	}
+	return ts.Value.End()
+}
+func (ts *ThrowStatement) ToString() string {
+	return ts.TokenLiteral() + " " + ts.Value.ToString() + ";"
+}
+
+// TryExpression try { block } catch (e) { handler }
+// it is an expression, the value is whatever the block ends with, or the handler when the block failed
+type TryExpression struct {
+	Token   token.Token // TRY
+	Block   *BlockStatement
+	Param   *Identifier // the caught error is bound to it, nil for a plain catch { ... }
+	Handler *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TryExpression) End() token.Position  { return te.Handler.End() }
+func (te *TryExpression) ToString() string {
+	var out bytes.Buffer
+	out.WriteString("try " + te.Block.ToString() + " catch ")
+	if te.Param != nil {
+		out.WriteString("(" + te.Param.ToString() + ") ")
+	}
+	out.WriteString(te.Handler.ToString())
+	return out.String()
+}
+
+// TypeExpression a written type annotation: a name like int or str, [elem], {key: value} or fn(params) -> result
+// annotations are only read by the checker, the evaluator ignores them
+type TypeExpression struct {
+	Token  token.Token       // the name, [, { or fn
+	Name   string            // int, bool, str, null, any, ..., or array, hash and fn for the composite ones
+	Params []*TypeExpression // the element of an array, key and value of a hash, parameters of a fn
+	Result *TypeExpression   // fn only, nil when the result is not written
+	Close  token.Position    // the ], } or ) closing a composite type
+}
+
+func (te *TypeExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TypeExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TypeExpression) End() token.Position {
+	switch {
+	case te.Result != nil:
+		return te.Result.End()
+	case te.Token.Type == token.LBRACKET, te.Token.Type == token.LBRACE, te.Token.Type == token.FUNCTION:
+		return after(te.Close)
+	}
+	return te.Token.End
+}
+func (te *TypeExpression) ToString() string {
+	switch te.Token.Type {
+	case token.LBRACKET:
+		return "[" + te.Params[0].ToString() + "]"
+	case token.LBRACE:
+		return "{" + te.Params[0].ToString() + ": " + te.Params[1].ToString() + "}"
+	case token.FUNCTION:
+		params := make([]string, len(te.Params))
+		for i, p := range te.Params {
+			params[i] = p.ToString()
+		}
+		out := "fn(" + strings.Join(params, ", ") + ")"
+		if te.Result != nil {
+			out += " -> " + te.Result.ToString()
+		}
+		return out
+	}
+	return te.Name
+}
+
+// GroupedExpression an expression in parentheses, like the (1 + 2) of (1 + 2) * 3
+// it only records where the parentheses are, everything else treats it as the expression inside
+type GroupedExpression struct {
+	Token      token.Token // (
+	Expression Expression
+	Rparen     token.Position // the closing )
+}
+
+func (ge *GroupedExpression) expressionNode()      {}
+func (ge *GroupedExpression) TokenLiteral() string { return ge.Token.Literal }
+func (ge *GroupedExpression) Pos() token.Position  { return ge.Token.Pos }
+func (ge *GroupedExpression) End() token.Position  { return after(ge.Rparen) }
+func (ge *GroupedExpression) ToString() string     { return ge.Expression.ToString() }
+
+// Unparen the expression inside any number of parentheses, exp itself when it is not grouped
+func Unparen(exp Expression) Expression {
+	for {
+		grouped, ok := exp.(*GroupedExpression)
+		if !ok {
+			return exp
+		}
+		exp = grouped.Expression
+	}
+}
+
+// OperatorPos where errors about node are reported: where it starts, except that infix, index and call expressions
+// and assignments report their operator, the + of a + b, the [ of a[i], the ( of f(x) and the = of x = 1,
+// which is where evaluating them goes wrong
+func OperatorPos(node Node) token.Position {
+	switch node := node.(type) {
+	case *InfixExpression:
+		return node.Token.Pos
+	case *IndexExpression:
+		return node.Token.Pos
+	case *CallExpression:
+		return node.Token.Pos
+	case *AssignStatement:
+		return node.Token.Pos
+	}
+	return node.Pos()
+}
+
+// right after the one character delimiter at pos
+func after(pos token.Position) token.Position {
+	pos.Offset++
+	pos.Column++
+	return pos
+}
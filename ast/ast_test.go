@@ -0,0 +1,114 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"monkey/token"
+	"strings"
+	"testing"
+)
+
+// the source of every node under the program, in the order Inspect reaches them
+func sources(input string, program *ast.Program) []string {
+	var out []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			out = append(out, input[n.Pos().Offset:n.End().Offset])
+		}
+		return true
+	})
+	return out
+}
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x: [int] = -(1 + 2) * 3;", []string{
+			"let x: [int] = -(1 + 2) * 3", "let x: [int] = -(1 + 2) * 3", "x", "[int]", "int",
+			"-(1 + 2) * 3", "-(1 + 2)", "(1 + 2)", "1 + 2", "1", "2", "3",
+		}},
+		{`a[0] += f("x\"y", {1: 2})`, []string{
+			`a[0] += f("x\"y", {1: 2})`, `a[0] += f("x\"y", {1: 2})`, "a[0]", "a", "0",
+			`f("x\"y", {1: 2})`, "f", `"x\"y"`, "{1: 2}", "1", "2",
+		}},
+		{"fn(a: int) -> fn(int) { send a; }", []string{
+			"fn(a: int) -> fn(int) { send a; }", "fn(a: int) -> fn(int) { send a; }", "fn(a: int) -> fn(int) { send a; }",
+			"a", "int", "fn(int)", "int", "{ send a; }", "send a", "a",
+		}},
+		{"try { throw [] } catch (e) { e }", []string{
+			"try { throw [] } catch (e) { e }", "try { throw [] } catch (e) { e }", "try { throw [] } catch (e) { e }",
+			"{ throw [] }", "throw []", "[]", "e", "{ e }", "e", "e",
+		}},
+		{"while (x) { break }", []string{"while (x) { break }", "while (x) { break }", "x", "{ break }", "break"}},
+	}
+
+	for _, tt := range tests {
+		have := sources(tt.input, parse(t, tt.input))
+		if strings.Join(have, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("input %q:\nwant %q\nhave %q", tt.input, tt.expected, have)
+		}
+	}
+}
+
+func TestNodeLines(t *testing.T) {
+	input := "let s = \"a\nb\";\nwhen (s) {\n  1\n} otherwise {\n  2\n}"
+	program := parse(t, input)
+
+	tests := []struct {
+		node       ast.Node
+		start, end token.Position
+	}{
+		{program.Statements[0].(*ast.LetStatement).Value, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 13, Line: 2, Column: 3}},
+		{program.Statements[1], token.Position{Offset: 15, Line: 3, Column: 1}, token.Position{Offset: 49, Line: 7, Column: 2}},
+		{program, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 49, Line: 7, Column: 2}},
+	}
+	for i, tt := range tests {
+		if tt.node.Pos() != tt.start || tt.node.End() != tt.end {
+			t.Errorf("tests[%d]: want %#v-%#v, have %#v-%#v", i, tt.start, tt.end, tt.node.Pos(), tt.node.End())
+		}
+	}
+
+	if empty := (&ast.Program{}); empty.Pos() != (token.Position{}) || empty.End() != (token.Position{}) {
+		t.Errorf("empty program: want zero positions, have %v-%v", empty.Pos(), empty.End())
+	}
+}
+
+// parentheses are kept so spans are exact, but they change nothing about what the program means
+func TestGroupedExpression(t *testing.T) {
+	program := parse(t, "let f = (fn() { 1 }); (x) = ((2));")
+	if name := ast.Unparen(program.Statements[0].(*ast.LetStatement).Value).(*ast.FunctionLiteral).Name; name != "f" {
+		t.Errorf("parenthesized function literal: want name f, have %q", name)
+	}
+	assign := program.Statements[1].(*ast.AssignStatement)
+	if _, ok := assign.Target.(*ast.Identifier); !ok {
+		t.Errorf("assign target: want *ast.Identifier, have %T", assign.Target)
+	}
+	if have := program.ToString(); have != "let f = fn() { 1 1; };x = 2;" {
+		t.Errorf("want the parentheses left out of ToString, have %q", have)
+	}
+}
+
+func TestOperatorPos(t *testing.T) {
+	input := "a[0] += f(1 + (2))"
+	assign := parse(t, input).Statements[0].(*ast.AssignStatement)
+	call := assign.Value.(*ast.CallExpression)
+	sum := call.Arguments[0].(*ast.InfixExpression)
+
+	tests := []struct {
+		node   ast.Node
+		column int
+	}{
+		{assign, 6},
+		{assign.Target, 2},
+		{call, 10},
+		{sum, 13},
+		{sum.Right, 15},
+		{call.Function, 9},
+	}
+	for _, tt := range tests {
+		if have := ast.OperatorPos(tt.node).Column; have != tt.column {
+			t.Errorf("%s: want column %d, have %d", tt.node.ToString(), tt.column, have)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// the JSON form of a tree, for tools that are not written in Go:
+// every node is an object with its "kind", the "pos" and "end" of its span, then one member per field,
+// named like the field with a lowercase first letter; tokens and positions look the way the token package encodes them,
+// a missing node is null; what later passes fill in, like the resolver's slots, is left out
+
+// node types by the kind their JSON names
+var kinds = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []Node{
+		&Program{}, &Identifier{}, &LetStatement{}, &SendStatement{}, &BadStatement{}, &ExpressionStatement{},
+		&IntegerLiteral{}, &PrefixExpression{}, &InfixExpression{}, &AssignStatement{}, &ArrayLiteral{}, &HashLiteral{},
+		&IndexExpression{}, &Boolean{}, &StringLiteral{}, &BlockStatement{}, &WhenExpression{}, &FunctionLiteral{},
+		&CallExpression{}, &WhileStatement{}, &ForStatement{}, &BreakStatement{}, &ContinueStatement{},
+		&ThrowStatement{}, &TryExpression{}, &TypeExpression{}, &GroupedExpression{},
+	} {
+		t := reflect.TypeOf(node).Elem()
+		kinds[t.Name()] = t
+	}
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+// ToJSON encodes the tree under node, indented so people can read it too
+func ToJSON(node Node) ([]byte, error) {
+	return json.MarshalIndent(encode(reflect.ValueOf(node)), "", "  ")
+}
+
+// FromJSON decodes what ToJSON encoded back into a tree
+func FromJSON(data []byte) (Node, error) {
+	node, err := decode(data, nodeType, "$")
+	if err != nil {
+		return nil, err
+	}
+	return node.Interface().(Node), nil
+}
+
+// object keeps its members in order, so kind and span come first
+type object []member
+
+type member struct {
+	name  string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&out, "%q:", m.name)
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// v holds a node, as an interface or a pointer
+func encode(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.IsNil() {
+		return nil
+	}
+	node := v.Interface().(Node)
+	out := object{{"kind", v.Elem().Type().Name()}, {"pos", node.Pos()}, {"end", node.End()}}
+
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := fieldName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		switch {
+		case field.Type().Implements(nodeType):
+			out = append(out, member{name, encode(field)})
+		case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+			var items []interface{}
+			if !field.IsNil() {
+				items = make([]interface{}, field.Len())
+			}
+			for j := range items {
+				items[j] = encode(field.Index(j))
+			}
+			out = append(out, member{name, items})
+		default:
+			out = append(out, member{name, field.Interface()})
+		}
+	}
+	return out
+}
+
+// decodes the node in data into a value of type want, an interface like Expression or a pointer like *BlockStatement
+// path is where data is in the whole document, for the errors
+func decode(data []byte, want reflect.Type, path string) (reflect.Value, error) {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return reflect.Zero(want), nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return reflect.Value{}, fmt.Errorf("ast: %s: want a node, have %s", path, data)
+	}
+	var kind string
+	if raw, ok := members["kind"]; !ok || json.Unmarshal(raw, &kind) != nil {
+		return reflect.Value{}, fmt.Errorf("ast: %s: node without a kind", path)
+	}
+	t, ok := kinds[kind]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("ast: %s: unknown node kind %q", path, kind)
+	}
+	node := reflect.New(t)
+	if !node.Type().AssignableTo(want) {
+		return reflect.Value{}, fmt.Errorf("ast: %s: %s is not a %s", path, kind, strings.TrimLeft(want.String(), "*")[len("ast."):])
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		raw, present := members[name]
+		if !ok || !present {
+			continue
+		}
+		if err := decodeField(raw, node.Elem().Field(i), path+"."+name); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	return node, nil
+}
+
+func decodeField(data []byte, field reflect.Value, path string) error {
+	switch {
+	case field.Type().Implements(nodeType):
+		node, err := decode(data, field.Type(), path)
+		if err != nil {
+			return err
+		}
+		field.Set(node)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Implements(nodeType):
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("ast: %s: %v", path, err)
+		}
+		if items == nil {
+			return nil
+		}
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			node, err := decode(item, field.Type().Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+			slice.Index(i).Set(node)
+		}
+		field.Set(slice)
+	default:
+		if err := json.Unmarshal(data, field.Addr().Interface()); err != nil {
+			return fmt.Errorf("ast: %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// the member a field is encoded as, false for the fields that are left out
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.Tag.Get("json") == "-" {
+		return "", false
+	}
+	return strings.ToLower(field.Name[:1]) + field.Name[1:], true
+}
@@ -0,0 +1,75 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"strings"
+	"testing"
+)
+
+// the span of every node, to compare trees beyond what ToString shows
+func spans(node ast.Node) string {
+	var out []string
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			out = append(out, n.Pos().String()+"-"+n.End().String())
+		}
+		return true
+	})
+	return strings.Join(out, " ")
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		"let x: {str: [int]} = -(1 + 2) * 3 ** 2;",
+		`const s = "a\"b\n"; s += "c"; send s[0];`,
+		"let f = fn(a: int, b) -> fn(int) -> bool { when (a < b) { a } otherwise { b } };",
+		"let h = {1: yes, \"k\": [no]}; h[1] = f(1, 2)(3);",
+		"for (x in [1, 2]) { when (x == 2) { break; } continue; } while (no) {}",
+		"try { throw {} } catch (e) { e } try { 1 } catch { 2 }",
+		"",
+	}
+
+	for _, input := range tests {
+		program := parse(t, input)
+		data, err := ast.ToJSON(program)
+		if err != nil {
+			t.Fatalf("input %q: %v", input, err)
+		}
+		decoded, err := ast.FromJSON(data)
+		if err != nil {
+			t.Fatalf("input %q: %v", input, err)
+		}
+
+		if decoded.ToString() != program.ToString() {
+			t.Errorf("input %q: want %q, have %q", input, program.ToString(), decoded.ToString())
+		}
+		if spans(decoded) != spans(program) {
+			t.Errorf("input %q: spans changed\nwant %s\nhave %s", input, spans(program), spans(decoded))
+		}
+		again, _ := ast.ToJSON(decoded)
+		if string(again) != string(data) {
+			t.Errorf("input %q: encoding the decoded tree gives different JSON", input)
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1]`, "ast: $: want a node, have [1]"},
+		{`{"statements": []}`, "ast: $: node without a kind"},
+		{`{"kind": "Program", "statements": [{"kind": "Loop"}]}`, `ast: $.statements[0]: unknown node kind "Loop"`},
+		{`{"kind": "Program", "statements": [{"kind": "IntegerLiteral"}]}`, "ast: $.statements[0]: IntegerLiteral is not a Statement"},
+		{`{"kind": "WhileStatement", "body": {"kind": "Boolean"}}`, "ast: $.body: Boolean is not a BlockStatement"},
+		{`{"kind": "IntegerLiteral", "value": "1"}`, "ast: $.value: json: cannot unmarshal string into Go value of type int"},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.FromJSON([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("input %s: want error %q, have %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package ast
+
+// TailCalls the calls in a function body whose value the function returns as it is, nothing is left to do after them:
+// the value of a send, and the expression the body ends in, through parentheses and the branches of when and catch
+// a send in a try block is not one, the catch may still have to handle what the call throws;
+// functions nested in body are left out, they have tail calls of their own
+func TailCalls(body *BlockStatement) []*CallExpression {
+	t := &tails{}
+	t.block(body, true)
+	return t.calls
+}
+
+type tails struct {
+	calls []*CallExpression
+}
+
+// final says whether what the block evaluates to is returned
+func (t *tails) block(block *BlockStatement, final bool) {
+	for i, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *SendStatement:
+			t.expression(stmt.Value, true)
+		case *ExpressionStatement:
+			t.expression(stmt.Expression, final && i == len(block.Statements)-1)
+		case *WhileStatement:
+			t.block(stmt.Body, false)
+		case *ForStatement:
+			t.block(stmt.Body, false)
+		}
+	}
+}
+
+// exp is a tail call itself when returned is set, the sends in its blocks are either way
+func (t *tails) expression(exp Expression, returned bool) {
+	switch exp := Unparen(exp).(type) {
+	case *CallExpression:
+		if returned {
+			t.calls = append(t.calls, exp)
+		}
+	case *WhenExpression:
+		t.block(exp.Consequence, returned)
+		if exp.Alternative != nil {
+			t.block(exp.Alternative, returned)
+		}
+	case *TryExpression:
+		t.block(exp.Handler, returned)
+	}
+}
@@ -0,0 +1,37 @@
+package ast_test
+
+import (
+	"monkey/ast"
+	"strings"
+	"testing"
+)
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the functions of the tail calls found in the first function, in source order
+	}{
+		{"fn() { a(); b() }", "b"},
+		{"fn() { send a(); b(); send (c()) }", "a c"},
+		{"fn() { a() + b() }", ""},
+		{"fn() { let x = a(); x }", ""},
+		{"fn() { when (c()) { a() } otherwise { b(); 1 } }", "a"},
+		{"fn() { when (x) { send a() }; b() }", "a b"},
+		{"fn() { while (x) { a(); send b() } }", "b"},
+		{"fn() { for (x in y) { when (x) { send a() } }; 0 }", "a"},
+		{"fn() { try { send a(); b() } catch (e) { send c(); d() } }", "c d"},
+		{"fn() { fn() { a() }; f()() }", "f()"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		fl := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+		var calls []string
+		for _, call := range ast.TailCalls(fl.Body) {
+			calls = append(calls, call.Function.ToString())
+		}
+		if have := strings.Join(calls, " "); have != tt.expected {
+			t.Errorf("input %q: want %q, have %q", tt.input, tt.expected, have)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package ast
+
+import "fmt"
+
+// Visitor what Walk calls for every node, same as go/ast:
+// Visit(node) returns the visitor for the children of node, nil to skip them
+// after the children, Visit(nil) is called on that visitor
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree under node depth first, children in the order they appear in the source
+// type annotations are walked too, they are Nodes even though they are not expressions
+// a nil node, like a field a failed parse left empty, is not visited
+func Walk(v Visitor, node Node) {
+	if node == nil {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			Walk(v, stmt)
+		}
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			Walk(v, stmt)
+		}
+	case *LetStatement:
+		Walk(v, n.Name)
+		if n.Type != nil {
+			Walk(v, n.Type)
+		}
+		Walk(v, n.Value)
+	case *AssignStatement:
+		Walk(v, n.Target)
+		Walk(v, n.Value)
+	case *SendStatement:
+		Walk(v, n.Value)
+	case *ThrowStatement:
+		Walk(v, n.Value)
+	case *ExpressionStatement:
+		Walk(v, n.Expression)
+	case *WhileStatement:
+		Walk(v, n.Condition)
+		Walk(v, n.Body)
+	case *ForStatement:
+		Walk(v, n.Variable)
+		Walk(v, n.Iterable)
+		Walk(v, n.Body)
+	case *PrefixExpression:
+		Walk(v, n.Right)
+	case *GroupedExpression:
+		Walk(v, n.Expression)
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+	case *WhenExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *TryExpression:
+		Walk(v, n.Block)
+		if n.Param != nil {
+			Walk(v, n.Param)
+		}
+		Walk(v, n.Handler)
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			Walk(v, param)
+			if i < len(n.ParamTypes) && n.ParamTypes[i] != nil {
+				Walk(v, n.ParamTypes[i])
+			}
+		}
+		if n.ReturnType != nil {
+			Walk(v, n.ReturnType)
+		}
+		Walk(v, n.Body)
+	case *CallExpression:
+		Walk(v, n.Function)
+		for _, arg := range n.Arguments {
+			Walk(v, arg)
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			Walk(v, el)
+		}
+	case *HashLiteral:
+		for i, key := range n.Keys {
+			Walk(v, key)
+			Walk(v, n.Values[i])
+		}
+	case *IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+	case *TypeExpression:
+		for _, param := range n.Params {
+			Walk(v, param)
+		}
+		if n.Result != nil {
+			Walk(v, n.Result)
+		}
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean, *BreakStatement, *ContinueStatement, *BadStatement:
+		// leaves
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect calls f for every node under node, node included, as Walk visits them
+// f returning false skips the children, after them f(nil) is called
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite replaces nodes bottom up: the children of a node are rewritten first, then f gets the node holding the
+// rewritten children, and what f returns takes its place; f returns its argument to keep a node
+// the replacement has to fit where the node was, an expression for an expression, a block for a block and so on,
+// anything else panics; the tree is changed in place, the rewritten root is returned
+func Rewrite(node Node, f func(Node) Node) Node {
+	if node == nil {
+		return nil
+	}
+	switch n := node.(type) {
+	case *Program:
+		for i, stmt := range n.Statements {
+			n.Statements[i] = rewriteAs[Statement](stmt, f)
+		}
+	case *BlockStatement:
+		for i, stmt := range n.Statements {
+			n.Statements[i] = rewriteAs[Statement](stmt, f)
+		}
+	case *LetStatement:
+		n.Name = rewriteAs[*Identifier](n.Name, f)
+		if n.Type != nil {
+			n.Type = rewriteAs[*TypeExpression](n.Type, f)
+		}
+		n.Value = rewriteAs[Expression](n.Value, f)
+	case *AssignStatement:
+		n.Target = rewriteAs[Expression](n.Target, f)
+		n.Value = rewriteAs[Expression](n.Value, f)
+	case *SendStatement:
+		n.Value = rewriteAs[Expression](n.Value, f)
+	case *ThrowStatement:
+		n.Value = rewriteAs[Expression](n.Value, f)
+	case *ExpressionStatement:
+		n.Expression = rewriteAs[Expression](n.Expression, f)
+	case *WhileStatement:
+		n.Condition = rewriteAs[Expression](n.Condition, f)
+		n.Body = rewriteAs[*BlockStatement](n.Body, f)
+	case *ForStatement:
+		n.Variable = rewriteAs[*Identifier](n.Variable, f)
+		n.Iterable = rewriteAs[Expression](n.Iterable, f)
+		n.Body = rewriteAs[*BlockStatement](n.Body, f)
+	case *PrefixExpression:
+		n.Right = rewriteAs[Expression](n.Right, f)
+	case *GroupedExpression:
+		n.Expression = rewriteAs[Expression](n.Expression, f)
+	case *InfixExpression:
+		n.Left = rewriteAs[Expression](n.Left, f)
+		n.Right = rewriteAs[Expression](n.Right, f)
+	case *WhenExpression:
+		n.Condition = rewriteAs[Expression](n.Condition, f)
+		n.Consequence = rewriteAs[*BlockStatement](n.Consequence, f)
+		if n.Alternative != nil {
+			n.Alternative = rewriteAs[*BlockStatement](n.Alternative, f)
+		}
+	case *TryExpression:
+		n.Block = rewriteAs[*BlockStatement](n.Block, f)
+		if n.Param != nil {
+			n.Param = rewriteAs[*Identifier](n.Param, f)
+		}
+		n.Handler = rewriteAs[*BlockStatement](n.Handler, f)
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			n.Parameters[i] = rewriteAs[*Identifier](param, f)
+			if i < len(n.ParamTypes) && n.ParamTypes[i] != nil {
+				n.ParamTypes[i] = rewriteAs[*TypeExpression](n.ParamTypes[i], f)
+			}
+		}
+		if n.ReturnType != nil {
+			n.ReturnType = rewriteAs[*TypeExpression](n.ReturnType, f)
+		}
+		n.Body = rewriteAs[*BlockStatement](n.Body, f)
+	case *CallExpression:
+		n.Function = rewriteAs[Expression](n.Function, f)
+		for i, arg := range n.Arguments {
+			n.Arguments[i] = rewriteAs[Expression](arg, f)
+		}
+	case *ArrayLiteral:
+		for i, el := range n.Elements {
+			n.Elements[i] = rewriteAs[Expression](el, f)
+		}
+	case *HashLiteral:
+		for i := range n.Keys {
+			n.Keys[i] = rewriteAs[Expression](n.Keys[i], f)
+			n.Values[i] = rewriteAs[Expression](n.Values[i], f)
+		}
+	case *IndexExpression:
+		n.Left = rewriteAs[Expression](n.Left, f)
+		n.Index = rewriteAs[Expression](n.Index, f)
+	case *TypeExpression:
+		for i, param := range n.Params {
+			n.Params[i] = rewriteAs[*TypeExpression](param, f)
+		}
+		if n.Result != nil {
+			n.Result = rewriteAs[*TypeExpression](n.Result, f)
+		}
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean, *BreakStatement, *ContinueStatement, *BadStatement:
+		// leaves
+	default:
+		panic(fmt.Sprintf("ast.Rewrite: unexpected node type %T", n))
+	}
+	return f(node)
+}
+
+// rewrites node and checks that what comes back fits into a field of type T
+func rewriteAs[T Node](node T, f func(Node) Node) T {
+	result := Rewrite(node, f)
+	if result == nil {
+		var empty T
+		return empty
+	}
+	replaced, ok := result.(T)
+	if !ok {
+		panic(fmt.Sprintf("ast.Rewrite: %T cannot take the place of %T", result, node))
+	}
+	return replaced
+}
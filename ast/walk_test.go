@@ -0,0 +1,132 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	return program
+}
+
+// node types in the order Inspect reaches them
+func visited(node ast.Node) string {
+	var types []string
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			types = append(types, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		}
+		return true
+	})
+	return strings.Join(types, " ")
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = -1;", "Program LetStatement Identifier TypeExpression PrefixExpression IntegerLiteral"},
+		{"a[0] += f(yes, \"s\");", "Program AssignStatement IndexExpression Identifier IntegerLiteral CallExpression Identifier Boolean StringLiteral"},
+		{"fn(a: [int]) -> str { send a; }",
+			"Program ExpressionStatement FunctionLiteral Identifier TypeExpression TypeExpression TypeExpression BlockStatement SendStatement Identifier"},
+		{"when (a) { 1 } otherwise { {1: 2} }",
+			"Program ExpressionStatement WhenExpression Identifier BlockStatement ExpressionStatement IntegerLiteral BlockStatement ExpressionStatement HashLiteral IntegerLiteral IntegerLiteral"},
+		{"for (x in xs) { break; } while (no) { continue; }",
+			"Program ForStatement Identifier Identifier BlockStatement BreakStatement WhileStatement Boolean BlockStatement ContinueStatement"},
+		{"try { throw [1]; } catch (e) { e }",
+			"Program ExpressionStatement TryExpression BlockStatement ThrowStatement ArrayLiteral IntegerLiteral Identifier BlockStatement ExpressionStatement Identifier"},
+	}
+
+	for _, tt := range tests {
+		if have := visited(parse(t, tt.input)); have != tt.expected {
+			t.Errorf("input %q:\nwant %s\nhave %s", tt.input, tt.expected, have)
+		}
+	}
+}
+
+// counts identifiers outside of functions, skipping a node skips what is below it
+type counter struct{ identifiers, left int }
+
+func (c *counter) Visit(node ast.Node) ast.Visitor {
+	switch node.(type) {
+	case nil:
+		c.left++
+	case *ast.FunctionLiteral:
+		return nil
+	case *ast.Identifier:
+		c.identifiers++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	program := parse(t, "let a = b + fn(c) { d }; e;")
+	c := &counter{}
+	ast.Walk(c, program)
+	if c.identifiers != 3 {
+		t.Errorf("want 3 identifiers outside of functions, have %d", c.identifiers)
+	}
+	// every node whose children were walked is left once: program, let, infix, a, b, expression statement, e
+	if c.left != 7 {
+		t.Errorf("want 7 nodes left, have %d", c.left)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	program := parse(t, "let x = 1 + 2 * 3; x * 4;")
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		infix, ok := node.(*ast.InfixExpression)
+		if !ok {
+			return node
+		}
+		left, lok := infix.Left.(*ast.IntegerLiteral)
+		right, rok := infix.Right.(*ast.IntegerLiteral)
+		if !lok || !rok {
+			return node
+		}
+		value := left.Value + right.Value
+		if infix.Operator == "*" {
+			value = left.Value * right.Value
+		}
+		// children are rewritten first, so 2 * 3 is already 6 by the time 1 + ... gets here
+		return &ast.IntegerLiteral{Token: infix.Token, Value: value}
+	})
+
+	values := []string{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.IntegerLiteral); ok {
+			values = append(values, fmt.Sprint(lit.Value))
+		}
+		return true
+	})
+	if have := strings.Join(values, " "); have != "7 4" {
+		t.Errorf("want literals 7 4, have %s", have)
+	}
+}
+
+func TestRewriteChecksReplacements(t *testing.T) {
+	program := parse(t, "when (yes) { 1 }")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("replacing a block with an expression did not panic")
+		}
+	}()
+	ast.Rewrite(program, func(node ast.Node) ast.Node {
+		if _, ok := node.(*ast.BlockStatement); ok {
+			return &ast.Boolean{Value: true}
+		}
+		return node
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/checker"
+	"monkey/evaluator"
+	"monkey/resolver"
+)
+
+func init() {
+	commands["check"] = runCheck
+}
+
+// monkey check file... type checks without running, the exit code is 1 when anything was found
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprintln(stderr, "usage: monkey check file...") }
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	builtins := evaluator.New(io.Discard).BuiltinNames()
+	status := 0
+	for _, path := range flags.Args() {
+		program, ok := parseFile(path, stderr)
+		if !ok {
+			status = 1
+			continue
+		}
+		diags := resolver.New(builtins...).Resolve(program)
+		for _, d := range diags {
+			fmt.Fprintf(stdout, "%s: %v\n", path, d)
+		}
+		if resolver.HasErrors(diags) {
+			status = 1
+			continue
+		}
+		for _, d := range checker.Check(program) {
+			fmt.Fprintf(stdout, "%s: %v\n", path, d)
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,73 @@
+package checker
+
+import "monkey/ast"
+
+// types of the default builtins, used when a script passes one around as a value
+var builtins = map[string]*Type{
+	"len":        FuncOf(Int, Any),
+	"print":      FuncOf(Null),
+	"puts":       FuncOf(Null),
+	"first":      FuncOf(Any, ArrayOf(Any)),
+	"last":       FuncOf(Any, ArrayOf(Any)),
+	"rest":       FuncOf(ArrayOf(Any), ArrayOf(Any)),
+	"push":       FuncOf(ArrayOf(Any), ArrayOf(Any), Any),
+	"type":       FuncOf(Str, Any),
+	"str":        FuncOf(Str, Any),
+	"int":        FuncOf(Int, Any),
+	"read_file":  FuncOf(Str, Str),
+	"write_file": FuncOf(Null, Str, Str),
+	"now":        FuncOf(Int),
+	"sleep":      FuncOf(Null, Int),
+	"rand":       FuncOf(Int, Int),
+	// the assertions take an optional message, so like print their parameters are not spelled out
+	"assert":       FuncOf(Null),
+	"assert_eq":    FuncOf(Null),
+	"assert_error": FuncOf(Any),
+}
+
+// results of calling the builtins, some depend on the arguments, like first([1]) being an int
+// the builtins check their own arguments at runtime, so they are not checked here
+var builtinResults = map[string]func(args []*Type) *Type{
+	"first": elementOf,
+	"last":  elementOf,
+	"rest": func(args []*Type) *Type {
+		if len(args) == 1 && args[0].Name == "array" {
+			return args[0]
+		}
+		return ArrayOf(Any)
+	},
+	"push": func(args []*Type) *Type {
+		if len(args) == 2 && args[0].Name == "array" {
+			return ArrayOf(join(args[0].Params[0], args[1]))
+		}
+		return ArrayOf(Any)
+	},
+}
+
+func init() {
+	for name, typ := range builtins {
+		if _, ok := builtinResults[name]; !ok {
+			result := typ.Result
+			builtinResults[name] = func([]*Type) *Type { return result }
+		}
+	}
+}
+
+func elementOf(args []*Type) *Type {
+	if len(args) == 1 && args[0].Name == "array" {
+		return args[0].Params[0]
+	}
+	return Any
+}
+
+// collects the names of every identifier assigned to with =, += and friends
+func collectAssigned(node ast.Node, names map[string]bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if assign, ok := n.(*ast.AssignStatement); ok {
+			if id, ok := assign.Target.(*ast.Identifier); ok {
+				names[id.Value] = true
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,413 @@
+// Package checker finds type errors before a program runs, using the optional annotations
+//
+//	let x: int = 5;
+//	let add = fn(a: int, b: int) -> int { a + b };
+//
+// Types missing an annotation are inferred from the values, and whatever cannot be inferred is any, which fits
+// everywhere. Only annotations are checked against, a program without any is never reported.
+package checker
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+// Diagnostic a type error, Pos is where the offending value or annotation starts
+type Diagnostic struct {
+	Pos     token.Position
+	Message string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("type error at %v: %v", d.Pos, d.Message)
+}
+
+type binding struct {
+	typ       *Type
+	annotated bool // assignments have to fit typ
+}
+
+// scopes follow the environments of the evaluator, like the resolver's do
+type scope struct {
+	bindings map[string]*binding
+	outer    *scope
+}
+
+func (s *scope) lookup(name string) (*binding, bool) {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.bindings[name]; ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// the function whose body is being checked
+type function struct {
+	result *Type // annotated result, nil when there is none
+	sends  *Type // join of everything sent so far
+}
+
+type checker struct {
+	scope     *scope
+	functions []*function
+	// names assigned to anywhere in the program, unannotated bindings of these names are any
+	// since the value they hold when something reads them is not known
+	assigned   map[string]bool
+	signatures map[*ast.FunctionLiteral]*Type
+	diags      []Diagnostic
+	types      map[*ast.Identifier]*Type // what each identifier was found to be
+}
+
+// Check type checks the program, it does not change anything about how it runs
+func Check(program *ast.Program) []Diagnostic {
+	c := newChecker(program)
+	c.check(program)
+	return c.diags
+}
+
+// Types the type of every identifier in program where it appears, declared names included, any where nothing is known
+func Types(program *ast.Program) map[*ast.Identifier]*Type {
+	c := newChecker(program)
+	c.check(program)
+	return c.types
+}
+
+func newChecker(program *ast.Program) *checker {
+	c := &checker{
+		scope:      &scope{bindings: make(map[string]*binding)},
+		assigned:   make(map[string]bool),
+		signatures: make(map[*ast.FunctionLiteral]*Type),
+		types:      make(map[*ast.Identifier]*Type),
+	}
+	collectAssigned(program, c.assigned)
+	return c
+}
+
+func (c *checker) check(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		c.statement(stmt)
+	}
+}
+
+// errorf reports at pos, for a value that is where its expression starts
+func (c *checker) errorf(pos token.Position, format string, a ...interface{}) {
+	c.diags = append(c.diags, Diagnostic{Pos: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) inScope(fn func()) {
+	c.scope = &scope{bindings: make(map[string]*binding), outer: c.scope}
+	defer func() { c.scope = c.scope.outer }()
+	fn()
+}
+
+func (c *checker) declare(name *ast.Identifier, typ *Type, annotated bool) {
+	c.scope.bindings[name.Value] = &binding{typ: typ, annotated: annotated}
+	c.types[name] = typ
+}
+
+// statement checks stmt and returns the type of the value it leaves, which matters for the last one of a block
+func (c *checker) statement(stmt ast.Statement) *Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.letStatement(stmt)
+	case *ast.AssignStatement:
+		c.assignStatement(stmt)
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	case *ast.SendStatement:
+		typ := c.expression(stmt.Value)
+		if len(c.functions) > 0 {
+			fn := c.functions[len(c.functions)-1]
+			c.checkResult(fn, stmt.Value, typ)
+			fn.sends = join(fn.sends, typ)
+		}
+		return typ
+	case *ast.ThrowStatement:
+		c.expression(stmt.Value)
+	case *ast.WhileStatement:
+		c.expression(stmt.Condition)
+		c.inScope(func() { c.block(stmt.Body) })
+	case *ast.ForStatement:
+		iterable := c.expression(stmt.Iterable)
+		variable := Any
+		switch iterable.Name {
+		case "array", "hash":
+			variable = iterable.Params[0]
+		case "str":
+			variable = Str
+		}
+		c.inScope(func() {
+			c.declare(stmt.Variable, variable, false)
+			c.block(stmt.Body)
+		})
+	}
+	return Null
+}
+
+func (c *checker) block(block *ast.BlockStatement) *Type {
+	typ := Null
+	for _, stmt := range block.Statements {
+		typ = c.statement(stmt)
+	}
+	return typ
+}
+
+func (c *checker) letStatement(let *ast.LetStatement) {
+	name := let.Name.Value
+	declared := Any
+	if let.Type != nil {
+		declared = c.typeOf(let.Type)
+	}
+
+	// a function can call itself, so its signature is known before its body is checked
+	if fl, ok := ast.Unparen(let.Value).(*ast.FunctionLiteral); ok {
+		c.declare(let.Name, c.signature(fl), let.Type != nil)
+	}
+
+	typ := c.expression(let.Value)
+	switch {
+	case let.Type != nil:
+		if !assignable(declared, typ) {
+			c.errorf(let.Value.Pos(), "cannot use %v as %v in let %s", typ, declared, name)
+		}
+		c.declare(let.Name, declared, true)
+	case c.assigned[name] && !let.IsConstant():
+		c.declare(let.Name, Any, false)
+	default:
+		c.declare(let.Name, typ, false)
+	}
+}
+
+func (c *checker) assignStatement(assign *ast.AssignStatement) {
+	value := c.expression(assign.Value)
+	id, ok := assign.Target.(*ast.Identifier)
+	if !ok {
+		c.expression(assign.Target)
+		return
+	}
+	b, ok := c.scope.lookup(id.Value)
+	if ok {
+		c.types[id] = b.typ
+	}
+	if !ok || !b.annotated {
+		return
+	}
+	if assign.Token.Type != token.ASSIGN {
+		// x += 1 assigns x + 1
+		value = infix(operators[assign.Token.Type], b.typ, value)
+	}
+	if !assignable(b.typ, value) {
+		c.errorf(assign.Value.Pos(), "cannot assign %v to %s of type %v", value, id.Value, b.typ)
+	}
+}
+
+// the compound assignments and the operator they apply
+var operators = map[token.TokenType]string{
+	token.PLUS_ASSIGN:     "+",
+	token.MINUS_ASSIGN:    "-",
+	token.MULTIPLY_ASSIGN: "*",
+	token.DIVIDE_ASSIGN:   "/",
+}
+
+func (c *checker) expression(exp ast.Expression) *Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.Boolean:
+		return Bool
+	case *ast.StringLiteral:
+		return Str
+	case *ast.Identifier:
+		typ := Any
+		if b, ok := c.scope.lookup(exp.Value); ok {
+			typ = b.typ
+		} else if builtin, ok := builtins[exp.Value]; ok {
+			typ = builtin
+		}
+		c.types[exp] = typ
+		return typ
+	case *ast.GroupedExpression:
+		return c.expression(exp.Expression)
+	case *ast.PrefixExpression:
+		right := c.expression(exp.Right)
+		switch {
+		case exp.Operator == "!":
+			return Bool
+		case right == Int:
+			return Int
+		}
+		return Any
+	case *ast.InfixExpression:
+		return infix(exp.Operator, c.expression(exp.Left), c.expression(exp.Right))
+	case *ast.ArrayLiteral:
+		var elem *Type
+		for _, el := range exp.Elements {
+			elem = join(elem, c.expression(el))
+		}
+		if elem == nil {
+			elem = Any
+		}
+		return ArrayOf(elem)
+	case *ast.HashLiteral:
+		var key, value *Type
+		for i := range exp.Keys {
+			key = join(key, c.expression(exp.Keys[i]))
+			value = join(value, c.expression(exp.Values[i]))
+		}
+		if key == nil {
+			key, value = Any, Any
+		}
+		return HashOf(key, value)
+	case *ast.IndexExpression:
+		left := c.expression(exp.Left)
+		c.expression(exp.Index)
+		switch left.Name {
+		case "array":
+			return left.Params[0]
+		case "hash":
+			return left.Params[1]
+		}
+		return Any
+	case *ast.WhenExpression:
+		c.expression(exp.Condition)
+		typ := c.block(exp.Consequence)
+		if exp.Alternative == nil {
+			return join(typ, Null)
+		}
+		return join(typ, c.block(exp.Alternative))
+	case *ast.TryExpression:
+		typ := c.block(exp.Block)
+		var handler *Type
+		c.inScope(func() {
+			if exp.Param != nil {
+				c.declare(exp.Param, Any, false)
+			}
+			handler = c.block(exp.Handler)
+		})
+		return join(typ, handler)
+	case *ast.FunctionLiteral:
+		return c.functionLiteral(exp)
+	case *ast.CallExpression:
+		return c.call(exp)
+	}
+	return Any
+}
+
+// the types two operands give with op, any when the operands do not tell
+func infix(op string, left, right *Type) *Type {
+	switch op {
+	case "==", "!=", "<", ">", "<=", ">=":
+		return Bool
+	case "+":
+		if left == Str && right == Str {
+			return Str
+		}
+		fallthrough
+	case "-", "*", "/", "%", "**", "&", "|", "^", "<<", ">>":
+		if left == Int && right == Int {
+			return Int
+		}
+	}
+	return Any
+}
+
+// the type a function literal has from its annotations alone, missing ones are any
+// worked out once per literal, so unknown type names are reported once
+func (c *checker) signature(fl *ast.FunctionLiteral) *Type {
+	if fn, ok := c.signatures[fl]; ok {
+		return fn
+	}
+	fn := FuncOf(Any)
+	c.signatures[fl] = fn
+	for i := range fl.Parameters {
+		var annotation *ast.TypeExpression
+		if i < len(fl.ParamTypes) {
+			annotation = fl.ParamTypes[i]
+		}
+		fn.Params = append(fn.Params, c.typeOf(annotation))
+		fn.annotated = fn.annotated || annotation != nil
+	}
+	if fl.ReturnType != nil {
+		fn.Result = c.typeOf(fl.ReturnType)
+		fn.annotated = true
+	}
+	return fn
+}
+
+func (c *checker) functionLiteral(fl *ast.FunctionLiteral) *Type {
+	fn := c.signature(fl)
+
+	ctx := &function{}
+	if fl.ReturnType != nil {
+		ctx.result = fn.Result
+	}
+	c.functions = append(c.functions, ctx)
+	defer func() { c.functions = c.functions[:len(c.functions)-1] }()
+
+	var last *Type
+	c.inScope(func() {
+		for i, param := range fl.Parameters {
+			annotated := i < len(fl.ParamTypes) && fl.ParamTypes[i] != nil
+			c.declare(param, fn.Params[i], annotated)
+		}
+		last = c.block(fl.Body)
+	})
+
+	// the value a body ends with is a result too, unless it ends by sending
+	if n := len(fl.Body.Statements); n > 0 {
+		if es, ok := fl.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+			c.checkResult(ctx, es.Expression, last)
+			ctx.sends = join(ctx.sends, last)
+		}
+	}
+	if fl.ReturnType == nil {
+		fn.Result = Null
+		if ctx.sends != nil {
+			fn.Result = ctx.sends
+		}
+	}
+	return fn
+}
+
+// checks a value the function results in against its annotated result
+func (c *checker) checkResult(fn *function, exp ast.Expression, typ *Type) {
+	if fn.result != nil && !assignable(fn.result, typ) {
+		c.errorf(exp.Pos(), "cannot return %v from a function declared to return %v", typ, fn.result)
+	}
+}
+
+func (c *checker) call(call *ast.CallExpression) *Type {
+	callee := c.expression(call.Function)
+	args := make([]*Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	if id, ok := call.Function.(*ast.Identifier); ok {
+		if _, shadowed := c.scope.lookup(id.Value); !shadowed {
+			if result, ok := builtinResults[id.Value]; ok {
+				return result(args)
+			}
+		}
+	}
+	if callee.Name != "fn" {
+		return Any
+	}
+
+	if callee.annotated {
+		if len(args) != len(callee.Params) {
+			c.errorf(call.Token.Pos, "wrong number of arguments to %s: want %d, got %d",
+				call.Function.ToString(), len(callee.Params), len(args))
+		} else {
+			for i, arg := range args {
+				if !assignable(callee.Params[i], arg) {
+					c.errorf(call.Arguments[i].Pos(), "cannot use %v as %v in argument %d to %s",
+						arg, callee.Params[i], i+1, call.Function.ToString())
+				}
+			}
+		}
+	}
+	return callee.Result
+}
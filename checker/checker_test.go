@@ -0,0 +1,137 @@
+package checker
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, input string) []Diagnostic {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	return Check(program)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x: int = 5;", nil},
+		{`let x: int = "five";`, []string{"type error at 1:14: cannot use str as int in let x"}},
+		{"let x: int = 1; let y: str = x * 2;", []string{"type error at 1:30: cannot use int as str in let y"}},
+		{`let x: int = 1; x = "s";`, []string{"type error at 1:21: cannot assign str to x of type int"}},
+		{"let x: int = 1; x += 1;", nil},
+		{"let x: bool = 1 < 2; let y: any = x; let z: int = y;", nil},
+		{"let xs: [int] = [1, 2]; let ys: [str] = push(xs, 3);", []string{"type error at 1:41: cannot use [int] as [str] in let ys"}},
+		{`let h: {str: int} = {"a": 1}; let v: int = h["a"];`, nil},
+		{"let x: number = 1;", []string{"type error at 1:8: unknown type: number"}},
+		// functions
+		{`let add = fn(a: int, b: int) -> int { a + b }; add(1, "2")`, []string{"type error at 1:55: cannot use str as int in argument 2 to add"}},
+		{"let add = fn(a: int, b: int) -> int { a + b }; add(1)", []string{"type error at 1:51: wrong number of arguments to add: want 2, got 1"}},
+		{`let f = fn() -> int { "s" };`, []string{"type error at 1:23: cannot return str from a function declared to return int"}},
+		{`let f = fn(n: int) -> int { when (n > 0) { send "pos" }; n };`, []string{"type error at 1:49: cannot return str from a function declared to return int"}},
+		{"let f = fn(n: int) -> int { when (n == 0) { send 0 }; f(n - 1) };", nil},
+		// results are inferred when they are not annotated
+		{`let f = fn(n: int) { "s" + "t" }; let x: int = f(1);`, []string{"type error at 1:48: cannot use str as int in let x"}},
+		{"let apply = fn(g: fn(int) -> int, x: int) -> int { g(x) }; apply(fn(x: int) -> int { x }, 1)", nil},
+		{`let apply = fn(g: fn(int) -> int) -> int { g(1) }; apply(fn(x: str) -> str { x })`,
+			[]string{"type error at 1:58: cannot use fn(str) -> str as fn(int) -> int in argument 1 to apply"}},
+		{`for (c in "abc") { let s: str = c; s }`, nil},
+		{"let s: str = first([1]);", []string{"type error at 1:14: cannot use int as str in let s"}},
+	}
+
+	for _, tt := range tests {
+		diags := check(t, tt.input)
+		if len(diags) != len(tt.expected) {
+			t.Errorf("input %q: want %d diagnostics, got %v", tt.input, len(tt.expected), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Error() != tt.expected[i] {
+				t.Errorf("input %q: want %q, got %q", tt.input, tt.expected[i], d.Error())
+			}
+		}
+	}
+}
+
+// untyped code is never reported, whatever it does
+func TestUntypedCode(t *testing.T) {
+	inputs := []string{
+		`let x = 5; x = "five"; x + 1`,
+		`let f = fn(a, b) { a + b }; f(1); f("a", "b", "c")`,
+		`let x = 1; let g = fn() { x * 2 }; x = "s"; g()`,
+		`len(1); first("a"); 1 + "a"`,
+	}
+	for _, input := range inputs {
+		if diags := check(t, input); len(diags) != 0 {
+			t.Errorf("input %q: want no diagnostics, got %v", input, diags)
+		}
+	}
+}
+
+func TestInference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // type of the last let
+	}{
+		{"let x = 1 + 2;", "int"},
+		{`let x = "a" + "b";`, "str"},
+		{"let x = [1, 2];", "[int]"},
+		{`let x = [1, "a"];`, "[any]"},
+		{`let x = {"a": yes};`, "{str: bool}"},
+		{"let x = fn(a: int) { a * 2 };", "fn(int) -> int"},
+		{"let x = fn(a) { a };", "fn(any) -> any"},
+		{"let x = when (yes) { 1 } otherwise { 2 };", "int"},
+		{"let y = 1; y = 2; let x = y;", "any"}, // y is reassigned, so it is not known which value is read
+		{"let x = rest([1, 2]);", "[int]"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.NewLexer(tt.input)).ParseProgram()
+		c := newChecker(program)
+		c.check(program)
+		b, ok := c.scope.lookup("x")
+		if !ok {
+			t.Errorf("input %q: x not declared", tt.input)
+			continue
+		}
+		if got := b.typ.String(); got != tt.expected {
+			t.Errorf("input %q: want %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // every identifier as name:type, in source order
+	}{
+		{"let x = 1; x + len(x)", "x:int x:int len:fn(any) -> int x:int"},
+		{`let f = fn(a: str) { a + "!" }; f`, "f:fn(str) -> str a:str a:str f:fn(str) -> str"},
+		{"for (c in [yes]) { c }; try { 1 } catch (e) { e }", "c:bool c:bool e:any e:any"},
+		{"let y = 1; y = 2; y", "y:any y:any y:any"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.NewLexer(tt.input)).ParseProgram()
+		types := Types(program)
+		var have []string
+		ast.Inspect(program, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Identifier); ok {
+				have = append(have, id.Value+":"+types[id].String())
+			}
+			return true
+		})
+		if got := strings.Join(have, " "); got != tt.expected {
+			t.Errorf("input %q:\nwant %s\nhave %s", tt.input, tt.expected, got)
+		}
+	}
+}
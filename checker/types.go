@@ -0,0 +1,109 @@
+package checker
+
+import (
+	"monkey/ast"
+	"strings"
+)
+
+// Type what the checker knows about a value, any when it knows nothing
+// the composite ones are written the same way as annotations: [int], {str: int}, fn(int) -> str
+type Type struct {
+	Name   string  // int, bool, str, null, any, or array, hash and fn for the composite ones
+	Params []*Type // the element of an array, key and value of a hash, parameters of a fn
+	Result *Type   // fn only
+
+	// fn only: at least one parameter or the result was annotated, so calls are held to the signature
+	annotated bool
+}
+
+var (
+	Int  = &Type{Name: "int"}
+	Bool = &Type{Name: "bool"}
+	Str  = &Type{Name: "str"}
+	Null = &Type{Name: "null"}
+	Any  = &Type{Name: "any"}
+)
+
+// names an annotation may use for the simple types
+var named = map[string]*Type{"int": Int, "bool": Bool, "str": Str, "null": Null, "any": Any}
+
+func ArrayOf(elem *Type) *Type      { return &Type{Name: "array", Params: []*Type{elem}} }
+func HashOf(key, value *Type) *Type { return &Type{Name: "hash", Params: []*Type{key, value}} }
+func FuncOf(result *Type, params ...*Type) *Type {
+	return &Type{Name: "fn", Params: params, Result: result}
+}
+
+func (t *Type) String() string {
+	switch t.Name {
+	case "array":
+		return "[" + t.Params[0].String() + "]"
+	case "hash":
+		return "{" + t.Params[0].String() + ": " + t.Params[1].String() + "}"
+	case "fn":
+		params := make([]string, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = p.String()
+		}
+		return "fn(" + strings.Join(params, ", ") + ") -> " + t.Result.String()
+	}
+	return t.Name
+}
+
+// assignable whether a value of type from may go where to is expected, any goes both ways
+func assignable(to, from *Type) bool {
+	if to.Name == "any" || from.Name == "any" {
+		return true
+	}
+	if to.Name != from.Name || len(to.Params) != len(from.Params) {
+		return false
+	}
+	for i := range to.Params {
+		if !assignable(to.Params[i], from.Params[i]) {
+			return false
+		}
+	}
+	if to.Name == "fn" {
+		return assignable(to.Result, from.Result)
+	}
+	return true
+}
+
+// join the type of a value that is either a or b, any unless they agree
+// nil stands for no value yet, so join(nil, t) is t
+func join(a, b *Type) *Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.String() == b.String():
+		return a
+	}
+	return Any
+}
+
+// turns an annotation into a Type, unknown names are reported and become any
+func (c *checker) typeOf(annotation *ast.TypeExpression) *Type {
+	if annotation == nil {
+		return Any
+	}
+	switch annotation.Name {
+	case "array":
+		return ArrayOf(c.typeOf(annotation.Params[0]))
+	case "hash":
+		return HashOf(c.typeOf(annotation.Params[0]), c.typeOf(annotation.Params[1]))
+	case "fn":
+		params := make([]*Type, len(annotation.Params))
+		for i, p := range annotation.Params {
+			params[i] = c.typeOf(p)
+		}
+		fn := FuncOf(c.typeOf(annotation.Result), params...)
+		fn.annotated = true
+		return fn
+	}
+	if t, ok := named[annotation.Name]; ok {
+		return t
+	}
+	c.errorf(annotation.Token.Pos, "unknown type: %s", annotation.Name)
+	return Any
+}
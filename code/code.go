@@ -0,0 +1,235 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions bytecode of a function or of the program, every instruction is an opcode followed by its operands
+type Instructions []byte
+
+// Opcode first byte of an instruction, says what it does and how many operands follow
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota // push constants[operand]
+	OpPop                    // drop the top of the stack
+	OpTrue
+	OpFalse
+	OpNull
+
+	// binary operators pop the right operand, then the left one, and push the result
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpPow
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpLessEqual
+	OpGreaterEqual
+
+	// prefix operators replace the top of the stack
+	OpMinus
+	OpBang
+	OpBitNot
+
+	OpJump          // jump to the operand
+	OpJumpNotTruthy // pop, jump to the operand unless it was truthy
+
+	// bindings, a let sets them and an assignment (its second operand is 0 for = or the operator of +=, -=, ...)
+	// changes one that has to exist already
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	// locals a closure captures live in a cell, so the closure and the function see the same binding
+	OpNewCell  // the local starts out as an empty cell
+	OpBoxParam // the parameter's value is moved into a cell
+	OpGetCell
+	OpSetCell
+	OpAssignCell
+	OpGetFree
+	OpAssignFree
+	OpLoadCell // push the cell of a local itself, for OpClosure to capture
+	OpLoadFree // push the cell of a free variable itself
+
+	OpArray    // pop operand elements into an array
+	OpHash     // pop operand keys and values, alternating, into a hash
+	OpIndex    // pop index and left, push left[index]
+	OpSetIndex // pop index, left and value, left[index] = value, the operand is the operator as for assignments
+
+	OpCall        // call the function below operand arguments
+	OpTailCall    // call it in place of the current function, as if that returned what the call does
+	OpReturnValue // return the top of the stack, from the program too
+	OpClosure     // turn constants[first operand] into a closure over the second operand cells on the stack
+
+	OpIter     // replace the top of the stack with an iterator over it
+	OpIterNext // push the next item of the iterator on top, or jump to the operand once there are none left
+
+	OpThrow  // pop a value and fail with it
+	OpTry    // errors until the matching OpEndTry jump to the operand, with the caught error on the stack
+	OpEndTry // the try block finished without an error
+)
+
+// Definition how an opcode is printed and how wide its operands are, in bytes
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:      {"OpConstant", []int{2}},
+	OpPop:           {"OpPop", []int{}},
+	OpTrue:          {"OpTrue", []int{}},
+	OpFalse:         {"OpFalse", []int{}},
+	OpNull:          {"OpNull", []int{}},
+	OpAdd:           {"OpAdd", []int{}},
+	OpSub:           {"OpSub", []int{}},
+	OpMul:           {"OpMul", []int{}},
+	OpDiv:           {"OpDiv", []int{}},
+	OpMod:           {"OpMod", []int{}},
+	OpPow:           {"OpPow", []int{}},
+	OpBitAnd:        {"OpBitAnd", []int{}},
+	OpBitOr:         {"OpBitOr", []int{}},
+	OpBitXor:        {"OpBitXor", []int{}},
+	OpShiftLeft:     {"OpShiftLeft", []int{}},
+	OpShiftRight:    {"OpShiftRight", []int{}},
+	OpEqual:         {"OpEqual", []int{}},
+	OpNotEqual:      {"OpNotEqual", []int{}},
+	OpLessThan:      {"OpLessThan", []int{}},
+	OpGreaterThan:   {"OpGreaterThan", []int{}},
+	OpLessEqual:     {"OpLessEqual", []int{}},
+	OpGreaterEqual:  {"OpGreaterEqual", []int{}},
+	OpMinus:         {"OpMinus", []int{}},
+	OpBang:          {"OpBang", []int{}},
+	OpBitNot:        {"OpBitNot", []int{}},
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpAssignGlobal:  {"OpAssignGlobal", []int{2, 1}},
+	OpGetLocal:      {"OpGetLocal", []int{2}},
+	OpSetLocal:      {"OpSetLocal", []int{2}},
+	OpAssignLocal:   {"OpAssignLocal", []int{2, 1}},
+	OpNewCell:       {"OpNewCell", []int{2}},
+	OpBoxParam:      {"OpBoxParam", []int{2}},
+	OpGetCell:       {"OpGetCell", []int{2}},
+	OpSetCell:       {"OpSetCell", []int{2}},
+	OpAssignCell:    {"OpAssignCell", []int{2, 1}},
+	OpGetFree:       {"OpGetFree", []int{1}},
+	OpAssignFree:    {"OpAssignFree", []int{1, 1}},
+	OpLoadCell:      {"OpLoadCell", []int{2}},
+	OpLoadFree:      {"OpLoadFree", []int{1}},
+	OpArray:         {"OpArray", []int{2}},
+	OpHash:          {"OpHash", []int{2}},
+	OpIndex:         {"OpIndex", []int{}},
+	OpSetIndex:      {"OpSetIndex", []int{1}},
+	OpCall:          {"OpCall", []int{1}},
+	OpTailCall:      {"OpTailCall", []int{1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpClosure:       {"OpClosure", []int{2, 1}},
+	OpIter:          {"OpIter", []int{}},
+	OpIterNext:      {"OpIterNext", []int{2}},
+	OpThrow:         {"OpThrow", []int{}},
+	OpTry:           {"OpTry", []int{2}},
+	OpEndTry:        {"OpEndTry", []int{}},
+}
+
+// Lookup the definition of op, an error for bytes that are not an opcode
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes one instruction, operands are big endian and as wide as the definition says
+// an unknown opcode gives an empty instruction
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += def.OperandWidths[i]
+	}
+	return instruction
+}
+
+// ReadOperands decodes the operands Make encoded, n is how many bytes they took
+func ReadOperands(def *Definition, ins Instructions) (operands []int, n int) {
+	operands = make([]int, len(def.OperandWidths))
+	for i, w := range def.OperandWidths {
+		switch w {
+		case 2:
+			operands[i] = int(ReadUint16(ins[n:]))
+		case 1:
+			operands[i] = int(ins[n])
+		}
+		n += w
+	}
+	return operands, n
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// String disassembles, one instruction per line prefixed with its offset
+//
+//	0000 OpConstant 1
+//	0003 OpAdd
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+	for i := 0; i < len(ins); {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, n := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, formatInstruction(def, operands))
+		i += 1 + n
+	}
+	return out.String()
+}
+
+func formatInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d", len(operands), len(def.OperandWidths))
+	}
+	out := def.Name
+	for _, o := range operands {
+		out += fmt.Sprintf(" %d", o)
+	}
+	return out
+}
@@ -0,0 +1,595 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+	"monkey/token"
+)
+
+// Bytecode what the vm runs: the top level of the program as a function without parameters, and the constants
+// its instructions refer to
+type Bytecode struct {
+	Main      *object.CompiledFunction
+	Constants []object.Object
+	Globals   []string // name of every global slot, globals are looked up by name when they were never set
+}
+
+// Compiler lowers a program to bytecode, one scope of instructions per function being compiled
+type Compiler struct {
+	constants []object.Object
+	symbols   *SymbolTable
+	scopes    []*compilationScope
+	pos       token.Position // where the node being compiled starts, recorded for every instruction emitted
+	tails     map[*ast.CallExpression]bool
+}
+
+// compilationScope the instructions of one function and the loops and try blocks open while compiling them
+type compilationScope struct {
+	instructions code.Instructions
+	positions    map[int]token.Position
+	loops        []*loop
+	tries        int // try blocks the next instruction is in
+}
+
+// loop where break and continue jump to, patched once the loop is compiled
+type loop struct {
+	breaks    []int
+	continues []int
+	tries     int // try blocks around the loop, a jump out of the ones inside has to close them
+}
+
+// operators with an instruction of their own
+var binaryOps = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+var prefixOps = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+func New() *Compiler {
+	return &Compiler{tails: make(map[*ast.CallExpression]bool)}
+}
+
+// Compile compiles program, the first error stops it
+func (c *Compiler) Compile(program *ast.Program) error {
+	c.symbols = NewSymbolTable(capturedNames(program))
+	c.enterScope()
+	// a program evaluates to its last statement like a function body, and a send ends it the same way
+	if err := c.compileValue(program.Statements); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// Bytecode the result of Compile
+func (c *Compiler) Bytecode() *Bytecode {
+	scope := c.scopes[0]
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: scope.instructions,
+			NumLocals:    len(c.symbols.fn.locals),
+			Positions:    scope.positions,
+			Locals:       c.symbols.fn.locals,
+		},
+		Constants: c.constants,
+		Globals:   c.symbols.globals,
+	}
+}
+
+func (c *Compiler) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("compile error at %v: %s", c.pos, fmt.Sprintf(format, a...))
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	if pos := ast.OperatorPos(node); pos.Line != 0 {
+		saved := c.pos
+		c.pos = pos
+		defer func() { c.pos = saved }()
+	}
+
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		if err := c.compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			if err := c.compile(stmt); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		return c.compileLet(node)
+	case *ast.AssignStatement:
+		return c.compileAssign(node)
+	case *ast.SendStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		return c.compileJumpOut(node)
+	case *ast.WhileStatement:
+		return c.compileWhile(node)
+	case *ast.ForStatement:
+		return c.compileFor(node)
+	case *ast.Identifier:
+		c.load(c.resolve(node.Value))
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.GroupedExpression:
+		return c.compile(node.Expression)
+	case *ast.PrefixExpression:
+		op, ok := prefixOps[node.Operator]
+		if !ok {
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.InfixExpression:
+		op, ok := binaryOps[node.Operator]
+		if !ok {
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+	case *ast.WhenExpression:
+		return c.compileWhen(node)
+	case *ast.TryExpression:
+		return c.compileTry(node)
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+	case *ast.CallExpression:
+		if err := c.compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.compile(arg); err != nil {
+				return err
+			}
+		}
+		if c.tails[node] {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for i, key := range node.Keys {
+			if err := c.compile(key); err != nil {
+				return err
+			}
+			if err := c.compile(node.Values[i]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, 2*len(node.Keys))
+	case *ast.IndexExpression:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	default:
+		return c.errorf("cannot compile %T", node)
+	}
+	return nil
+}
+
+// compiles statements that produce a value, the one of the last statement when that is an expression, null otherwise
+func (c *Compiler) compileValue(statements []ast.Statement) error {
+	for i, stmt := range statements {
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && i == len(statements)-1 {
+			return c.compile(es.Expression)
+		}
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpNull)
+	return nil
+}
+
+// let x = 5 evaluates 5 before declaring x, so a value mentioning x still sees the x from before
+func (c *Compiler) compileLet(node *ast.LetStatement) error {
+	if err := c.compile(node.Value); err != nil {
+		return err
+	}
+	name := node.Name.Value
+	if sym, ok := c.symbols.store[name]; ok && sym.declared && sym.Constant {
+		return c.errorf("cannot redeclare constant: %s", name)
+	}
+	sym := c.symbols.Define(name)
+	sym.Constant = node.IsConstant()
+
+	switch {
+	case sym.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, sym.Index)
+	case sym.Boxed:
+		c.emit(code.OpSetCell, sym.Index)
+	default:
+		c.emit(code.OpSetLocal, sym.Index)
+	}
+	return nil
+}
+
+// the value is evaluated first, then x += is applied to the current value of x, same as in the evaluator
+func (c *Compiler) compileAssign(node *ast.AssignStatement) error {
+	operator := 0
+	if node.Operator != "=" {
+		op, ok := binaryOps[node.Operator[:len(node.Operator)-1]]
+		if !ok {
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+		operator = int(op)
+	}
+	if err := c.compile(node.Value); err != nil {
+		return err
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		sym := c.resolve(target.Value)
+		if sym.Constant {
+			return c.errorf("cannot assign to constant: %s", target.Value)
+		}
+		switch {
+		case sym.Scope == GlobalScope:
+			c.emit(code.OpAssignGlobal, sym.Index, operator)
+		case sym.Scope == FreeScope:
+			c.emit(code.OpAssignFree, sym.Index, operator)
+		case sym.Boxed:
+			c.emit(code.OpAssignCell, sym.Index, operator)
+		default:
+			c.emit(code.OpAssignLocal, sym.Index, operator)
+		}
+	case *ast.IndexExpression:
+		if err := c.compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.compile(target.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex, operator)
+	default:
+		return c.errorf("cannot assign to %s", node.Target.ToString())
+	}
+	return nil
+}
+
+// the symbol name refers to here, a global when no scope declares it
+func (c *Compiler) resolve(name string) *Symbol {
+	if sym, ok := c.symbols.Resolve(name); ok {
+		return sym
+	}
+	return c.symbols.global(name)
+}
+
+// pushes the value of sym
+func (c *Compiler) load(sym *Symbol) {
+	switch {
+	case sym.Scope == GlobalScope:
+		c.emit(code.OpGetGlobal, sym.Index)
+	case sym.Scope == FreeScope:
+		c.emit(code.OpGetFree, sym.Index)
+	case sym.Boxed:
+		c.emit(code.OpGetCell, sym.Index)
+	default:
+		c.emit(code.OpGetLocal, sym.Index)
+	}
+}
+
+func (c *Compiler) compileWhen(node *ast.WhenExpression) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 0)
+	if err := c.compileValue(node.Consequence.Statements); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 0)
+
+	c.patch(jumpNotTruthy)
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileValue(node.Alternative.Statements); err != nil {
+		return err
+	}
+	c.patch(jump)
+	return nil
+}
+
+// OpTry handler
+// block
+// OpEndTry
+// OpJump end
+// handler: the caught error is on the stack
+// end:
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	try := c.emit(code.OpTry, 0)
+	c.scope().tries++
+	if err := c.compileValue(node.Block.Statements); err != nil {
+		return err
+	}
+	c.scope().tries--
+	c.emit(code.OpEndTry)
+	jump := c.emit(code.OpJump, 0)
+
+	c.patch(try)
+	if node.Param == nil {
+		c.emit(code.OpPop)
+	}
+	err := c.inBlock(node.Handler, node.Param, func() error {
+		return c.compileValue(node.Handler.Statements)
+	})
+	if err != nil {
+		return err
+	}
+	c.patch(jump)
+	return nil
+}
+
+// start: condition
+// OpJumpNotTruthy end
+// body
+// OpJump start
+// end:
+func (c *Compiler) compileWhile(node *ast.WhileStatement) error {
+	start := len(c.scope().instructions)
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+	exit := c.emit(code.OpJumpNotTruthy, 0)
+
+	l, err := c.compileLoopBody(node.Body, nil)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	c.patch(exit)
+	c.patchAll(l.breaks, len(c.scope().instructions))
+	c.patchAll(l.continues, start)
+	return nil
+}
+
+// iterable
+// OpIter
+// start: OpIterNext end
+// body, with the item bound to the variable
+// OpJump start
+// end: OpPop, the iterator
+func (c *Compiler) compileFor(node *ast.ForStatement) error {
+	if err := c.compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	start := len(c.scope().instructions)
+	next := c.emit(code.OpIterNext, 0)
+
+	l, err := c.compileLoopBody(node.Body, node.Variable)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	c.patch(next)
+	c.patchAll(l.breaks, len(c.scope().instructions))
+	c.patchAll(l.continues, start)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// every iteration runs in a scope of its own, so closures created in it capture their own cells
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, variable *ast.Identifier) (*loop, error) {
+	scope := c.scope()
+	l := &loop{tries: scope.tries}
+	scope.loops = append(scope.loops, l)
+	defer func() { scope.loops = scope.loops[:len(scope.loops)-1] }()
+
+	err := c.inBlock(body, variable, func() error { return c.compile(body) })
+	return l, err
+}
+
+// break and continue close the try blocks they jump out of
+func (c *Compiler) compileJumpOut(node ast.Node) error {
+	scope := c.scope()
+	if len(scope.loops) == 0 {
+		return c.errorf("%s outside of loop", node.TokenLiteral())
+	}
+	l := scope.loops[len(scope.loops)-1]
+	for i := l.tries; i < scope.tries; i++ {
+		c.emit(code.OpEndTry)
+	}
+
+	jump := c.emit(code.OpJump, 0)
+	if _, ok := node.(*ast.BreakStatement); ok {
+		l.breaks = append(l.breaks, jump)
+	} else {
+		l.continues = append(l.continues, jump)
+	}
+	return nil
+}
+
+// compiles a loop body or catch handler in a scope of its own, bound (the loop variable or the caught error)
+// is popped off the stack into it first, when there is one
+func (c *Compiler) inBlock(body *ast.BlockStatement, bound *ast.Identifier, compile func() error) error {
+	c.symbols = newBlockTable(c.symbols)
+	defer func() { c.symbols = c.symbols.Outer }()
+
+	first := len(c.symbols.fn.locals)
+	var sym *Symbol
+	if bound != nil {
+		sym = c.symbols.Define(bound.Value)
+	}
+	for _, name := range declaredNames(body) {
+		c.symbols.hoist(name)
+	}
+	c.newCells(first, 0)
+
+	switch {
+	case sym == nil:
+	case sym.Boxed:
+		c.emit(code.OpSetCell, sym.Index)
+	default:
+		c.emit(code.OpSetLocal, sym.Index)
+	}
+	return compile()
+}
+
+// gives the boxed locals of the current scope from slot first on their cells as the scope is entered,
+// the first params of them are parameters whose value goes into the cell
+func (c *Compiler) newCells(first, params int) {
+	locals := c.symbols.fn.locals
+	for i := first; i < len(locals); i++ {
+		sym := c.symbols.store[locals[i]]
+		switch {
+		case sym == nil || sym.Index != i || !sym.Boxed:
+			// a parameter hidden by a later one of the same name, nothing can refer to it
+		case i < params:
+			c.emit(code.OpBoxParam, i)
+		default:
+			c.emit(code.OpNewCell, i)
+		}
+	}
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	c.symbols = newFunctionTable(c.symbols, capturedNames(node.Body))
+	c.enterScope()
+
+	for _, param := range node.Parameters {
+		c.symbols.defineParam(param.Value)
+	}
+	for _, name := range declaredNames(node.Body) {
+		c.symbols.hoist(name)
+	}
+	c.newCells(0, len(node.Parameters))
+	for _, tc := range ast.TailCalls(node.Body) {
+		c.tails[tc] = true
+	}
+	if err := c.compileValue(node.Body.Statements); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	scope, fn := c.leaveScope(), c.symbols.fn
+	c.symbols = c.symbols.Outer
+
+	compiled := &object.CompiledFunction{
+		Instructions:  scope.instructions,
+		NumLocals:     len(fn.locals),
+		NumParameters: len(node.Parameters),
+		Name:          node.Name,
+		Positions:     scope.positions,
+		Locals:        fn.locals,
+	}
+	for _, free := range fn.free {
+		compiled.Free = append(compiled.Free, free.Name)
+		switch original := free.original; {
+		case original.Scope == FreeScope:
+			c.emit(code.OpLoadFree, original.Index)
+		case original.Boxed:
+			c.emit(code.OpLoadCell, original.Index)
+		default:
+			return c.errorf("%s is captured but has no cell", free.Name)
+		}
+	}
+	c.emit(code.OpClosure, c.addConstant(compiled), len(fn.free))
+	return nil
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) scope() *compilationScope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, &compilationScope{positions: make(map[int]token.Position)})
+}
+
+func (c *Compiler) leaveScope() *compilationScope {
+	scope := c.scope()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	return scope
+}
+
+// emit appends an instruction and returns its offset
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := c.scope()
+	offset := len(scope.instructions)
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+	if c.pos.Line != 0 {
+		scope.positions[offset] = c.pos
+	}
+	return offset
+}
+
+// points the jump at offset to the next instruction
+func (c *Compiler) patch(offset int) {
+	c.patchAll([]int{offset}, len(c.scope().instructions))
+}
+
+// rewrites the first operand of the jumps at offsets to target
+func (c *Compiler) patchAll(offsets []int, target int) {
+	ins := c.scope().instructions
+	for _, offset := range offsets {
+		op := code.Opcode(ins[offset])
+		copy(ins[offset:], code.Make(op, target))
+	}
+}
@@ -0,0 +1,160 @@
+package compiler
+
+import (
+	"monkey/code"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func compile(t *testing.T, input string) (*Bytecode, error) {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	c := New()
+	if err := c.Compile(program); err != nil {
+		return nil, err
+	}
+	return c.Bytecode(), nil
+}
+
+func concat(instructions ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected code.Instructions
+	}{
+		{"1 + 2", concat(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpAdd),
+			code.Make(code.OpReturnValue),
+		)},
+		{"let x = 1; x += 2; x", concat(
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpAssignGlobal, 0, int(code.OpAdd)),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpReturnValue),
+		)},
+		{"when (yes) { 1 }; 2", concat(
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 10),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpNull),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpReturnValue),
+		)},
+		// the loop body is a scope of its own, y is a local of the program's frame
+		{"while (no) { let y = 1; break }", concat(
+			code.Make(code.OpFalse),
+			code.Make(code.OpJumpNotTruthy, 16),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetLocal, 0),
+			code.Make(code.OpJump, 16),
+			code.Make(code.OpJump, 0),
+			code.Make(code.OpNull),
+			code.Make(code.OpReturnValue),
+		)},
+		{`try { throw "x" } catch { 1 }`, concat(
+			code.Make(code.OpTry, 12),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpThrow),
+			code.Make(code.OpNull),
+			code.Make(code.OpEndTry),
+			code.Make(code.OpJump, 16),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpReturnValue),
+		)},
+	}
+
+	for _, tt := range tests {
+		bytecode, err := compile(t, tt.input)
+		if err != nil {
+			t.Fatalf("input %q: %v", tt.input, err)
+		}
+		if have := bytecode.Main.Instructions.String(); have != tt.expected.String() {
+			t.Errorf("input %q: wrong instructions\nwant\n%s\nhave\n%s", tt.input, tt.expected, have)
+		}
+	}
+}
+
+// only locals some closure captures are put in cells, the closure gets the cell rather than the value
+func TestClosures(t *testing.T) {
+	input := "let f = fn(a, b) { let c = a; fn() { a + c } }"
+	bytecode, err := compile(t, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outer := bytecode.Constants[1].(*object.CompiledFunction)
+	expected := concat(
+		code.Make(code.OpBoxParam, 0),
+		code.Make(code.OpNewCell, 2),
+		code.Make(code.OpGetCell, 0),
+		code.Make(code.OpSetCell, 2),
+		code.Make(code.OpLoadCell, 0),
+		code.Make(code.OpLoadCell, 2),
+		code.Make(code.OpClosure, 0, 2),
+		code.Make(code.OpReturnValue),
+	)
+	if outer.Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions\nwant\n%s\nhave\n%s", expected, outer.Instructions)
+	}
+	if outer.Name != "f" || outer.NumParameters != 2 || outer.NumLocals != 3 {
+		t.Errorf("wrong function: %s with %d locals", outer.Inspect(), outer.NumLocals)
+	}
+
+	inner := bytecode.Constants[0].(*object.CompiledFunction)
+	expected = concat(
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpGetFree, 1),
+		code.Make(code.OpAdd),
+		code.Make(code.OpReturnValue),
+	)
+	if inner.Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions\nwant\n%s\nhave\n%s", expected, inner.Instructions)
+	}
+	if strings.Join(inner.Free, ", ") != "a, c" {
+		t.Errorf("wrong free variables: %v", inner.Free)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = 1; x = 2", "compile error at 1:16: cannot assign to constant: x"},
+		{"const x = 1; let x = 2", "compile error at 1:14: cannot redeclare constant: x"},
+		{"let f = fn() { const y = 1; fn() { y += 1 } }", "compile error at 1:38: cannot assign to constant: y"},
+	}
+
+	for _, tt := range tests {
+		_, err := compile(t, tt.input)
+		if err == nil {
+			t.Errorf("input %q: no error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("input %q: want %q, have %q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package compiler
+
+import "monkey/ast"
+
+// what a scope has to know before its code is compiled: which names it declares further down,
+// and which names the closures inside it use
+
+// names the lets directly in a scope declare, the ones in when and try blocks included since those share it
+// loop bodies, catch handlers and functions declare into scopes of their own
+func declaredNames(body ast.Node) []string {
+	var names []string
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			names = append(names, node.Name.Value)
+		case *ast.WhileStatement:
+			names = append(names, declaredNames(node.Condition)...)
+			return false
+		case *ast.ForStatement:
+			names = append(names, declaredNames(node.Iterable)...)
+			return false
+		case *ast.TryExpression:
+			names = append(names, declaredNames(node.Block)...)
+			return false
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return true
+	})
+	return names
+}
+
+// names used anywhere inside the functions nested in body, over-approximates what their closures capture
+func capturedNames(body ast.Node) map[string]bool {
+	captured := make(map[string]bool)
+	ast.Inspect(body, func(node ast.Node) bool {
+		if fl, ok := node.(*ast.FunctionLiteral); ok {
+			ast.Inspect(fl.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Identifier); ok {
+					captured[id.Value] = true
+				}
+				return true
+			})
+			return false
+		}
+		return true
+	})
+	return captured
+}
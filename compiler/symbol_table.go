@@ -0,0 +1,149 @@
+package compiler
+
+// SymbolScope where the value of a symbol lives at runtime
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL" // a slot of the vm's globals, found by name when nothing closer declares it
+	LocalScope  SymbolScope = "LOCAL"  // a slot of the frame of the function (or the program) it belongs to
+	FreeScope   SymbolScope = "FREE"   // a cell a closure captured from a function around it
+)
+
+// Symbol what an identifier compiles to
+type Symbol struct {
+	Name     string
+	Scope    SymbolScope
+	Index    int
+	Constant bool
+	// a local some closure captures, its slot holds a cell instead of the value
+	Boxed bool
+
+	// the let declaring it has been compiled, until then only code running later (closures) may see it
+	declared bool
+	// free only: the symbol of the enclosing function it was captured from
+	original *Symbol
+}
+
+// SymbolTable one scope, shaped like the environments of the evaluator: the program, every function body,
+// every loop body and every catch handler get one, when and try blocks share the one around them
+type SymbolTable struct {
+	Outer *SymbolTable
+	store map[string]*Symbol
+	fn    *function // the function whose frame holds the locals of this scope, the program for the top level
+	// a function body, names resolved past it become free variables
+	boundary bool
+
+	globals []string // top level only: name of every global slot
+}
+
+// function what the frame of one function (or of the program) needs to know about its locals
+type function struct {
+	locals     []string // name of every slot
+	free       []*Symbol
+	freeByName map[string]*Symbol
+	captured   map[string]bool // names used by closures inside, locals with one of these names get a cell
+}
+
+func newFunction(captured map[string]bool) *function {
+	return &function{freeByName: make(map[string]*Symbol), captured: captured}
+}
+
+// NewSymbolTable top level scope of a program, captured are the names closures in it use
+func NewSymbolTable(captured map[string]bool) *SymbolTable {
+	return &SymbolTable{store: make(map[string]*Symbol), fn: newFunction(captured)}
+}
+
+// scope of a function body on top of outer, the function gets its own frame
+func newFunctionTable(outer *SymbolTable, captured map[string]bool) *SymbolTable {
+	return &SymbolTable{Outer: outer, store: make(map[string]*Symbol), fn: newFunction(captured), boundary: true}
+}
+
+// scope of a loop body or catch handler, its locals go in the frame of the function around it
+func newBlockTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{Outer: outer, store: make(map[string]*Symbol), fn: outer.fn}
+}
+
+// Define declares name in this scope, a name the scope already has keeps its slot like env.Set keeps its entry
+func (s *SymbolTable) Define(name string) *Symbol {
+	sym := s.hoist(name)
+	sym.declared = true
+	return sym
+}
+
+// hoist gives name a slot in this scope ahead of its let, so closures created before the let can capture it
+func (s *SymbolTable) hoist(name string) *Symbol {
+	if sym, ok := s.store[name]; ok {
+		return sym
+	}
+	return s.add(name)
+}
+
+// defineParam always takes a new slot, parameters are the first slots of the frame in order
+// of two parameters with the same name the last one wins, as in the evaluator
+func (s *SymbolTable) defineParam(name string) *Symbol {
+	sym := s.add(name)
+	sym.declared = true
+	return sym
+}
+
+func (s *SymbolTable) add(name string) *Symbol {
+	sym := &Symbol{Name: name}
+	if s.Outer == nil {
+		sym.Scope, sym.Index, sym.declared = GlobalScope, len(s.globals), true
+		s.globals = append(s.globals, name)
+	} else {
+		sym.Scope, sym.Index, sym.Boxed = LocalScope, len(s.fn.locals), s.fn.captured[name]
+		s.fn.locals = append(s.fn.locals, name)
+	}
+	s.store[name] = sym
+	return sym
+}
+
+// Resolve finds what name refers to here, ok is false when no scope declares it
+// that is left to the globals, which are looked up by name at runtime and fall back to the builtins
+func (s *SymbolTable) Resolve(name string) (*Symbol, bool) {
+	return s.resolve(name, false)
+}
+
+// later: the reference runs after the scope is set up rather than right now, so it also sees the hoisted names
+func (s *SymbolTable) resolve(name string, later bool) (*Symbol, bool) {
+	if sym, ok := s.store[name]; ok && (sym.declared || later) {
+		return sym, true
+	}
+	if s.boundary {
+		if sym, ok := s.fn.freeByName[name]; ok {
+			return sym, true
+		}
+	}
+	if s.Outer == nil {
+		return nil, false
+	}
+
+	sym, ok := s.Outer.resolve(name, later || s.boundary)
+	if !ok || sym.Scope == GlobalScope || !s.boundary {
+		return sym, ok
+	}
+	return s.fn.capture(sym), true
+}
+
+func (f *function) capture(original *Symbol) *Symbol {
+	sym := &Symbol{
+		Name:     original.Name,
+		Scope:    FreeScope,
+		Index:    len(f.free),
+		Constant: original.Constant,
+		declared: true,
+		original: original,
+	}
+	f.free = append(f.free, sym)
+	f.freeByName[sym.Name] = sym
+	return sym
+}
+
+// global the global slot of name, taking a new one for a name no program mentioned yet
+func (s *SymbolTable) global(name string) *Symbol {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s.hoist(name)
+}
@@ -0,0 +1,246 @@
+// Package cst is the concrete syntax tree: every token of the source with the whitespace and comments around it,
+// grouped into nodes that follow the ast, so the source can be printed back byte for byte and edited in place
+package cst
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+// TriviaKind what a piece of trivia is
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota // spaces, tabs and carriage returns
+	Newline                      // a single \n
+	Comment                      // // up to the end of the line, the newline not included
+)
+
+// Trivia source text between tokens that the parser never sees
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
+// Token a token with its spelling and the trivia around it
+// trivia after a token up to and including the end of its line is the token's Trailing,
+// everything else before the next token is that token's Leading, so a comment on a line of its own belongs to what follows
+type Token struct {
+	token.Token
+	Text     string // exactly as in the source, Literal has the escapes of a string resolved
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// Element a child of a Node, either a *Node or a *Token
+type Element interface {
+	element()
+}
+
+// Node the tokens of one ast node, with the nodes of its children in between
+// the tokens of a node are its keywords and punctuation, a statement's semicolon included
+type Node struct {
+	Kind     string   // the ast type, like LetStatement, the same as the kind of its JSON
+	AST      ast.Node // what the node was built from; edits do not change it, ToAST parses them again
+	Children []Element
+}
+
+func (t *Token) element() {}
+func (n *Node) element()  {}
+
+// Parse builds the tree for src, the errors are the ones the parser reported
+// the tree is complete either way, a statement that failed to parse just has more of its tokens left to the node around it
+func Parse(src string) (*Node, []error) {
+	tokens := Scan(src)
+	program := parser.New(lexer.NewLexer(src)).ParseProgram()
+
+	b := &builder{tokens: tokens}
+	root := b.build(program, program.End().Offset)
+	// trivia at the end of the source is the leading trivia of EOF
+	for ; b.next < len(b.tokens); b.next++ {
+		root.Children = append(root.Children, b.tokens[b.next])
+	}
+
+	var errs []error
+	for _, err := range program.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return root, errs
+}
+
+// String the source of the node, trivia included
+func (n *Node) String() string {
+	var out strings.Builder
+	for _, tok := range n.Tokens() {
+		for _, t := range tok.Leading {
+			out.WriteString(t.Text)
+		}
+		out.WriteString(tok.Text)
+		for _, t := range tok.Trailing {
+			out.WriteString(t.Text)
+		}
+	}
+	return out.String()
+}
+
+// Tokens every token under the node in source order
+func (n *Node) Tokens() []*Token {
+	var out []*Token
+	for _, child := range n.Children {
+		switch child := child.(type) {
+		case *Token:
+			out = append(out, child)
+		case *Node:
+			out = append(out, child.Tokens()...)
+		}
+	}
+	return out
+}
+
+// Find the node built from node, nil when there is none under n
+func (n *Node) Find(node ast.Node) *Node {
+	if n.AST == node {
+		return n
+	}
+	for _, child := range n.Children {
+		if child, ok := child.(*Node); ok {
+			if found := child.Find(node); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// ToAST parses what root prints, so edits to the tokens show up in the tree and its positions
+func ToAST(root *Node) *ast.Program {
+	return parser.New(lexer.NewLexer(root.String())).ParseProgram()
+}
+
+// Scan lexes src down to EOF and hands the text between tokens out as trivia, without parsing it
+// the tokens and their trivia cover src exactly, however broken it is
+func Scan(src string) []*Token {
+	var tokens []*Token
+	lex := lexer.NewLexer(src)
+	end := 0
+	for {
+		tok := lex.NextToken()
+		leading := trivia(src[end:tok.Pos.Offset])
+		if len(tokens) > 0 {
+			prev := tokens[len(tokens)-1]
+			for len(leading) > 0 {
+				prev.Trailing = append(prev.Trailing, leading[0])
+				leading = leading[1:]
+				if prev.Trailing[len(prev.Trailing)-1].Kind == Newline {
+					break
+				}
+			}
+		}
+		// the lexer takes a NUL byte for the end, what follows it is kept as one illegal token
+		if tok.Type == token.EOF && tok.Pos.Offset < len(src) {
+			rest := src[tok.Pos.Offset:]
+			after := advance(tok.Pos, rest)
+			illegal := token.Token{Type: token.ILLEGAL, Literal: rest, Reason: "NUL byte, nothing after it is read", Pos: tok.Pos, End: after}
+			return append(tokens,
+				&Token{Token: illegal, Text: rest, Leading: leading},
+				&Token{Token: token.Token{Type: token.EOF, Pos: after, End: after}})
+		}
+		tokens = append(tokens, &Token{Token: tok, Text: src[tok.Pos.Offset:tok.End.Offset], Leading: leading})
+		end = tok.End.Offset
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// the position right after text, when text starts at pos
+func advance(pos token.Position, text string) token.Position {
+	for i := 0; i < len(text); i++ {
+		pos.Offset++
+		if text[i] == '\n' {
+			pos.Line, pos.Column = pos.Line+1, 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+// splits the text between two tokens, which the lexer has already made sure is only whitespace and comments
+func trivia(text string) []Trivia {
+	var out []Trivia
+	for len(text) > 0 {
+		n := 0
+		kind := Whitespace
+		switch {
+		case text[0] == '\n':
+			kind, n = Newline, 1
+		case strings.HasPrefix(text, "//"):
+			kind, n = Comment, strings.IndexByte(text, '\n')
+			if n < 0 {
+				n = len(text)
+			}
+		default:
+			for n < len(text) && text[n] != '\n' && !strings.HasPrefix(text[n:], "//") {
+				n++
+			}
+		}
+		out = append(out, Trivia{Kind: kind, Text: text[:n]})
+		text = text[n:]
+	}
+	return out
+}
+
+type builder struct {
+	tokens []*Token
+	next   int // the first token not in the tree yet
+}
+
+// the node for n, taking the tokens before end that its children do not take
+func (b *builder) build(n ast.Node, end int) *Node {
+	node := &Node{Kind: strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."), AST: n}
+	for _, child := range children(n) {
+		b.take(node, child.Pos().Offset)
+		built := b.build(child, child.End().Offset)
+		if _, ok := child.(ast.Statement); ok && b.peek(token.SEMICOLON) {
+			built.Children = append(built.Children, b.tokens[b.next])
+			b.next++
+		}
+		node.Children = append(node.Children, built)
+	}
+	b.take(node, end)
+	return node
+}
+
+// moves the tokens starting before offset into node, EOF stays for the root
+func (b *builder) take(node *Node, offset int) {
+	for b.next < len(b.tokens) && b.tokens[b.next].Pos.Offset < offset && b.tokens[b.next].Type != token.EOF {
+		node.Children = append(node.Children, b.tokens[b.next])
+		b.next++
+	}
+}
+
+func (b *builder) peek(tt token.TokenType) bool {
+	return b.next < len(b.tokens) && b.tokens[b.next].Type == tt
+}
+
+// the nodes directly under n, in source order
+func children(n ast.Node) []ast.Node {
+	var out []ast.Node
+	ast.Inspect(n, func(child ast.Node) bool {
+		if child == n {
+			return true
+		}
+		if child != nil {
+			out = append(out, child)
+		}
+		return false
+	})
+	return out
+}
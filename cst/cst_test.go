@@ -0,0 +1,141 @@
+package cst
+
+import (
+	"monkey/ast"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []string{
+		"",
+		"   \n\t",
+		"// only a comment",
+		"let x = 5;",
+		"let  x:[ int ]=  ( 1+2 ) *3 ;;\n",
+		"// header\n\nlet f = fn(a, b) { // trailing\n  send a + b; // more\n};\r\n\r\nf(1,\n  2)\n// the end\n",
+		"when (x) { \"a\\\"b\\n\" } otherwise\n{ [1, 2][0] }",
+		"for (x in {1: 2}) { break } while (no) { continue; }",
+		"try { throw 1 } catch (e) { e }   ",
+		// broken input has to come back just as well
+		"let = 5; let y = @@ 1;",
+		"let s = \"unterminated",
+		"fn(x) { x",
+		"a\x00b",
+		"let s = \"a\x00b\";\n// c\x00d\nlet t = 1\x00",
+	}
+
+	for _, src := range tests {
+		root, _ := Parse(src)
+		if have := root.String(); have != src {
+			t.Errorf("want %q, have %q", src, have)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	src := "// greeting\nlet x = 1; // one\n\n  x"
+	root, errs := Parse(src)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	tokens := root.Tokens()
+
+	tests := []struct {
+		text              string
+		leading, trailing string
+	}{
+		{"let", "// greeting|\n", " "},
+		{"x", "", " "},
+		{"1", "", ""},
+		{";", "", " |// one|\n"},
+		{"x", "\n|  ", ""},
+		{"", "", ""}, // EOF
+	}
+	have := []*Token{tokens[0], tokens[1], tokens[3], tokens[4], tokens[5], tokens[6]}
+	for i, tt := range tests {
+		tok := have[i]
+		if tok.Text != tt.text || join(tok.Leading) != tt.leading || join(tok.Trailing) != tt.trailing {
+			t.Errorf("tests[%d]: want %q %q %q, have %q %q %q",
+				i, tt.leading, tt.text, tt.trailing, join(tok.Leading), tok.Text, join(tok.Trailing))
+		}
+	}
+	if kinds := []TriviaKind{tokens[0].Leading[0].Kind, tokens[0].Leading[1].Kind, tokens[5].Leading[1].Kind}; kinds[0] != Comment || kinds[1] != Newline || kinds[2] != Whitespace {
+		t.Errorf("wrong trivia kinds %v", kinds)
+	}
+}
+
+func join(trivia []Trivia) string {
+	texts := make([]string, len(trivia))
+	for i, t := range trivia {
+		texts[i] = t.Text
+	}
+	return strings.Join(texts, "|")
+}
+
+// the shape of the tree, nodes as Kind(children), tokens as their text
+func shape(el Element) string {
+	switch el := el.(type) {
+	case *Token:
+		return el.Text
+	case *Node:
+		parts := make([]string, len(el.Children))
+		for i, child := range el.Children {
+			parts[i] = shape(child)
+		}
+		return el.Kind + "(" + strings.Join(parts, " ") + ")"
+	}
+	return ""
+}
+
+func TestStructure(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"let x = -(1);", "Program(LetStatement(let Identifier(x) = PrefixExpression(- GroupedExpression(( IntegerLiteral(1) ))) ;) )"},
+		{"f(a)[0] += 2", "Program(AssignStatement(IndexExpression(CallExpression(Identifier(f) ( Identifier(a) )) [ IntegerLiteral(0) ]) += IntegerLiteral(2)) )"},
+		{"when (yes) { 1; }", "Program(ExpressionStatement(WhenExpression(when ( Boolean(yes) ) BlockStatement({ ExpressionStatement(IntegerLiteral(1) ;) }))) )"},
+	}
+
+	for _, tt := range tests {
+		root, errs := Parse(tt.src)
+		if len(errs) != 0 {
+			t.Fatalf("src %q: %v", tt.src, errs)
+		}
+		if have := shape(root); have != tt.expected {
+			t.Errorf("src %q:\nwant %s\nhave %s", tt.src, tt.expected, have)
+		}
+	}
+}
+
+// renaming through the tree touches nothing but the name, and the ast derived afterwards sees it
+func TestEditAndDerive(t *testing.T) {
+	src := "let count = 1; // how many\ncount += 1;\nprint(count)"
+	root, _ := Parse(src)
+
+	program := root.AST.(*ast.Program)
+	var ids []*ast.Identifier
+	ast.Inspect(program, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Identifier); ok && id.Value == "count" {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	for _, id := range ids {
+		tok := root.Find(id).Tokens()[0]
+		tok.Text, tok.Literal = "total", "total"
+	}
+
+	expected := "let total = 1; // how many\ntotal += 1;\nprint(total)"
+	if have := root.String(); have != expected {
+		t.Fatalf("want %q, have %q", expected, have)
+	}
+	derived := ToAST(root)
+	if have := derived.ToString(); have != "let total = 1;total += 1;print print(total);" {
+		t.Errorf("derived ast %q", have)
+	}
+	if pos := derived.Statements[2].Pos(); pos.Line != 3 || pos.Column != 1 {
+		t.Errorf("derived positions are off: %v", pos)
+	}
+}
@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// shorthand for Builtin.Params
+var (
+	anyType    = []object.ObjectType{}
+	arrayType  = []object.ObjectType{object.ARRAY_OBJ}
+	intType    = []object.ObjectType{object.INTEGER_OBJ}
+	stringType = []object.ObjectType{object.STRING_OBJ}
+	sizedTypes = []object.ObjectType{object.STRING_OBJ, object.ARRAY_OBJ, object.HASH_OBJ}
+	intTypes   = []object.ObjectType{object.INTEGER_OBJ, object.STRING_OBJ, object.BOOLEAN_OBJ}
+	fnTypes    = []object.ObjectType{object.FUNCTION_OBJ, object.CLOSURE_OBJ, object.BUILTIN_OBJ}
+)
+
+// builtins every evaluator starts with, print is a method since it needs to know where to write
+func (e *Evaluator) defaultBuiltins() []*object.Builtin {
+	return []*object.Builtin{
+		{Name: "len", Params: [][]object.ObjectType{sizedTypes}, Fn: builtinLen},
+		{Name: "print", Params: [][]object.ObjectType{anyType}, Variadic: true, Requires: object.CapPrint, Fn: e.builtinPrint},
+		{Name: "puts", Params: [][]object.ObjectType{anyType}, Variadic: true, Requires: object.CapPrint, Fn: e.builtinPrint},
+		{Name: "first", Params: [][]object.ObjectType{arrayType}, Fn: builtinFirst},
+		{Name: "last", Params: [][]object.ObjectType{arrayType}, Fn: builtinLast},
+		{Name: "rest", Params: [][]object.ObjectType{arrayType}, Fn: builtinRest},
+		{Name: "push", Params: [][]object.ObjectType{arrayType, anyType}, Fn: e.builtinPush},
+		{Name: "type", Params: [][]object.ObjectType{anyType}, Fn: builtinType},
+		{Name: "str", Params: [][]object.ObjectType{anyType}, Fn: builtinStr},
+		{Name: "int", Params: [][]object.ObjectType{intTypes}, Fn: builtinInt},
+		{Name: "read_file", Params: [][]object.ObjectType{stringType}, Requires: object.CapIO, Fn: builtinReadFile},
+		{Name: "write_file", Params: [][]object.ObjectType{stringType, stringType}, Requires: object.CapIO, Fn: builtinWriteFile},
+		{Name: "now", Requires: object.CapTime, Fn: builtinNow},
+		{Name: "sleep", Params: [][]object.ObjectType{intType}, Requires: object.CapTime, Fn: e.builtinSleep},
+		{Name: "rand", Params: [][]object.ObjectType{intType}, Requires: object.CapRandom, Fn: builtinRand},
+		{Name: "assert", Params: [][]object.ObjectType{anyType, stringType}, Variadic: true, Fn: builtinAssert},
+		{Name: "assert_eq", Params: [][]object.ObjectType{anyType, anyType, stringType}, Variadic: true, Fn: builtinAssertEq},
+		{Name: "assert_error", Params: [][]object.ObjectType{fnTypes, stringType}, Variadic: true, Fn: e.builtinAssertError},
+	}
+}
+
+// len counts characters of a string, not bytes, same as for-in does
+func builtinLen(args ...object.Object) object.Object {
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: utf8.RuneCountInString(arg.Value)}
+	case *object.Array:
+		return &object.Integer{Value: len(arg.Elements)}
+	default:
+		return &object.Integer{Value: len(arg.(*object.Hash).Pairs)}
+	}
+}
+
+// print(a, b) writes "a b" and a newline, strings without their quotes
+func (e *Evaluator) builtinPrint(args ...object.Object) object.Object {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = toString(arg)
+	}
+	fmt.Fprintln(e.Out, strings.Join(parts, " "))
+	return NULL
+}
+
+func builtinFirst(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return NULL
+	}
+	return elements[0]
+}
+
+func builtinLast(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return NULL
+	}
+	return elements[len(elements)-1]
+}
+
+// rest everything but the first element, as a new array
+func builtinRest(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	if len(elements) == 0 {
+		return NULL
+	}
+	rest := make([]object.Object, len(elements)-1)
+	copy(rest, elements[1:])
+	return &object.Array{Elements: rest}
+}
+
+// push returns a new array, the one passed in stays as it was
+func (e *Evaluator) builtinPush(args ...object.Object) object.Object {
+	elements := args[0].(*object.Array).Elements
+	if err := e.CheckLength(object.ARRAY_OBJ, len(elements)+1); err != nil {
+		return err
+	}
+	pushed := make([]object.Object, len(elements), len(elements)+1)
+	copy(pushed, elements)
+	return &object.Array{Elements: append(pushed, args[1])}
+}
+
+// names type() gives back, the same ones a script would write
+var typeNames = map[object.ObjectType]string{
+	object.INTEGER_OBJ:     "int",
+	object.BOOLEAN_OBJ:     "bool",
+	object.STRING_OBJ:      "str",
+	object.ARRAY_OBJ:       "array",
+	object.HASH_OBJ:        "hash",
+	object.FUNCTION_OBJ:    "fn",
+	object.BUILTIN_OBJ:     "fn",
+	object.CLOSURE_OBJ:     "fn",
+	object.NULL_OBJ:        "null",
+	object.ERROR_VALUE_OBJ: "error",
+}
+
+func builtinType(args ...object.Object) object.Object {
+	name, ok := typeNames[args[0].Type()]
+	if !ok {
+		name = strings.ToLower(string(args[0].Type()))
+	}
+	return &object.String{Value: name}
+}
+
+func builtinStr(args ...object.Object) object.Object {
+	return &object.String{Value: toString(args[0])}
+}
+
+func builtinInt(args ...object.Object) object.Object {
+	switch arg := args[0].(type) {
+	case *object.String:
+		value, err := strconv.Atoi(strings.TrimSpace(arg.Value))
+		if err != nil {
+			return newError("int: cannot convert %q to an integer", arg.Value)
+		}
+		return &object.Integer{Value: value}
+	case *object.Boolean:
+		if arg.Value {
+			return &object.Integer{Value: 1}
+		}
+		return &object.Integer{Value: 0}
+	default:
+		return arg
+	}
+}
+
+// strings as they are, everything else the way the REPL shows it
+func toString(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
+}
+
+// checks the arguments against what the builtin declared, so its Fn can assume they are right
+func checkBuiltinArgs(builtin *object.Builtin, args []object.Object) *object.Error {
+	params := builtin.Params
+	if builtin.Variadic {
+		if len(args) < len(params)-1 {
+			return newError("wrong number of arguments to %s: want at least %d, got %d", builtin.Name, len(params)-1, len(args))
+		}
+	} else if len(args) != len(params) {
+		return newError("wrong number of arguments to %s: want %d, got %d", builtin.Name, len(params), len(args))
+	}
+
+	for i, arg := range args {
+		var allowed []object.ObjectType
+		if i < len(params) {
+			allowed = params[i]
+		} else if len(params) > 0 {
+			allowed = params[len(params)-1]
+		}
+		if len(allowed) == 0 {
+			continue
+		}
+		ok := false
+		for _, t := range allowed {
+			ok = ok || arg.Type() == t
+		}
+		if !ok {
+			return newError("argument %d to %s must be %s, got %s", i+1, builtin.Name, joinTypes(allowed), arg.Type())
+		}
+	}
+	return nil
+}
+
+// STRING, ARRAY or HASH
+func joinTypes(types []object.ObjectType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"strings"
+)
+
+// builtins for tests, a failed assertion is a runtime error at the call, so it stops the test where it happened
+
+// assert(cond) and assert(cond, message) fail unless cond is truthy
+func builtinAssert(args ...object.Object) object.Object {
+	if len(args) > 2 {
+		return newError("wrong number of arguments to assert: want at most 2, got %d", len(args))
+	}
+	if isTruthy(args[0]) {
+		return NULL
+	}
+	if len(args) == 2 {
+		return newError("assertion failed: %s", args[1].(*object.String).Value)
+	}
+	return newError("assertion failed")
+}
+
+// assert_eq(have, want) and assert_eq(have, want, message) fail unless have equals want,
+// arrays and hashes by their elements, functions by identity
+func builtinAssertEq(args ...object.Object) object.Object {
+	if len(args) > 3 {
+		return newError("wrong number of arguments to assert_eq: want at most 3, got %d", len(args))
+	}
+	if equal(args[0], args[1]) {
+		return NULL
+	}
+	if len(args) == 3 {
+		return newError("assert_eq failed: %s: have %s, want %s", args[2].(*object.String).Value, args[0].Inspect(), args[1].Inspect())
+	}
+	return newError("assert_eq failed: have %s, want %s", args[0].Inspect(), args[1].Inspect())
+}
+
+// assert_error(fn) calls fn without arguments and fails unless fn fails, assert_error(fn, text) also unless the
+// message contains text; the error is returned as the value a catch would get
+// running out of a limit is not the kind of failure a test asserts, it fails the test
+func (e *Evaluator) builtinAssertError(args ...object.Object) object.Object {
+	if len(args) > 2 {
+		return newError("wrong number of arguments to assert_error: want at most 2, got %d", len(args))
+	}
+	result := e.Apply(args[0], nil)
+	err, ok := result.(*object.Error)
+	switch {
+	case ok && err.Limit:
+		return err
+	case !ok:
+		return newError("assert_error failed: no error, the function gave %s", result.Inspect())
+	case len(args) == 2 && !strings.Contains(err.Message, args[1].(*object.String).Value):
+		return newError("assert_error failed: want an error containing %q, have %q", args[1].(*object.String).Value, err.Message)
+	}
+	return &object.ErrorValue{Err: err}
+}
+
+// equal compares values the way a test means it, by what they hold
+func equal(a, b object.Object) bool {
+	return equalHolding(a, b, map[[2]object.Object]bool{})
+}
+
+// comparing holds the pairs of collections compared further up, meeting one again means a collection holds
+// itself and the pair is equal unless something else in it differs
+func equalHolding(a, b object.Object, comparing map[[2]object.Object]bool) bool {
+	switch a.(type) {
+	case *object.Array, *object.Hash:
+		pair := [2]object.Object{a, b}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+	}
+
+	switch a := a.(type) {
+	case *object.Integer:
+		b, ok := b.(*object.Integer)
+		return ok && a.Value == b.Value
+	case *object.String:
+		b, ok := b.(*object.String)
+		return ok && a.Value == b.Value
+	case *object.Array:
+		b, ok := b.(*object.Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !equalHolding(a.Elements[i], b.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		b, ok := b.(*object.Hash)
+		if !ok || len(a.Pairs) != len(b.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			other, ok := b.Pairs[key]
+			if !ok || !equalHolding(pair.Value, other.Value, comparing) {
+				return false
+			}
+		}
+		return true
+	case *object.ErrorValue:
+		b, ok := b.(*object.ErrorValue)
+		return ok && (a.Err == b.Err || a.Err.Message == b.Err.Message)
+	}
+	// yes, no and null are singletons
+	return a == b
+}
@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"math/rand"
+	"monkey/object"
+	"os"
+	"time"
+)
+
+// builtins reaching outside the script, each one needs a capability the evaluator has to grant
+
+func builtinReadFile(args ...object.Object) object.Object {
+	path := args[0].(*object.String).Value
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return newError("read_file: %v", err)
+	}
+	return &object.String{Value: string(data)}
+}
+
+func builtinWriteFile(args ...object.Object) object.Object {
+	path, content := args[0].(*object.String).Value, args[1].(*object.String).Value
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return newError("write_file: %v", err)
+	}
+	return NULL
+}
+
+// now milliseconds since the unix epoch
+func builtinNow(args ...object.Object) object.Object {
+	return &object.Integer{Value: int(time.Now().UnixMilli())}
+}
+
+// sleep(ms) wakes up early when the run's context is done
+func (e *Evaluator) builtinSleep(args ...object.Object) object.Object {
+	ms := args[0].(*object.Integer).Value
+	if ms < 0 {
+		return newError("sleep: negative duration %d", ms)
+	}
+	timer := time.NewTimer(time.Duration(ms) * time.Millisecond)
+	defer timer.Stop()
+
+	var done <-chan struct{}
+	if e.ctx != nil {
+		done = e.ctx.Done()
+	}
+	select {
+	case <-timer.C:
+		return NULL
+	case <-done:
+		return limitError("%v", e.ctx.Err())
+	}
+}
+
+// rand(n) an integer from 0 up to but not including n
+func builtinRand(args ...object.Object) object.Object {
+	n := args[0].(*object.Integer).Value
+	if n <= 0 {
+		return newError("rand: bound must be positive, got %d", n)
+	}
+	return &object.Integer{Value: rand.Intn(n)}
+}
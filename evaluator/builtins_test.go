@@ -0,0 +1,217 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{} // int, string for a String result, nil for NULL, *object.Error for an error
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len({1: 2, 3: 4})`, 2},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`len(rest([1, 2, 3]))`, 2},
+		{`first(rest([1, 2, 3]))`, 2},
+		{`rest([])`, nil},
+		{`last(push([1, 2], 3))`, 3},
+		{`let a = [1]; let b = push(a, 2); len(a)`, 1},
+		{`type(1)`, "int"},
+		{`type("a")`, "str"},
+		{`type(yes)`, "bool"},
+		{`type([])`, "array"},
+		{`type({})`, "hash"},
+		{`type(fn() {})`, "fn"},
+		{`type(len)`, "fn"},
+		{`type(first([]))`, "null"},
+		{`str(12)`, "12"},
+		{`str("a")`, "a"},
+		{`str([1, "a"])`, `[1, "a"]`},
+		{`int("42")`, 42},
+		{`int(" -7 ")`, -7},
+		{`int(yes)`, 1},
+		{`int(5)`, 5},
+		{`let len = fn(x) { 99 }; len("a")`, 99},
+		{`len(1)`, &object.Error{Message: "argument 1 to len must be STRING, ARRAY or HASH, got INTEGER"}},
+		{`len("one", "two")`, &object.Error{Message: "wrong number of arguments to len: want 1, got 2"}},
+		{`first(1)`, &object.Error{Message: "argument 1 to first must be ARRAY, got INTEGER"}},
+		{`push(1, 1)`, &object.Error{Message: "argument 1 to push must be ARRAY, got INTEGER"}},
+		{`push([1])`, &object.Error{Message: "wrong number of arguments to push: want 2, got 1"}},
+		{`int("abc")`, &object.Error{Message: `int: cannot convert "abc" to an integer`}},
+		{`int([])`, &object.Error{Message: "argument 1 to int must be INTEGER, STRING or BOOLEAN, got ARRAY"}},
+		{`assert(1 < 2)`, nil},
+		{`assert([])`, nil},
+		{`assert(no)`, &object.Error{Message: "assertion failed"}},
+		{`assert(first([]), "must hold")`, &object.Error{Message: "assertion failed: must hold"}},
+		{`assert()`, &object.Error{Message: "wrong number of arguments to assert: want at least 1, got 0"}},
+		{`assert(yes, 1)`, &object.Error{Message: "argument 2 to assert must be STRING, got INTEGER"}},
+		{`assert(yes, "a", "b")`, &object.Error{Message: "wrong number of arguments to assert: want at most 2, got 3"}},
+		{`assert_eq(1 + 1, 2)`, nil},
+		{`assert_eq([1, {"a": [2]}], [1, {"a": [2]}])`, nil},
+		{`assert_eq(len, len)`, nil},
+		{`assert_eq("1", 1)`, &object.Error{Message: `assert_eq failed: have "1", want 1`}},
+		{`assert_eq([1, 2], [1], "lengths")`, &object.Error{Message: "assert_eq failed: lengths: have [1, 2], want [1]"}},
+		{`assert_eq({1: 2}, {1: 3})`, &object.Error{Message: "assert_eq failed: have {1: 2}, want {1: 3}"}},
+		{`let a = [1]; a[0] = a; let b = [1]; b[0] = b; assert_eq(a, a); assert_eq(a, b)`, nil},
+		{`let a = [1, 2]; a[0] = a; let b = [1, 3]; b[0] = b; assert_eq(a, b)`, &object.Error{Message: "assert_eq failed: have [[...], 2], want [[...], 3]"}},
+		{`let h = {}; h["h"] = h; assert_eq(h, {"h": h})`, nil},
+		{`assert_eq(fn() {}, fn() {})`, &object.Error{Message: "assert_eq failed: have fn() {  }, want fn() {  }"}},
+		{`assert_error(fn() { 1 / 0 })["message"]`, "division by zero: 1 / 0"},
+		{`assert_error(fn() { throw "boom" }, "oo")["message"]`, "boom"},
+		{`assert_error(fn() { 1 })`, &object.Error{Message: "assert_error failed: no error, the function gave 1"}},
+		{`assert_error(fn() { throw "boom" }, "bang")`, &object.Error{Message: `assert_error failed: want an error containing "bang", have "boom"`}},
+		{`assert_error(fn(x) { x })["message"]`, "wrong number of arguments: want 1, got 0"},
+		{`assert_error(1)`, &object.Error{Message: "argument 1 to assert_error must be FUNCTION, CLOSURE or BUILTIN, got INTEGER"}},
+		{`try { assert(no, "caught") } catch (e) { e["message"] }`, "assertion failed: caught"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("input %q: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			} else if str.Value != expected {
+				t.Errorf("input %q: want %q, got %q", tt.input, expected, str.Value)
+			}
+		case *object.Error:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("input %q: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+			} else if errObj.Message != expected.Message {
+				t.Errorf("input %q: want error %q, got %q", tt.input, expected.Message, errObj.Message)
+			}
+		case nil:
+			if evaluated != NULL {
+				t.Errorf("input %q: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	var out bytes.Buffer
+	e := New(&out)
+
+	program := parser.New(lexer.NewLexer(`print("a", 1, [yes]); puts(); puts("b")`)).ParseProgram()
+	if evaluated := e.Eval(program, object.NewEnvironment()); evaluated != NULL {
+		t.Fatalf("print did not return NULL. got=%T (%+v)", evaluated, evaluated)
+	}
+	if got := out.String(); got != "a 1 [yes]\n\nb\n" {
+		t.Errorf("wrong output. got=%q", got)
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	e := New(&bytes.Buffer{})
+	e.RegisterBuiltin(&object.Builtin{
+		Name:   "shout",
+		Params: [][]object.ObjectType{{object.STRING_OBJ}},
+		Fn: func(args ...object.Object) object.Object {
+			return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value) + "!"}
+		},
+	})
+
+	program := parser.New(lexer.NewLexer(`shout("hey")`)).ParseProgram()
+	evaluated := e.Eval(program, object.NewEnvironment())
+	if str, ok := evaluated.(*object.String); !ok || str.Value != "HEY!" {
+		t.Errorf("wrong result. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	program = parser.New(lexer.NewLexer(`shout(1)`)).ParseProgram()
+	evaluated = e.Eval(program, object.NewEnvironment())
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "argument 1 to shout must be STRING, got INTEGER" {
+		t.Errorf("wrong result. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	// variadic without params takes anything
+	e.RegisterBuiltin(&object.Builtin{
+		Name:     "count",
+		Variadic: true,
+		Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: len(args)}
+		},
+	})
+	program = parser.New(lexer.NewLexer(`count() + count(1, "a", [])`)).ParseProgram()
+	evaluated = e.Eval(program, object.NewEnvironment())
+	if integer, ok := evaluated.(*object.Integer); !ok || integer.Value != 3 {
+		t.Errorf("wrong result. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestBuiltinErrorPosition(t *testing.T) {
+	evaluated := testEval(t, "let x = 1;\nlet y = len(x);")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Inspect() != "runtime error at 2:12: argument 1 to len must be STRING, ARRAY or HASH, got INTEGER" {
+		t.Errorf("wrong error. got=%q", errObj.Inspect())
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "note.txt")
+	write := fmt.Sprintf(`write_file(%q, "hello"); read_file(%q)`, path, path)
+
+	tests := []struct {
+		input    string
+		caps     object.Capability
+		expected interface{} // string for the message of an error, int or bool for a value
+	}{
+		{`print("hi")`, object.NoCapabilities, "print needs the print capability, which is not granted"},
+		{write, object.CapPrint, "write_file needs the io capability, which is not granted"},
+		{write, object.CapIO, "hello"},
+		{"now() > 0", object.CapIO, "now needs the time capability, which is not granted"},
+		{"now() > 0", object.CapTime, true},
+		{"sleep(-1)", object.CapTime, "sleep: negative duration -1"},
+		{"rand(1)", object.CapTime, "rand needs the random capability, which is not granted"},
+		{"rand(1)", object.CapRandom, 0},
+		{"rand(0)", object.AllCapabilities, "rand: bound must be positive, got 0"},
+		{"let f = rand; type(f)", object.NoCapabilities, "fn"}, // only calling needs the capability
+	}
+
+	for _, tt := range tests {
+		e := New(&bytes.Buffer{})
+		e.Capabilities = tt.caps
+		program := parser.New(lexer.NewLexer(tt.input)).ParseProgram()
+		evaluated := e.Eval(program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			switch got := evaluated.(type) {
+			case *object.Error:
+				if got.Message != expected {
+					t.Errorf("input %q: wrong error message. want=%q, got=%q", tt.input, expected, got.Message)
+				}
+			case *object.String:
+				if got.Value != expected {
+					t.Errorf("input %q: want %q, got %q", tt.input, expected, got.Value)
+				}
+			default:
+				t.Errorf("input %q: unexpected result %T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
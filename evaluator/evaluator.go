@@ -0,0 +1,774 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"os"
+)
+
+// there is only ever one null, one yes and one no, so they can be compared by pointer
+var (
+	NULL = &object.Null{}
+	YES  = &object.Boolean{Value: true}
+	NO   = &object.Boolean{Value: false}
+
+	// break/continue carry no data either
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+)
+
+// Evaluator everything a run needs besides the environment: where print writes to, which builtins exist,
+// which of them scripts may call and how much a run may do
+type Evaluator struct {
+	Out          io.Writer
+	Limits       Limits
+	Capabilities object.Capability // builtins requiring more than this fail when called
+	builtins     map[string]*object.Builtin
+
+	// Foreign calls what the evaluator cannot, like the closures of the vm, when a builtin such as assert_error
+	// is handed one; the vm sets it while it runs
+	Foreign func(fn object.Object, args []object.Object) object.Object
+
+	// state of the current run
+	ctx   context.Context
+	steps int
+	depth int
+
+	// calls in tail position of the function bodies seen so far, see ast.TailCalls
+	tails   map[*ast.CallExpression]bool
+	scanned map[*ast.BlockStatement]bool
+}
+
+// New evaluator with the default builtins, print and puts write to out
+// calls may nest DefaultMaxCallDepth deep, everything else is unlimited
+// scripts may print, file, clock and random builtins have to be granted through Capabilities
+func New(out io.Writer) *Evaluator {
+	e := &Evaluator{
+		Out:          out,
+		Limits:       Limits{MaxCallDepth: DefaultMaxCallDepth},
+		Capabilities: object.CapPrint,
+		builtins:     make(map[string]*object.Builtin),
+		tails:        make(map[*ast.CallExpression]bool),
+		scanned:      make(map[*ast.BlockStatement]bool),
+	}
+	for _, b := range e.defaultBuiltins() {
+		e.RegisterBuiltin(b)
+	}
+	return e
+}
+
+// RegisterBuiltin makes b callable from scripts as b.Name, replacing any builtin of the same name
+// a binding in the environment with the same name still wins over it
+func (e *Evaluator) RegisterBuiltin(b *object.Builtin) {
+	e.builtins[b.Name] = b
+}
+
+// Builtin the builtin registered as name, if there is one
+func (e *Evaluator) Builtin(name string) (*object.Builtin, bool) {
+	b, ok := e.builtins[name]
+	return b, ok
+}
+
+// BuiltinNames names of every registered builtin, in no particular order
+func (e *Evaluator) BuiltinNames() []string {
+	names := make([]string, 0, len(e.builtins))
+	for name := range e.builtins {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Apply calls fn (a function or a builtin) with already evaluated arguments, the way a call expression does
+func (e *Evaluator) Apply(fn object.Object, args []object.Object) object.Object {
+	return e.applyFunction(fn, args, token.Position{})
+}
+
+// Eval evaluates with a fresh evaluator printing to stdout, for when nothing needs configuring
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(os.Stdout).Eval(node, env)
+}
+
+// Eval walks the node and returns the value it produces, bindings are read from and written to env
+// every node counts as a step against the limits
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+	result := e.eval(node, env)
+	if err := e.CheckSize(result); err != nil {
+		return err
+	}
+	// the innermost node an error comes out of is where it happened
+	if errObj, ok := result.(*object.Error); ok && errObj.Pos.Line == 0 {
+		errObj.Pos = ast.OperatorPos(node)
+	}
+	return result
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.Program:
+		return e.evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return e.Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return e.evalBlockStatement(node, env)
+	case *ast.SendStatement:
+		val := e.Eval(node.Value, env)
+		if interrupts(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env)
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
+	case *ast.LetStatement:
+		return e.evalLetStatement(node, env)
+	case *ast.AssignStatement:
+		return e.evalAssignStatement(node, env)
+	case *ast.Identifier:
+		return e.evalIdentifier(node, env)
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.WhenExpression:
+		return e.evalWhenExpression(node, env)
+	case *ast.FunctionLiteral:
+		return &object.Function{Name: node.Name, Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := e.Eval(node.Function, env)
+		if interrupts(function) {
+			return function
+		}
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && interrupts(args[0]) {
+			return args[0]
+		}
+		if e.tails[node] {
+			return &tailCall{fn: function, args: args, node: node}
+		}
+		return e.applyFunction(function, args, node.Token.Pos)
+	case *ast.ArrayLiteral:
+		if err := e.CheckLength(object.ARRAY_OBJ, len(node.Elements)); err != nil {
+			return err
+		}
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && interrupts(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := e.Eval(node.Left, env)
+		if interrupts(left) {
+			return left
+		}
+		index := e.Eval(node.Index, env)
+		if interrupts(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.GroupedExpression:
+		return e.Eval(node.Expression, env)
+	case *ast.PrefixExpression:
+		right := e.Eval(node.Right, env)
+		if interrupts(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.InfixExpression:
+		left := e.Eval(node.Left, env)
+		if interrupts(left) {
+			return left
+		}
+		right := e.Eval(node.Right, env)
+		if interrupts(right) {
+			return right
+		}
+		if err := e.CheckInfix(node.Operator, left, right); err != nil {
+			return err
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	}
+	return nil
+}
+
+// a send at the top level ends the program with its value
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+	for _, stmt := range program.Statements {
+		result = e.Eval(stmt, env)
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside of loop", result.Inspect())
+		}
+	}
+	// ending in a let, an assignment or a loop, the program has no value
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// unlike evalProgram the wrapped send/break/continue are passed up as they are,
+// the enclosing function call or loop is the one that unwraps them
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	for _, stmt := range block.Statements {
+		result = e.Eval(stmt, env)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return result
+			}
+		}
+	}
+	// an empty block, or one ending in a statement without a value, is null like on the vm
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+func (e *Evaluator) evalWhenExpression(we *ast.WhenExpression, env *object.Environment) object.Object {
+	condition := e.Eval(we.Condition, env)
+	if interrupts(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return e.Eval(we.Consequence, env)
+	} else if we.Alternative != nil {
+		return e.Eval(we.Alternative, env)
+	}
+	return NULL
+}
+
+func (e *Evaluator) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := e.Eval(ws.Condition, env)
+		if interrupts(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+		if result, done := e.evalLoopBody(ws.Body, object.NewEnclosedEnvironment(env)); done {
+			return result
+		}
+	}
+}
+
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.Eval(fs.Iterable, env)
+	if interrupts(iterable) {
+		return iterable
+	}
+
+	items, err := Items(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// every iteration gets its own scope, a function created in the body keeps its own x
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(fs.Variable.Value, item)
+		if result, done := e.evalLoopBody(fs.Body, loopEnv); done {
+			return result
+		}
+	}
+	return nil
+}
+
+// runs one iteration, done says whether the loop has to stop and result is what the loop then evaluates to
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	result := e.Eval(body, env)
+	if result == nil {
+		return nil, false
+	}
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return nil, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	}
+	return nil, false
+}
+
+// tailCall a call in tail position, handed back to the applyFunction running the body it is in and made there
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+	node *ast.CallExpression
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// call is where fn is called from, an error coming out of its body records it as a frame of the error's stack
+// a body ending in a tail call has the call made here, in place of the one that just finished,
+// so recursion through send f(x) runs in constant Go stack and does not count against MaxCallDepth;
+// the function a tail call replaced leaves no frame behind
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object, call token.Position) object.Object {
+	var caller *object.Function // the function the tail call came from
+	var tail *ast.CallExpression
+	for {
+		result := e.call(fn, args, call)
+		// what fails without a position failed at the call itself, in the function making it, as it would without the trampoline
+		if err, ok := result.(*object.Error); ok && tail != nil && err.Pos.Line == 0 {
+			err.Pos = ast.OperatorPos(tail)
+			err.Stack = append(err.Stack, object.Frame{Function: caller.Name, Pos: call})
+		}
+		tc, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+		caller = fn.(*object.Function)
+		fn, args, tail = tc.fn, tc.args, tc.node
+	}
+}
+
+// makes a single call, its result may be a tail call still to make
+func (e *Evaluator) call(fn object.Object, args []object.Object, call token.Position) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		if missing := builtin.Requires &^ e.Capabilities; missing != 0 {
+			return newError("%s needs the %s capability, which is not granted", builtin.Name, missing)
+		}
+		if err := checkBuiltinArgs(builtin, args); err != nil {
+			return err
+		}
+		return builtin.Fn(args...)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok && e.Foreign != nil {
+		return e.Foreign(fn, args)
+	}
+	if !ok {
+		return newError("not a function: %s", fn.Type())
+	}
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments: want %d, got %d", len(function.Parameters), len(args))
+	}
+	err, leave := e.enterCall()
+	defer leave()
+	if err != nil {
+		return err
+	}
+	if !e.scanned[function.Body] {
+		e.scanned[function.Body] = true
+		for _, tc := range ast.TailCalls(function.Body) {
+			e.tails[tc] = true
+		}
+	}
+
+	env := object.NewEnclosedEnvironment(function.Env)
+	for i, param := range function.Parameters {
+		env.Set(param.Value, args[i])
+	}
+
+	evaluated := e.Eval(function.Body, env)
+	switch evaluated := evaluated.(type) {
+	case *object.ReturnValue:
+		return evaluated.Value
+	case *object.Break, *object.Continue:
+		return newError("%s outside of loop", evaluated.Inspect())
+	case *object.Error:
+		evaluated.Stack = append(evaluated.Stack, object.Frame{Function: function.Name, Pos: call})
+	}
+	return evaluated
+}
+
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Resolved {
+		if val, ok := env.GetAt(node.Depth, node.Value); ok {
+			return val
+		}
+	}
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := e.builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+// evaluates left to right, stops at the first error, send, break or continue and returns only that
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := []object.Object{}
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if interrupts(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for i, keyNode := range node.Keys {
+		key := e.Eval(keyNode, env)
+		if interrupts(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		value := e.Eval(node.Values[i], env)
+		if interrupts(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+	return hash
+}
+
+// reading past the end of an array or a missing hash key gives null
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= len(elements) {
+			return NULL
+		}
+		return elements[i]
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorField(left.(*object.ErrorValue).Err, index.(*object.String).Value)
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		pair, ok := left.(*object.Hash).Pairs[key.HashKey()]
+		if !ok {
+			return NULL
+		}
+		return pair.Value
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// e["message"], e["position"] and e["value"] of a caught error, anything else is null like a missing hash key
+func evalErrorField(err *object.Error, field string) object.Object {
+	switch field {
+	case "message":
+		return &object.String{Value: err.Message}
+	case "position":
+		if err.Pos.Line == 0 {
+			return NULL
+		}
+		return &object.String{Value: err.Pos.String()}
+	case "value":
+		if err.Value == nil {
+			return NULL
+		}
+		return err.Value
+	}
+	return NULL
+}
+
+func (e *Evaluator) evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := e.Eval(node.Value, env)
+	if interrupts(val) {
+		return val
+	}
+	return Thrown(val)
+}
+
+// the block shares the scope around it like a when block, the handler gets its own one holding the caught error
+// errors from limits are not caught, the script has to stop
+func (e *Evaluator) evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.Eval(node.Block, env)
+	err, ok := result.(*object.Error)
+	if !ok || err.Limit {
+		return result
+	}
+
+	handlerEnv := object.NewEnclosedEnvironment(env)
+	if node.Param != nil {
+		handlerEnv.Set(node.Param.Value, &object.ErrorValue{Err: err})
+	}
+	return e.Eval(node.Handler, handlerEnv)
+}
+
+// let x = 5 or const x = 5, a constant cannot be redeclared in the same scope either
+func (e *Evaluator) evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
+	val := e.Eval(node.Value, env)
+	if interrupts(val) {
+		return val
+	}
+
+	name := node.Name.Value
+	if env.Owns(name) && env.IsConstant(name) {
+		return newError("cannot redeclare constant: %s", name)
+	}
+	if node.IsConstant() {
+		env.SetConstant(name, val)
+	} else {
+		env.Set(name, val)
+	}
+	return nil
+}
+
+// x = 5, x += 5, arr[0] = 5, hash[key] -= 1
+func (e *Evaluator) evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	val := e.Eval(node.Value, env)
+	if interrupts(val) {
+		return val
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if env.IsConstant(target.Value) {
+			return newError("cannot assign to constant: %s", target.Value)
+		}
+		if node.Operator != "=" {
+			current, ok := env.Get(target.Value)
+			if !ok {
+				return newError("cannot assign to undeclared identifier: %s", target.Value)
+			}
+			if val = e.evalCompoundOperator(node.Operator, current, val); interrupts(val) {
+				return val
+			}
+		}
+		if !env.Assign(target.Value, val) {
+			return newError("cannot assign to undeclared identifier: %s", target.Value)
+		}
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if interrupts(left) {
+			return left
+		}
+		index := e.Eval(target.Index, env)
+		if interrupts(index) {
+			return index
+		}
+		if node.Operator != "=" {
+			current := evalIndexExpression(left, index)
+			if interrupts(current) {
+				return current
+			}
+			if val = e.evalCompoundOperator(node.Operator, current, val); interrupts(val) {
+				return val
+			}
+		}
+		if err := evalIndexAssignment(left, index, val); err != nil {
+			return err
+		}
+		// a hash grows when a new key is assigned
+		if err := e.CheckSize(left); err != nil {
+			return err
+		}
+	default:
+		return newError("cannot assign to %s", node.Target.ToString())
+	}
+	return nil
+}
+
+// += is + applied to the current value, and so on
+func (e *Evaluator) evalCompoundOperator(operator string, current, val object.Object) object.Object {
+	operator = operator[:len(operator)-1]
+	if err := e.CheckInfix(operator, current, val); err != nil {
+		return err
+	}
+	return evalInfixExpression(operator, current, val)
+}
+
+// unlike reading, writing outside of an array is an error, there is nothing to grow it with
+func evalIndexAssignment(left, index, val object.Object) *object.Error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i >= len(elements) {
+			return newError("index out of range: %d, length %d", i, len(elements))
+		}
+		elements[i] = val
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Set(key, val)
+	default:
+		return newError("index assignment not supported: %s[%s]", left.Type(), index.Type())
+	}
+	return nil
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case "-":
+		if right.Type() != object.INTEGER_OBJ {
+			return newError("unknown operator: -%s", right.Type())
+		}
+		return &object.Integer{Value: -right.(*object.Integer).Value}
+	case "~":
+		if right.Type() != object.INTEGER_OBJ {
+			return newError("unknown operator: ~%s", right.Type())
+		}
+		return &object.Integer{Value: ^right.(*object.Integer).Value}
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left.(*object.Integer).Value, right.(*object.Integer).Value)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left.(*object.String).Value, right.(*object.String).Value)
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalIntegerInfixExpression(operator string, left, right int) object.Object {
+	switch operator {
+	case "+":
+		return &object.Integer{Value: left + right}
+	case "-":
+		return &object.Integer{Value: left - right}
+	case "*":
+		return &object.Integer{Value: left * right}
+	case "/":
+		if right == 0 {
+			return newError("division by zero: %d / %d", left, right)
+		}
+		return &object.Integer{Value: left / right}
+	case "%":
+		if right == 0 {
+			return newError("modulo by zero: %d %% %d", left, right)
+		}
+		return &object.Integer{Value: left % right}
+	case "**":
+		if right < 0 {
+			return newError("negative exponent: %d ** %d", left, right)
+		}
+		return &object.Integer{Value: power(left, right)}
+	case "&":
+		return &object.Integer{Value: left & right}
+	case "|":
+		return &object.Integer{Value: left | right}
+	case "^":
+		return &object.Integer{Value: left ^ right}
+	case "<<":
+		// go panics on a negative shift count, so catch it before it does
+		if right < 0 {
+			return newError("negative shift count: %d << %d", left, right)
+		}
+		return &object.Integer{Value: left << right}
+	case ">>":
+		if right < 0 {
+			return newError("negative shift count: %d >> %d", left, right)
+		}
+		return &object.Integer{Value: left >> right}
+	case "<":
+		return nativeBoolToBooleanObject(left < right)
+	case ">":
+		return nativeBoolToBooleanObject(left > right)
+	case "<=":
+		return nativeBoolToBooleanObject(left <= right)
+	case ">=":
+		return nativeBoolToBooleanObject(left >= right)
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ)
+	}
+}
+
+func evalStringInfixExpression(operator string, left, right string) object.Object {
+	switch operator {
+	case "+":
+		return &object.String{Value: left + right}
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", object.STRING_OBJ, operator, object.STRING_OBJ)
+	}
+}
+
+// power square-and-multiply, exponent is known to be non negative
+func power(base, exponent int) int {
+	result := 1
+	for exponent > 0 {
+		if exponent&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exponent >>= 1
+	}
+	return result
+}
+
+func nativeBoolToBooleanObject(b bool) *object.Boolean {
+	if b {
+		return YES
+	}
+	return NO
+}
+
+// everything is truthy except no and null
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL, NO:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// whether obj ends the evaluation of whatever it is part of: an error, or a send, break or continue on its way
+// to the function or loop it leaves, like the send in let x = when (c) { send 1 } otherwise { 2 }
+func interrupts(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+		return true
+	}
+	return false
+}
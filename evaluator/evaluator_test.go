@@ -0,0 +1,443 @@
+package evaluator
+
+import (
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	return Eval(program, object.NewEnvironment())
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int) bool {
+	t.Helper()
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. want=%d, got=%d", expected, result.Value)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	t.Helper()
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. want=%t, got=%t", expected, result.Value)
+		return false
+	}
+	return true
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"5", 5},
+		{"-10", -10},
+		{"5 + 5 * 2", 15},
+		{"(5 + 5) * 2", 20},
+		{"20 / 3", 6},
+		{"20 % 3", 2},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"5 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"1 + 1 << 2", 8},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"2 <= 2", true},
+		{"1 >= 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"(1 < 2) == (3 < 4)", true},
+		{"(1 < 2) != (3 > 4)", true},
+		{"!5", false},
+		{"!!5", true},
+		{"5 & 1 == 1", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"10 % (5 - 5)", "modulo by zero: 10 % 0"},
+		{"2 ** -1", "negative exponent: 2 ** -1"},
+		{"1 << -1", "negative shift count: 1 << -1"},
+		{"1 + (1 < 2)", "type mismatch: INTEGER + BOOLEAN"},
+		{"-(1 < 2)", "unknown operator: -BOOLEAN"},
+		{"~(1 < 2)", "unknown operator: ~BOOLEAN"},
+		{"(1 < 2) + (1 < 2)", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"1 + 10 / 0 * 3", "division by zero: 10 / 0"},
+		{"foobar", "identifier not found: foobar"},
+		{"x = 5", "cannot assign to undeclared identifier: x"},
+		{"x += 5", "cannot assign to undeclared identifier: x"},
+		{"let x = 5; x /= 0", "division by zero: 5 / 0"},
+		{"let a = [1]; a[1] = 5", "index out of range: 1, length 1"},
+		{"let a = 5; a[0] = 1", "index assignment not supported: INTEGER[INTEGER]"},
+		{"{[1]: 2}", "unusable as hash key: ARRAY"},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{"5(1)", "not a function: INTEGER"},
+		{"fn(x) { x }()", "wrong number of arguments: want 1, got 0"},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+		{"let i = 0; while (i < 10) { i += 1; when (i == 3) { send i / 0 } }", "division by zero: 3 / 0"},
+		{"const x = 1; x = 2", "cannot assign to constant: x"},
+		{"const x = 1; x += 2", "cannot assign to constant: x"},
+		{"const x = 1; let x = 2", "cannot redeclare constant: x"},
+		{"const x = 1; let f = fn() { x = 2 }; f()", "cannot assign to constant: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error object returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("input %q: wrong error message. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5; let a = a + 1; a", 6},
+		{"const a = 5; a * 2", 10},
+		{"const a = 5; let f = fn() { let a = 1; a = 2; a }; f() + a", 7},
+		{"const a = [1]; a[0] = 4; a[0]", 4},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let x = 5; x = 10; x", 10},
+		{"let x = 5; x = x * 2; x", 10},
+		{"let x = 5; x += 3; x", 8},
+		{"let x = 5; x -= 3; x", 2},
+		{"let x = 5; x *= 3; x", 15},
+		{"let x = 15; x /= 4; x", 3},
+		{"let x = 1; x += 1; x += 1; x", 3},
+		{"let a = [1, 2, 3]; a[1] = 20; a[1]", 20},
+		{"let a = [1, 2, 3]; a[2] += 10; a[2]", 13},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 7; a[0]", 7},
+		{"let h = {1: 10}; h[1] = 11; h[1]", 11},
+		{"let h = {1: 10}; h[2] = 20; h[2]", 20},
+		{"let h = {1: 10}; h[1] *= 3; h[1]", 30},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 30; m[1][0]", 30},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestArrayAndHashIndexing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2 * 2, 3 + 3][1]", 4},
+		{"let i = 0; [1][i]", 1},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{"{1: 5, 2: 6}[2]", 6},
+		{"{1 < 2: 5}[2 > 1]", 5},
+		{"{}[0]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, expected)
+		} else if evaluated != NULL {
+			t.Errorf("input %q: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestWhenExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"when (yes) { 10 }", 10},
+		{"when (no) { 10 }", nil},
+		{"when (1 < 2) { 10 } otherwise { 20 }", 10},
+		{"when (1 > 2) { 10 } otherwise { 20 }", 20},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, expected)
+		} else if evaluated != NULL {
+			t.Errorf("input %q: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let double = fn(x) { send x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let f = fn(x) { when (x > 1) { send 1; } send 2; }; f(5)", 1},
+		{"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3);", 5},
+		{"let counter = 0; let inc = fn() { counter += 1 }; inc(); inc(); counter", 2},
+		{"send 1; 2", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+// calls in tail position run in constant Go stack, no matter how deep the recursion goes
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let loop = fn(n) { when (n == 0) { send 0 } otherwise { send loop(n - 1) } }; loop(1000000)", 0},
+		{"let count = fn(n, acc) { when (n == 0) { acc } otherwise { count(n - 1, acc + 1) } }; count(100000, 0)", 100000},
+		{`let even = fn(n) { when (n == 0) { send 1 }; odd(n - 1) };
+		  let odd = fn(n) { when (n == 0) { send 0 }; (even(n - 1)) };
+		  even(100001)`, 0},
+		{"let f = fn(n) { while (yes) { when (n == 0) { send 7 }; send f(n - 1) } }; f(100000)", 7},
+		{"let f = fn(n) { try { throw n } catch (e) { when (n == 0) { 3 } otherwise { f(n - 1) } } }; f(100000)", 3},
+		{"let sum = fn(n) { when (n == 0) { 0 } otherwise { n + sum(n - 1) } }; sum(100)", 5050},
+		// a call in a try block is no tail call, the catch still sees what it throws
+		{"let g = fn() { throw 1 }; let f = fn() { try { send g() } catch { 2 } }; f()", 2},
+		{"let f = fn() { len }; f()([1, 2])", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	evaluated := testEval(t, `let s = "hello" + " " + "world"; s`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "hello world" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+
+	testBooleanObject(t, testEval(t, `"a" == "a"`), true)
+	testIntegerObject(t, testEval(t, `{"one": 1, "two": 2}["two"]`), 2)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let i = 0; while (no) { i += 1 }; i", 0},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { sum += x }; sum", 10},
+		{"let n = 0; for (c in \"héllo\") { n += 1 }; n", 5},
+		{"let s = \"\"; for (c in \"abc\") { s = c + s }; when (s == \"cba\") { 1 } otherwise { 0 }", 1},
+		{"let sum = 0; for (k in {1: 10, 2: 20, 3: 30}) { sum += k }; sum", 6},
+		{"let i = 0; while (yes) { i += 1; when (i == 5) { break } }; i", 5},
+		{"let sum = 0; for (x in [1, 2, 3, 4, 5, 6]) { when (x % 2 == 0) { continue; } sum += x; }; sum", 9},
+		// break only leaves the innermost loop
+		{"let n = 0; for (x in [1, 2, 3]) { for (y in [1, 2, 3]) { when (y == 2) { break } n += 1 } }; n", 3},
+		// send leaves every loop and the function
+		{"let f = fn() { let i = 0; while (yes) { i += 1; when (i == 7) { send i } } }; f()", 7},
+		{"let f = fn(arr) { for (x in arr) { when (x > 2) { send x } }; send 0 }; f([1, 2, 3, 4])", 3},
+		// a loop inside a function called from a loop does not affect the outer one
+		{"let first = fn(arr) { for (x in arr) { break }; 1 }; let n = 0; for (x in [1, 2, 3]) { n += first([x]) }; n", 3},
+		// iteration variable does not leak, assignments to outer bindings do
+		{"let x = 100; for (x in [1, 2]) { x }; x", 100},
+		{"let arr = [1, 2, 3]; let n = 0; for (x in arr) { arr[2] = 10; n += x }; n", 6},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestLoopClosures(t *testing.T) {
+	input := `
+	let fns = [0, 0, 0];
+	for (x in [0, 1, 2]) { fns[x] = fn() { x * 10 } }
+	fns[0]() + fns[1]() + fns[2]()`
+	testIntegerObject(t, testEval(t, input), 30)
+}
+
+func TestErrorTraceback(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + yes", "runtime error at 1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1;\nx(2)", "runtime error at 2:2: not a function: INTEGER"},
+		{"let add = fn(a, b) {\n  a + b\n};\nlet twice = fn(x) { 2 * add(x, \"s\") };\ntwice(1);",
+			"runtime error at 2:5: type mismatch: INTEGER + STRING\n" +
+				"  at 2:5 in add\n" +
+				"  at 4:28 in twice\n" +
+				"  at 5:6 in <program>"},
+		{"fn() { missing }()", "runtime error at 1:8: identifier not found: missing\n" +
+			"  at 1:8 in <anonymous fn>\n" +
+			"  at 1:17 in <program>"},
+		{"let f = fn(n) { when (n == 0) { len(1) } otherwise { 1 + f(n - 1) } };\nf(30)",
+			"runtime error at 1:36: argument 1 to len must be STRING, ARRAY or HASH, got INTEGER\n" +
+				strings.Repeat("  at 1:36 in f\n", 1) +
+				strings.Repeat("  at 1:59 in f\n", 9) +
+				"  ... 12 more calls\n" +
+				strings.Repeat("  at 1:59 in f\n", 9) +
+				"  at 2:2 in <program>"},
+		// a tail call replaces the function making it, which leaves no frame
+		{"let add = fn(a, b) {\n  a + b\n};\nlet twice = fn(x) { send add(x, \"s\") };\ntwice(1);",
+			"runtime error at 2:5: type mismatch: INTEGER + STRING\n" +
+				"  at 2:5 in add\n" +
+				"  at 5:6 in <program>"},
+		{"let f = fn(n) { when (n == 0) { len(1) } otherwise { f(n - 1) } };\nf(30)",
+			"runtime error at 1:36: argument 1 to len must be STRING, ARRAY or HASH, got INTEGER\n" +
+				"  at 1:36 in f\n" +
+				"  at 2:2 in <program>"},
+		{"let f = fn() { send 5(1) };\nf()",
+			"runtime error at 1:22: not a function: INTEGER\n" +
+				"  at 1:22 in f\n" +
+				"  at 2:2 in <program>"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(t, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error returned", tt.input)
+			continue
+		}
+		if got := errObj.Traceback(); got != tt.expected {
+			t.Errorf("input %q: wrong traceback.\nwant:\n%s\ngot:\n%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{} // int, string for a String result, nil for NULL
+	}{
+		{`try { 1 } catch { 2 }`, 1},
+		{`try { 1 / 0 } catch { 2 }`, 2},
+		{`try { throw "boom" } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom" } catch (e) { type(e) }`, "error"},
+		{`try { 1 + yes } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{"try {\n  throw 42;\n} catch (e) { e[\"position\"] }", "2:3"},
+		{`try { throw [1, 2] } catch (e) { len(e["value"]) }`, 2},
+		{`try { missing } catch (e) { e["value"] }`, nil},
+		{`try { throw "x" } catch (e) { e["nope"] }`, nil},
+		// unwinds through nested calls
+		{`let f = fn(n) { when (n == 0) { throw "bottom" } otherwise { f(n - 1) } };
+		  try { f(5) } catch (e) { e["message"] }`, "bottom"},
+		// a send inside the block returns from the function, it is not an error
+		{`let f = fn() { try { send 1; 2 } catch { 3 }; 4 }; f()`, 1},
+		{`let f = fn() { try { throw "no" } catch { send 3 }; 4 }; f()`, 3},
+		// rethrow, the outer try sees the same error
+		{`try { try { throw "inner" } catch (e) { throw e } } catch (e) { e["message"] }`, "inner"},
+		{`let i = 0; while (yes) { try { break } catch { 0 } }; i`, 0},
+		// the handler's binding does not leak
+		{`let e = 1; try { throw "x" } catch (e) { 0 }; e`, 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("input %q: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("input %q: want %q, got %q", tt.input, expected, str.Value)
+			}
+		case nil:
+			if evaluated != NULL {
+				t.Errorf("input %q: object is not NULL. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	evaluated := testEval(t, "let f = fn() {\n  throw \"boom\"\n};\nf()")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error returned. got=%T (%+v)", evaluated, evaluated)
+	}
+	want := "runtime error at 2:3: boom\n  at 2:3 in f\n  at 4:2 in <program>"
+	if got := errObj.Traceback(); got != want {
+		t.Errorf("wrong traceback.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,103 @@
+package evaluator
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// DefaultMaxCallDepth how deep New lets calls nest, well below what would overflow the Go stack
+const DefaultMaxCallDepth = 10000
+
+// how many steps go by between two looks at the context, checking it costs more than a step
+const contextCheckInterval = 1024
+
+// Limits caps what a single run may do, so scripts nobody reviewed can be run safely
+// a zero field means no limit
+type Limits struct {
+	MaxSteps          int // nodes evaluated, or instructions run on the vm
+	MaxCallDepth      int // function calls nested inside each other
+	MaxCollectionSize int // elements of an array, pairs of a hash, bytes of a string
+}
+
+// EvalContext evaluates node as one run: counters start from zero and evaluation stops once ctx is done
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	defer e.start(ctx)()
+	return e.Eval(node, env)
+}
+
+// ApplyContext is Apply as one run, see EvalContext
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args []object.Object) object.Object {
+	defer e.start(ctx)()
+	return e.Apply(fn, args)
+}
+
+// resets the counters for a new run, the returned func forgets ctx again once the run is over
+func (e *Evaluator) start(ctx context.Context) func() {
+	e.ctx, e.steps, e.depth = ctx, 0, 0
+	return func() { e.ctx = nil }
+}
+
+// counts one evaluated node, returns an error once the run is over its budget or its context is done
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+	if e.Limits.MaxSteps > 0 && e.steps > e.Limits.MaxSteps {
+		return limitError("more than %d steps", e.Limits.MaxSteps)
+	}
+	if e.ctx != nil && e.steps%contextCheckInterval == 0 {
+		if err := e.ctx.Err(); err != nil {
+			return limitError("%v", err)
+		}
+	}
+	return nil
+}
+
+// enters a function call, the returned func leaves it again
+func (e *Evaluator) enterCall() (*object.Error, func()) {
+	e.depth++
+	leave := func() { e.depth-- }
+	if e.Limits.MaxCallDepth > 0 && e.depth > e.Limits.MaxCallDepth {
+		return limitError("calls nested more than %d deep", e.Limits.MaxCallDepth), leave
+	}
+	return nil, leave
+}
+
+// CheckSize checks that a value just produced is not bigger than MaxCollectionSize allows
+// where the size is known before the value is built, CheckLength and CheckInfix refuse it without allocating
+func (e *Evaluator) CheckSize(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return e.CheckLength(obj.Type(), len(obj.Elements))
+	case *object.Hash:
+		return e.CheckLength(obj.Type(), len(obj.Pairs))
+	case *object.String:
+		return e.CheckLength(obj.Type(), len(obj.Value))
+	}
+	return nil
+}
+
+// CheckLength checks that a value of type t and size elements is not bigger than MaxCollectionSize allows,
+// before it is built
+func (e *Evaluator) CheckLength(t object.ObjectType, size int) *object.Error {
+	if max := e.Limits.MaxCollectionSize; max > 0 && size > max {
+		return limitError("%s of size %d, the maximum is %d", t, size, max)
+	}
+	return nil
+}
+
+// CheckInfix checks what applying operator to left and right would build, before Infix builds it:
+// + joining two strings is the only operator producing a value bigger than its operands
+func (e *Evaluator) CheckInfix(operator string, left, right object.Object) *object.Error {
+	l, ok := left.(*object.String)
+	r, ok2 := right.(*object.String)
+	if operator != "+" || !ok || !ok2 {
+		return nil
+	}
+	return e.CheckLength(object.STRING_OBJ, len(l.Value)+len(r.Value))
+}
+
+func limitError(format string, a ...interface{}) *object.Error {
+	err := newError("limit exceeded: "+format, a...)
+	err.Limit = true
+	return err
+}
@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"context"
+	"io"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testEvalLimited(t *testing.T, ctx context.Context, limits Limits, input string) object.Object {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	e := New(io.Discard)
+	e.Limits = limits
+	return e.EvalContext(ctx, program, object.NewEnvironment())
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected string // error message, empty when the script has to run through
+	}{
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", Limits{MaxCallDepth: DefaultMaxCallDepth}, "limit exceeded: calls nested more than 10000 deep"},
+		{"let f = fn(n) { when (n == 0) { 0 } otherwise { 1 + f(n - 1) } }; f(50)", Limits{MaxCallDepth: 100}, ""},
+		{"let f = fn(n) { when (n == 0) { 0 } otherwise { 1 + f(n - 1) } }; f(200)", Limits{MaxCallDepth: 100}, "limit exceeded: calls nested more than 100 deep"},
+		// tail calls do not nest
+		{"let f = fn(n) { when (n == 0) { 0 } otherwise { f(n - 1) } }; f(200)", Limits{MaxCallDepth: 100}, ""},
+		{"let f = fn() { f() }; f()", Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 steps"},
+		{"let i = 0; while (yes) { i += 1 }", Limits{MaxSteps: 1000}, "limit exceeded: more than 1000 steps"},
+		{"1 + 2", Limits{MaxSteps: 1000}, ""},
+		{"let a = []; for (x in [1, 2, 3, 4]) { a = push(a, x) }", Limits{MaxCollectionSize: 3}, "limit exceeded: ARRAY of size 4, the maximum is 3"},
+		{`let s = "ab"; while (yes) { s = s + s }`, Limits{MaxCollectionSize: 64}, "limit exceeded: STRING of size 128, the maximum is 64"},
+		{`let h = {}; h["a"] = 1; h["b"] = 2;`, Limits{MaxCollectionSize: 1}, "limit exceeded: HASH of size 2, the maximum is 1"},
+		{"[1, 2, 3]", Limits{MaxCollectionSize: 3}, ""},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalLimited(t, context.Background(), tt.limits, tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("input %q: unexpected error %q", tt.input, errObj.Message)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("input %q: no error returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("input %q: wrong error message. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+		if !errObj.Limit {
+			t.Errorf("input %q: error is not marked as a limit", tt.input)
+		}
+	}
+}
+
+// growing past the limit fails before the oversized value is allocated
+func TestSizeCheckedBeforeAllocating(t *testing.T) {
+	const size = 1 << 20
+	e := New(io.Discard)
+	e.Limits = Limits{MaxCollectionSize: size}
+	env := object.NewEnvironment()
+	env.Set("s", &object.String{Value: strings.Repeat("a", size)})
+	env.Set("a", &object.Array{Elements: make([]object.Object, size)})
+
+	for _, input := range []string{"s + s", "s += s", "push(a, 1)"} {
+		program := parser.New(lexer.NewLexer(input)).ParseProgram()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		evaluated := e.Eval(program, env)
+		runtime.ReadMemStats(&after)
+
+		if err, ok := evaluated.(*object.Error); !ok || !err.Limit {
+			t.Errorf("input %q: want a limit error, got %v", input, evaluated)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated >= size {
+			t.Errorf("input %q: allocated %d bytes before failing", input, allocated)
+		}
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	evaluated := testEvalLimited(t, ctx, Limits{}, "while (yes) {}")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if !errObj.Limit || !strings.Contains(errObj.Message, "deadline exceeded") {
+		t.Errorf("wrong error: %+v", errObj)
+	}
+}
+
+func TestLimitsAreNotCaught(t *testing.T) {
+	evaluated := testEvalLimited(t, context.Background(), Limits{MaxSteps: 500}, "try { while (yes) {} } catch { 1 }")
+	if errObj, ok := evaluated.(*object.Error); !ok || !errObj.Limit {
+		t.Errorf("want a limit error, got %T (%+v)", evaluated, evaluated)
+	}
+}
@@ -0,0 +1,65 @@
+package evaluator
+
+import "monkey/object"
+
+// the bytecode vm does not walk the tree, but it shares what operators, indexing, loops and throw do with the evaluator
+// so a program means the same on both engines
+
+// Infix applies a binary operator to operands that are already evaluated
+func Infix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Prefix applies !, - or ~ to an evaluated operand
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Index reads left[index]
+func Index(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// SetIndex writes left[index] = val
+func SetIndex(left, index, val object.Object) *object.Error {
+	return evalIndexAssignment(left, index, val)
+}
+
+// IsTruthy whether a when or while condition evaluating to obj holds
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// Items what a for loop over obj iterates: the elements of an array, the characters of a string, the keys of a hash
+// an array is copied, so assigning to it inside the loop does not change what is iterated
+func Items(obj object.Object) ([]object.Object, *object.Error) {
+	var items []object.Object
+	switch obj := obj.(type) {
+	case *object.Array:
+		items = append(items, obj.Elements...)
+	case *object.String:
+		for _, r := range obj.Value {
+			items = append(items, &object.String{Value: string(r)})
+		}
+	case *object.Hash:
+		for _, hk := range obj.Order {
+			items = append(items, obj.Pairs[hk].Key)
+		}
+	default:
+		return nil, newError("cannot iterate over %s", obj.Type())
+	}
+	return items, nil
+}
+
+// Thrown the error throw val fails with: val as the message, or the same error again for a caught one
+func Thrown(val object.Object) *object.Error {
+	if caught, ok := val.(*object.ErrorValue); ok {
+		// the calls it unwinds through from here are the ones it would have unwound through uncaught
+		rethrown := *caught.Err
+		rethrown.Stack = append([]object.Frame(nil), caught.Err.Stack...)
+		return &rethrown
+	}
+	err := newError("%s", toString(val))
+	err.Value = val
+	return err
+}
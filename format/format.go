@@ -0,0 +1,168 @@
+// Package format prints scripts in one layout: a space around binary operators and inside the braces of blocks,
+// none inside parentheses, brackets and hash braces or before a call's arguments, and nested lines indented
+// one level deeper than the line opening them
+//
+// it works on the tokens of the cst, so comments stay where they are, and it only ever changes whitespace:
+// line breaks are kept as written, with runs of empty lines shortened to one
+package format
+
+import (
+	"monkey/cst"
+	"monkey/lexer"
+	"monkey/token"
+	"strings"
+)
+
+// Source formats src, indenting with indent per level
+// src has to parse, the first syntax error is returned otherwise
+func Source(src, indent string) (string, error) {
+	root, errs := cst.Parse(src)
+	if len(errs) > 0 {
+		return "", errs[0]
+	}
+
+	p := &printer{indent: indent, blocks: make(map[*cst.Token]bool)}
+	p.findBlocks(root)
+	for _, tok := range root.Tokens() {
+		p.trivia(tok.Leading)
+		if tok.Type != token.EOF {
+			p.token(tok)
+		}
+		p.trivia(tok.Trailing)
+	}
+	if p.out.Len() == 0 {
+		return "", nil
+	}
+	return p.out.String() + "\n", nil
+}
+
+type printer struct {
+	out      strings.Builder
+	indent   string
+	blocks   map[*cst.Token]bool // the braces of blocks, the ones of hashes and types are not padded
+	newlines int                 // line breaks since the last token or comment
+
+	prev  *cst.Token
+	unary bool  // prev is a prefix operator
+	level int   // indentation of the current line
+	open  []int // for every open bracket, the level of the line it was opened on
+}
+
+func (p *printer) findBlocks(node *cst.Node) {
+	for _, child := range node.Children {
+		switch child := child.(type) {
+		case *cst.Token:
+			if node.Kind == "BlockStatement" {
+				p.blocks[child] = true
+			}
+		case *cst.Node:
+			p.findBlocks(child)
+		}
+	}
+}
+
+func (p *printer) trivia(trivia []cst.Trivia) {
+	for _, t := range trivia {
+		switch t.Kind {
+		case cst.Newline:
+			p.newlines++
+		case cst.Comment:
+			// a comment with code before it on its line stays there
+			if p.newlines == 0 && p.out.Len() > 0 {
+				p.out.WriteString(" ")
+			} else {
+				p.lineBreak(p.nextLevel(false))
+			}
+			p.out.WriteString(strings.TrimRight(t.Text, " \t\r"))
+			p.newlines = 0
+		}
+	}
+}
+
+func (p *printer) token(tok *cst.Token) {
+	closing := tok.Type == token.RPAREN || tok.Type == token.RBRACKET || tok.Type == token.RBRACE
+	switch {
+	case p.out.Len() == 0:
+	case p.newlines > 0:
+		p.lineBreak(p.nextLevel(closing))
+	case p.space(tok) || !apart(p.prev, tok):
+		p.out.WriteString(" ")
+	}
+	p.out.WriteString(tok.Text)
+	p.newlines = 0
+
+	switch {
+	case tok.Type == token.LPAREN || tok.Type == token.LBRACKET || tok.Type == token.LBRACE:
+		p.open = append(p.open, p.level)
+	case closing && len(p.open) > 0:
+		p.open = p.open[:len(p.open)-1]
+	}
+	p.unary = isPrefix(tok.Type) && (p.prev == nil || !endsOperand(p.prev.Type))
+	p.prev = tok
+}
+
+// the level of a line starting now, one deeper than the line the innermost open bracket is on,
+// or that line's own level for a line closing it
+func (p *printer) nextLevel(closing bool) int {
+	if len(p.open) == 0 {
+		return 0
+	}
+	level := p.open[len(p.open)-1]
+	if !closing {
+		level++
+	}
+	return level
+}
+
+// ends the line, keeping one empty line when there were more line breaks, and indents the next one
+func (p *printer) lineBreak(level int) {
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+		if p.newlines > 1 {
+			p.out.WriteString("\n")
+		}
+		p.out.WriteString(strings.Repeat(p.indent, level))
+	}
+	p.level = level
+}
+
+// whether tok is printed with a space before it, on the same line as the previous token
+func (p *printer) space(tok *cst.Token) bool {
+	prev := p.prev.Type
+	switch {
+	case p.unary, prev == token.LPAREN, prev == token.LBRACKET:
+		return false
+	case prev == token.LBRACE:
+		return p.blocks[p.prev] && tok.Type != token.RBRACE
+	case tok.Type == token.RBRACE:
+		return p.blocks[tok]
+	case tok.Type == token.RPAREN, tok.Type == token.RBRACKET, tok.Type == token.COMMA,
+		tok.Type == token.SEMICOLON, tok.Type == token.COLON:
+		return false
+	case tok.Type == token.LPAREN:
+		// a call, or the parameters of fn
+		return !endsOperand(prev) && prev != token.FUNCTION
+	case tok.Type == token.LBRACKET:
+		return !endsOperand(prev)
+	}
+	return true
+}
+
+// whether the two tokens still lex as themselves printed without a space in between
+func apart(a, b *cst.Token) bool {
+	first := lexer.NewLexer(a.Text + b.Text).NextToken()
+	return first.Type == a.Type && first.End.Offset == len(a.Text)
+}
+
+// whether a token of type tt can be the last one of an operand, so an operator after it is a binary one
+func endsOperand(tt token.TokenType) bool {
+	switch tt {
+	case token.IDENT, token.INT, token.STRING, token.YES, token.NO, token.RPAREN, token.RBRACKET, token.RBRACE:
+		return true
+	}
+	return false
+}
+
+func isPrefix(tt token.TokenType) bool {
+	return tt == token.MINUS || tt == token.NOT || tt == token.BIT_NOT
+}
@@ -0,0 +1,65 @@
+package format
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let   x=1+2*3;", "let x = 1 + 2 * 3;\n"},
+		{"let f=fn (a,b){send a+b};f (1 ,2)", "let f = fn(a, b) { send a + b }; f(1, 2)\n"},
+		{"- x ; ! yes;~ 1; 2 - -3; [ - 1 ]", "-x; !yes; ~1; 2 - -3; [-1]\n"},
+		{`let h={ "a" : [1,2] [0] }; h [ "a" ]`, `let h = {"a": [1, 2][0]}; h["a"]` + "\n"},
+		{"when(x){}otherwise{ 1 }", "when (x) {} otherwise { 1 }\n"},
+		{"let x : [ int ] = [ ]; let g = fn ( a : int ) -> { str : int } { {} }", "let x: [int] = []; let g = fn(a: int) -> {str: int} { {} }\n"},
+		// lines are kept, indented by how deep they are nested
+		{"let f = fn(x) {\nwhen (x) {\n    send 1\n}\n  x\n}", "let f = fn(x) {\n\twhen (x) {\n\t\tsend 1\n\t}\n\tx\n}\n"},
+		{"print(fn() {\n1\n}, [\n2,\n3\n])", "print(fn() {\n\t1\n}, [\n\t2,\n\t3\n])\n"},
+		{"\n\n\nlet a = 1;\n\n\n\nlet b = 2;\n\n", "let a = 1;\n\nlet b = 2;\n"},
+		// comments stay where they are
+		{"// head\nlet a = 1;   // one\n  // about b\nlet b = 2 // two", "// head\nlet a = 1; // one\n// about b\nlet b = 2 // two\n"},
+		{"fn() {\n// inside\n  1 // last\n}", "fn() {\n\t// inside\n\t1 // last\n}\n"},
+		{"let a = 1\r\n// end\r\n", "let a = 1\n// end\n"},
+	}
+
+	for _, tt := range tests {
+		have, err := Source(tt.input, "\t")
+		if err != nil {
+			t.Errorf("input %q: %v", tt.input, err)
+			continue
+		}
+		if have != tt.expected {
+			t.Errorf("input %q:\nwant %q\nhave %q", tt.input, tt.expected, have)
+		}
+		if again, _ := Source(have, "\t"); again != have {
+			t.Errorf("input %q: formatting twice gives %q", tt.input, again)
+		}
+		if tokens(have) != tokens(tt.input) {
+			t.Errorf("input %q: the tokens changed", tt.input)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source("let = 1", "  "); err == nil || err.Error() != "parser error at 1:5: AFTER \"let\" WANT IDENT, HAVE ASSIGN" {
+		t.Errorf("wrong error %v", err)
+	}
+}
+
+// the tokens of src without their positions
+func tokens(src string) string {
+	var out string
+	for lex := lexer.NewLexer(src); ; {
+		tok := lex.NextToken()
+		if tok.Type == token.EOF {
+			return out
+		}
+		out += string(tok.Type) + " " + tok.Literal + "|"
+	}
+}
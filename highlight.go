@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/highlight"
+	"os"
+)
+
+func init() {
+	commands["highlight"] = runHighlight
+}
+
+// monkey highlight [--html] file, prints file colored for a terminal, or as HTML spans to put in a <pre>
+func runHighlight(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("highlight", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey highlight [--html] file")
+		flags.PrintDefaults()
+	}
+	asHTML := flags.Bool("html", false, "print HTML spans instead of ANSI escapes")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		if err == nil {
+			flags.Usage()
+		}
+		return 2
+	}
+	src, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *asHTML {
+		fmt.Fprint(stdout, highlight.HTML(string(src)))
+	} else {
+		fmt.Fprint(stdout, highlight.ANSI(string(src)))
+	}
+	return 0
+}
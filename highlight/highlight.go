@@ -0,0 +1,162 @@
+// Package highlight colors scripts by what the lexer makes of them, for terminals with ANSI escapes and for
+// web pages with HTML spans
+//
+// every byte of the source ends up in the output in its place, whitespace included, and the source does not have
+// to parse: tokens the lexer rejects are marked illegal and everything after them is colored as usual
+package highlight
+
+import (
+	"html"
+	"monkey/cst"
+	"monkey/token"
+	"strings"
+)
+
+// Category what a piece of source is, as far as coloring goes
+type Category int
+
+const (
+	Plain      Category = iota // whitespace, left as it is
+	Keyword                    // let, fn, when, yes and the rest
+	Identifier                 // names
+	Integer                    // integer literals
+	String                     // string literals, quotes included
+	Operator                   // arithmetic, comparison and assignment, -> too
+	Delimiter                  // commas, semicolons, colons and brackets
+	Comment                    // // up to the end of the line
+	Illegal                    // whatever the lexer rejected
+)
+
+var names = map[Category]string{
+	Plain:      "plain",
+	Keyword:    "keyword",
+	Identifier: "identifier",
+	Integer:    "integer",
+	String:     "string",
+	Operator:   "operator",
+	Delimiter:  "delimiter",
+	Comment:    "comment",
+	Illegal:    "illegal",
+}
+
+// String the category's name, the class of its HTML spans
+func (c Category) String() string {
+	return names[c]
+}
+
+var categories = map[token.TokenType]Category{
+	token.ILLEGAL: Illegal,
+	token.EOF:     Plain,
+
+	token.IDENT:  Identifier,
+	token.INT:    Integer,
+	token.STRING: String,
+
+	token.COMMA:     Delimiter,
+	token.SEMICOLON: Delimiter,
+	token.COLON:     Delimiter,
+	token.LPAREN:    Delimiter,
+	token.RPAREN:    Delimiter,
+	token.LBRACE:    Delimiter,
+	token.RBRACE:    Delimiter,
+	token.LBRACKET:  Delimiter,
+	token.RBRACKET:  Delimiter,
+
+	token.FUNCTION:  Keyword,
+	token.LET:       Keyword,
+	token.CONST:     Keyword,
+	token.WHEN:      Keyword,
+	token.OTHERWISE: Keyword,
+	token.YES:       Keyword,
+	token.NO:        Keyword,
+	token.SEND:      Keyword,
+	token.WHILE:     Keyword,
+	token.FOR:       Keyword,
+	token.IN:        Keyword,
+	token.BREAK:     Keyword,
+	token.CONTINUE:  Keyword,
+	token.THROW:     Keyword,
+	token.TRY:       Keyword,
+	token.CATCH:     Keyword,
+}
+
+// CategoryOf the category of a token type, the ones not listed are operators
+func CategoryOf(tt token.TokenType) Category {
+	if c, ok := categories[tt]; ok {
+		return c
+	}
+	return Operator
+}
+
+// Span a run of source text in one category
+type Span struct {
+	Category Category
+	Text     string
+}
+
+// Spans splits src into spans that concatenate back to src, a token or comment each, whitespace in between
+func Spans(src string) []Span {
+	var spans []Span
+	trivia := func(trivia []cst.Trivia) {
+		for _, t := range trivia {
+			c := Plain
+			if t.Kind == cst.Comment {
+				c = Comment
+			}
+			spans = append(spans, Span{c, t.Text})
+		}
+	}
+	for _, tok := range cst.Scan(src) {
+		trivia(tok.Leading)
+		if tok.Text != "" {
+			spans = append(spans, Span{CategoryOf(tok.Type), tok.Text})
+		}
+		trivia(tok.Trailing)
+	}
+	return spans
+}
+
+// the SGR parameters of every category, plain text gets none
+var colors = map[Category]string{
+	Keyword:    "1;35",
+	Identifier: "34",
+	Integer:    "36",
+	String:     "32",
+	Operator:   "33",
+	Delimiter:  "37",
+	Comment:    "3;90",
+	Illegal:    "1;4;31",
+}
+
+// ANSI src with terminal escapes around every span that is not plain, each one reset right after it
+func ANSI(src string) string {
+	var out strings.Builder
+	for _, span := range Spans(src) {
+		color, ok := colors[span.Category]
+		if !ok {
+			out.WriteString(span.Text)
+			continue
+		}
+		out.WriteString("\x1b[" + color + "m")
+		out.WriteString(span.Text)
+		out.WriteString("\x1b[0m")
+	}
+	return out.String()
+}
+
+// HTML src escaped, with every span that is not plain in a <span class="category">
+// whitespace is kept as it is, so the result belongs in a <pre>
+func HTML(src string) string {
+	var out strings.Builder
+	for _, span := range Spans(src) {
+		text := html.EscapeString(span.Text)
+		if span.Category == Plain {
+			out.WriteString(text)
+			continue
+		}
+		out.WriteString(`<span class="` + span.Category.String() + `">`)
+		out.WriteString(text)
+		out.WriteString("</span>")
+	}
+	return out.String()
+}
@@ -0,0 +1,99 @@
+package highlight
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpans(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []Span
+	}{
+		{"", nil},
+		{
+			"let x = 5; // five\n",
+			[]Span{
+				{Keyword, "let"}, {Plain, " "}, {Identifier, "x"}, {Plain, " "}, {Operator, "="}, {Plain, " "},
+				{Integer, "5"}, {Delimiter, ";"}, {Plain, " "}, {Comment, "// five"}, {Plain, "\n"},
+			},
+		},
+		{
+			"when (yes) {\n\tsend \"a\\\"b\"\n}",
+			[]Span{
+				{Keyword, "when"}, {Plain, " "}, {Delimiter, "("}, {Keyword, "yes"}, {Delimiter, ")"}, {Plain, " "},
+				{Delimiter, "{"}, {Plain, "\n"}, {Plain, "\t"}, {Keyword, "send"}, {Plain, " "}, {String, `"a\"b"`},
+				{Plain, "\n"}, {Delimiter, "}"},
+			},
+		},
+		{
+			"fn(a: int) -> int { a ** 2 >= a }",
+			[]Span{
+				{Keyword, "fn"}, {Delimiter, "("}, {Identifier, "a"}, {Delimiter, ":"}, {Plain, " "}, {Identifier, "int"},
+				{Delimiter, ")"}, {Plain, " "}, {Operator, "->"}, {Plain, " "}, {Identifier, "int"}, {Plain, " "},
+				{Delimiter, "{"}, {Plain, " "}, {Identifier, "a"}, {Plain, " "}, {Operator, "**"}, {Plain, " "},
+				{Integer, "2"}, {Plain, " "}, {Operator, ">="}, {Plain, " "}, {Identifier, "a"}, {Plain, " "},
+				{Delimiter, "}"},
+			},
+		},
+		// what the lexer rejects is marked and the rest goes on as usual
+		{
+			"x @@ 1",
+			[]Span{{Identifier, "x"}, {Plain, " "}, {Illegal, "@@"}, {Plain, " "}, {Integer, "1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		have := Spans(tt.src)
+		if !reflect.DeepEqual(have, tt.want) {
+			t.Errorf("%q:\nwant %v\nhave %v", tt.src, tt.want, have)
+		}
+	}
+}
+
+// nothing gets lost or added, however broken the source
+func TestSpansCoverSource(t *testing.T) {
+	tests := []string{
+		"   \n\t",
+		"// only a comment",
+		"let s = \"unterminated",
+		"let f = fn(a, b) { // trailing\r\n  send a + b;\r\n};\n\n\n",
+		"{1: [2, 3]}[1][0] % 2 << 1 & ~3 | 4 ^ 5",
+		"é = 1 # 2",
+		"a\x00b",
+		"let s = \"a\x00b\";\n// c\x00d\nlet t = 1\x00",
+	}
+
+	for _, src := range tests {
+		var b strings.Builder
+		for _, span := range Spans(src) {
+			if span.Text == "" {
+				t.Errorf("%q: empty %v span", src, span.Category)
+			}
+			b.WriteString(span.Text)
+		}
+		if have := b.String(); have != src {
+			t.Errorf("want %q, have %q", src, have)
+		}
+	}
+}
+
+func TestANSI(t *testing.T) {
+	have := ANSI("let x = 1; // one\n@")
+	want := "\x1b[1;35mlet\x1b[0m \x1b[34mx\x1b[0m \x1b[33m=\x1b[0m \x1b[36m1\x1b[0m\x1b[37m;\x1b[0m " +
+		"\x1b[3;90m// one\x1b[0m\n\x1b[1;4;31m@\x1b[0m"
+	if have != want {
+		t.Errorf("want %q\nhave %q", want, have)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	have := HTML("when (a < b) {\n  \"<&>\"\n}")
+	want := `<span class="keyword">when</span> <span class="delimiter">(</span><span class="identifier">a</span> ` +
+		`<span class="operator">&lt;</span> <span class="identifier">b</span><span class="delimiter">)</span> ` +
+		"<span class=\"delimiter\">{</span>\n  <span class=\"string\">&#34;&lt;&amp;&gt;&#34;</span>\n<span class=\"delimiter\">}</span>"
+	if have != want {
+		t.Errorf("want %q\nhave %q", want, have)
+	}
+}
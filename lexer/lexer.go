@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey/token"
+	"unicode/utf8"
 )
 
 type Lexer struct {
@@ -9,6 +10,10 @@ type Lexer struct {
 	position int
 	nextPos  int
 	char     byte
+
+	// line and column of char, counted from 1
+	line   int
+	column int
 }
 
 var mapTokenType = map[byte]token.TokenType{
@@ -17,6 +22,8 @@ var mapTokenType = map[byte]token.TokenType{
 	'}': token.RBRACE,
 	'(': token.LPAREN,
 	')': token.RPAREN,
+	'[': token.LBRACKET,
+	']': token.RBRACKET,
 	'/': token.DIVIDE,
 
 	//operators
@@ -27,15 +34,31 @@ var mapTokenType = map[byte]token.TokenType{
 	'!': token.NOT,
 	'<': token.LESSTHAN,
 	'>': token.MORETHAN,
+	'%': token.MODULO,
+	'&': token.BIT_AND,
+	'|': token.BIT_OR,
+	'^': token.BIT_XOR,
+	'~': token.BIT_NOT,
 
 	// separators
 	',': token.COMMA,
 	';': token.SEMICOLON,
+	':': token.COLON,
 }
 
 func (lex *Lexer) NextToken() token.Token {
 	lex.skipWhitespace()
 
+	pos := token.Position{Offset: lex.position, Line: lex.line, Column: lex.column}
+	tok := lex.nextToken()
+	tok.Pos, tok.End = pos, token.Position{Offset: lex.position, Line: lex.line, Column: lex.column}
+	if tok.Type == token.EOF {
+		tok.End = pos
+	}
+	return tok
+}
+
+func (lex *Lexer) nextToken() token.Token {
 	var tok token.Token
 	if tt, ok := mapTokenType[lex.char]; ok {
 		switch {
@@ -49,11 +72,33 @@ func (lex *Lexer) NextToken() token.Token {
 			tok = token.Token{Type: token.EQ_OR_LESS, Literal: lex.readTwice()}
 		case token.MORETHAN == tt && token.ASSIGN == mapTokenType[lex.peekChar()]:
 			tok = token.Token{Type: token.EQ_OR_MORE, Literal: lex.readTwice()}
+		case token.MULTIPLY == tt && token.MULTIPLY == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.POWER, Literal: lex.readTwice()}
+		case token.LESSTHAN == tt && token.LESSTHAN == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.SHIFT_LEFT, Literal: lex.readTwice()}
+		case token.MORETHAN == tt && token.MORETHAN == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.SHIFT_RIGHT, Literal: lex.readTwice()}
+		case token.PLUS == tt && token.ASSIGN == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.PLUS_ASSIGN, Literal: lex.readTwice()}
+		case token.MINUS == tt && token.ASSIGN == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.MINUS_ASSIGN, Literal: lex.readTwice()}
+		case token.MINUS == tt && token.MORETHAN == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.ARROW, Literal: lex.readTwice()}
+		case token.MULTIPLY == tt && token.ASSIGN == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.MULTIPLY_ASSIGN, Literal: lex.readTwice()}
+		case token.DIVIDE == tt && token.ASSIGN == mapTokenType[lex.peekChar()]:
+			tok = token.Token{Type: token.DIVIDE_ASSIGN, Literal: lex.readTwice()}
 		}
 	} else {
 		switch lex.char {
 		case 0:
 			tok.Type, tok.Literal = token.EOF, ""
+		case '"':
+			if str, ok := lex.readString(); ok {
+				tok.Type, tok.Literal = token.STRING, str
+			} else {
+				tok.Type, tok.Literal, tok.Reason = token.ILLEGAL, str, "unterminated string"
+			}
 		default:
 			if isLetter(lex.char) {
 				tok.Literal = lex.readIdentifier()
@@ -64,7 +109,7 @@ func (lex *Lexer) NextToken() token.Token {
 				tok.Type = token.INT
 				return tok
 			} else {
-				tok = newToken(token.ILLEGAL, lex.char)
+				return lex.readIllegal()
 			}
 		}
 	}
@@ -80,6 +125,63 @@ func (lex *Lexer) readTwice() string {
 	return string(first) + string(second)
 }
 
+// reads "...", curChar is on the opening quote and ends up on the closing one
+// returns the unescaped content and whether the closing quote was found before EOF
+func (lex *Lexer) readString() (string, bool) {
+	var out []byte
+	for {
+		lex.readChar()
+		switch lex.char {
+		case '"':
+			return string(out), true
+		case 0:
+			return string(out), false
+		case '\\':
+			lex.readChar()
+			switch lex.char {
+			case 'n':
+				out = append(out, '\n')
+			case 't':
+				out = append(out, '\t')
+			case 0:
+				return string(out), false
+			default: // \" and \\ are just the character itself
+				out = append(out, lex.char)
+			}
+		default:
+			out = append(out, lex.char)
+		}
+	}
+}
+
+// reads the whole run of characters that cannot start a token, so "@@@" is one ILLEGAL token and not three
+func (lex *Lexer) readIllegal() token.Token {
+	position := lex.position
+	for lex.char != 0 && !lex.startsToken(lex.char) {
+		lex.readChar()
+	}
+	run := lex.input[position:lex.position]
+	return token.Token{Type: token.ILLEGAL, Literal: run, Reason: describeIllegal(run)}
+}
+
+// whether b can start a token (or separate two of them)
+func (lex *Lexer) startsToken(b byte) bool {
+	_, known := mapTokenType[b]
+	return known || isLetter(b) || isDigit(b) || isWhitespace(b) || b == '"'
+}
+
+func describeIllegal(run string) string {
+	for i := 0; i < len(run); i++ {
+		if run[i] >= utf8.RuneSelf {
+			return "non-ASCII characters are only allowed inside strings"
+		}
+	}
+	if len(run) == 1 {
+		return "unexpected character"
+	}
+	return "unexpected characters"
+}
+
 func (lex *Lexer) readDigit() string {
 	position := lex.position
 	for isDigit(lex.char) {
@@ -92,21 +194,44 @@ func isDigit(b byte) bool {
 	return '0' <= b && b <= '9'
 }
 
+// skips whitespace and // comments, a comment runs to the end of its line
 func (lex *Lexer) skipWhitespace() {
-	for lex.char == ' ' || lex.char == '\t' || lex.char == '\n' || lex.char == '\r' {
-		lex.readChar()
+	for {
+		switch {
+		case isWhitespace(lex.char):
+			lex.readChar()
+		case lex.char == '/' && lex.peekChar() == '/':
+			for lex.char != '\n' && lex.char != 0 {
+				lex.readChar()
+			}
+		default:
+			return
+		}
 	}
 }
 
+func isWhitespace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
 // map of known keywords
 var keywords = map[string]token.TokenType{
 	"let":       token.LET,
+	"const":     token.CONST,
 	"fn":        token.FUNCTION,
 	"yes":       token.YES,
 	"no":        token.NO,
 	"when":      token.WHEN,
 	"otherwise": token.OTHERWISE,
 	"send":      token.SEND,
+	"while":     token.WHILE,
+	"for":       token.FOR,
+	"in":        token.IN,
+	"break":     token.BREAK,
+	"continue":  token.CONTINUE,
+	"throw":     token.THROW,
+	"try":       token.TRY,
+	"catch":     token.CATCH,
 }
 
 // returns whether the string is among known keywords
@@ -137,6 +262,17 @@ func newToken(tt token.TokenType, char byte) token.Token {
 }
 
 func (lex *Lexer) readChar() {
+	// at the end already, the position stays right after the last character
+	if lex.position >= len(lex.input) && lex.nextPos > lex.position {
+		return
+	}
+	// a new line starts right after a newline
+	if lex.char == '\n' {
+		lex.line++
+		lex.column = 0
+	}
+	lex.column++
+
 	if lex.nextPos >= len(lex.input) {
 		lex.char = 0
 	} else {
@@ -154,7 +290,7 @@ func (lex *Lexer) peekChar() byte {
 }
 
 func NewLexer(input string) *Lexer {
-	lex := &Lexer{input: input}
+	lex := &Lexer{input: input, line: 1}
 	lex.readChar()
 	return lex
 }
@@ -134,3 +134,220 @@ func TestNextToken(t *testing.T) {
 		l.Infof("parsed token %v", tok)
 	}
 }
+
+func TestNextTokenOperators(t *testing.T) {
+	input := `10 % 3
+	2 ** 8
+	6 & 3 | 1 ^ 4
+	1 << 4 >> 2
+	~7
+	2 * 3 < 4
+	x += 1 -= 2 *= 3 /= 4
+	{1: [2]}
+	) -> int - -1`
+
+	validations := []validation{
+		{token.INT, "10"},
+		{token.MODULO, "%"},
+		{token.INT, "3"},
+		{token.INT, "2"},
+		{token.POWER, "**"},
+		{token.INT, "8"},
+		{token.INT, "6"},
+		{token.BIT_AND, "&"},
+		{token.INT, "3"},
+		{token.BIT_OR, "|"},
+		{token.INT, "1"},
+		{token.BIT_XOR, "^"},
+		{token.INT, "4"},
+		{token.INT, "1"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "4"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "2"},
+		{token.BIT_NOT, "~"},
+		{token.INT, "7"},
+		{token.INT, "2"},
+		{token.MULTIPLY, "*"},
+		{token.INT, "3"},
+		{token.LESSTHAN, "<"},
+		{token.INT, "4"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.MULTIPLY_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.DIVIDE_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.LBRACE, "{"},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.LBRACKET, "["},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.RBRACE, "}"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
+		{token.MINUS, "-"},
+		{token.MINUS, "-"},
+		{token.INT, "1"},
+		{token.EOF, ""},
+	}
+
+	lex := NewLexer(input)
+	for i, v := range validations {
+		tok := lex.NextToken()
+		if tok.Type != v.expectedType {
+			t.Fatalf("tests[%v] - INVALID tokenType want=%v have=%v for token=%v", i, v.expectedType, tok.Type, tok)
+		}
+		if tok.Literal != v.expectedLiteral {
+			t.Fatalf("tests[%v] - INVALID tokenLiteral want=%v have=%v", i, v.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenLoopsAndStrings(t *testing.T) {
+	input := `while (yes) { break; continue; }
+	for (c in "a \"b\"\n") {}
+	try { throw e } catch (e) {}
+	"unterminated`
+
+	validations := []validation{
+		{token.WHILE, "while"},
+		{token.LPAREN, "("},
+		{token.YES, "yes"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.BREAK, "break"},
+		{token.SEMICOLON, ";"},
+		{token.CONTINUE, "continue"},
+		{token.SEMICOLON, ";"},
+		{token.RBRACE, "}"},
+		{token.FOR, "for"},
+		{token.LPAREN, "("},
+		{token.IDENT, "c"},
+		{token.IN, "in"},
+		{token.STRING, "a \"b\"\n"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.TRY, "try"},
+		{token.LBRACE, "{"},
+		{token.THROW, "throw"},
+		{token.IDENT, "e"},
+		{token.RBRACE, "}"},
+		{token.CATCH, "catch"},
+		{token.LPAREN, "("},
+		{token.IDENT, "e"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.ILLEGAL, "unterminated"},
+		{token.EOF, ""},
+	}
+
+	lex := NewLexer(input)
+	for i, v := range validations {
+		tok := lex.NextToken()
+		if tok.Type != v.expectedType {
+			t.Fatalf("tests[%v] - INVALID tokenType want=%v have=%v for token=%v", i, v.expectedType, tok.Type, tok)
+		}
+		if tok.Literal != v.expectedLiteral {
+			t.Fatalf("tests[%v] - INVALID tokenLiteral want=%q have=%q", i, v.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIllegalTokens(t *testing.T) {
+	input := `let x = @@@ + 1;
+$ é "open`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedReason  string
+	}{
+		{token.LET, "let", ""},
+		{token.IDENT, "x", ""},
+		{token.ASSIGN, "=", ""},
+		{token.ILLEGAL, "@@@", "unexpected characters"},
+		{token.PLUS, "+", ""},
+		{token.INT, "1", ""},
+		{token.SEMICOLON, ";", ""},
+		{token.ILLEGAL, "$", "unexpected character"},
+		{token.ILLEGAL, "é", "non-ASCII characters are only allowed inside strings"},
+		{token.ILLEGAL, "open", "unterminated string"},
+		{token.EOF, "", ""},
+	}
+
+	lex := NewLexer(input)
+	for i, tt := range tests {
+		tok := lex.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%v] - want %v %q, have %v %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Reason != tt.expectedReason {
+			t.Fatalf("tests[%v] - INVALID reason want=%q have=%q", i, tt.expectedReason, tok.Reason)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  send x +\n\t10"
+
+	expected := []token.Position{
+		{Offset: 0, Line: 1, Column: 1},   // let
+		{Offset: 4, Line: 1, Column: 5},   // x
+		{Offset: 6, Line: 1, Column: 7},   // =
+		{Offset: 8, Line: 1, Column: 9},   // 5
+		{Offset: 9, Line: 1, Column: 10},  // ;
+		{Offset: 13, Line: 2, Column: 3},  // send
+		{Offset: 18, Line: 2, Column: 8},  // x
+		{Offset: 20, Line: 2, Column: 10}, // +
+		{Offset: 23, Line: 3, Column: 2},  // 10
+		{Offset: 25, Line: 3, Column: 4},  // EOF
+	}
+
+	lex := NewLexer(input)
+	for i, pos := range expected {
+		tok := lex.NextToken()
+		if tok.Pos != pos {
+			t.Fatalf("tests[%v] - INVALID position for %q want=%+v have=%+v", i, tok.Literal, pos, tok.Pos)
+		}
+	}
+}
+
+func TestTokenEnds(t *testing.T) {
+	input := "xy <= \"a\\\"\nb\";"
+
+	expected := []token.Position{
+		{Offset: 2, Line: 1, Column: 3},  // xy
+		{Offset: 5, Line: 1, Column: 6},  // <=
+		{Offset: 13, Line: 2, Column: 3}, // "a\"\nb", escapes and the closing quote included
+		{Offset: 14, Line: 2, Column: 4}, // ;
+		{Offset: 14, Line: 2, Column: 4}, // EOF is empty
+	}
+
+	lex := NewLexer(input)
+	for i, end := range expected {
+		tok := lex.NextToken()
+		if tok.End != end {
+			t.Fatalf("tests[%v] - INVALID end for %q want=%+v have=%+v", i, tok.Literal, end, tok.End)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// first\nx // second\n/ y //"
+
+	expected := []token.TokenType{token.IDENT, token.DIVIDE, token.IDENT, token.EOF}
+	lex := NewLexer(input)
+	for i, tt := range expected {
+		if tok := lex.NextToken(); tok.Type != tt {
+			t.Fatalf("tests[%v] - want %v, have %v %q", i, tt, tok.Type, tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey/evaluator"
+	"monkey/lint"
+	"os"
+)
+
+func init() {
+	commands["lint"] = runLint
+}
+
+// the config lint reads when there is no --config, if it exists
+const lintConfig = ".monkeylint.json"
+
+// monkey lint [--config file] file..., reports likely mistakes, the exit code is 1 when anything was found
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey lint [--config file] file...")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "JSON file switching rules on and off, "+lintConfig+" when there is one")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return 2
+	}
+
+	cfg, err := readLintConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	builtins := evaluator.New(io.Discard).BuiltinNames()
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			status = 1
+			continue
+		}
+		findings, errs := lint.Lint(string(src), cfg, builtins...)
+		for _, err := range errs {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			status = 1
+		}
+		for _, f := range findings {
+			fmt.Fprintf(stdout, "%s: %v\n", path, f)
+			status = 1
+		}
+	}
+	return status
+}
+
+// the config at path, without one the config in the working directory or the defaults when there is none
+func readLintConfig(path string) (lint.Config, error) {
+	if path != "" {
+		return lint.ReadConfig(path)
+	}
+	cfg, err := lint.ReadConfig(lintConfig)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lint.DefaultConfig(), nil
+	}
+	return cfg, err
+}
@@ -0,0 +1,151 @@
+// Package lint reports code that runs but is probably not what its author meant: bindings nobody reads,
+// names hiding others, statements that can never run, comparisons and conditions with a foregone result
+// and functions grown too long
+//
+// every rule can be switched off in a Config, and single findings can be silenced where they are with a comment:
+// // lint:ignore silences every rule, // lint:ignore unused, shadow just the ones listed
+// a comment after code applies to its own line, a comment on a line of its own to the next line of code
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/ast"
+	"monkey/cst"
+	"monkey/token"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Rules the names of every rule
+var Rules = []string{"unused", "shadow", "unreachable", "self-compare", "constant-condition", "function-length"}
+
+// Finding something a rule reported, Pos is where the code it is about starts
+type Finding struct {
+	Pos     token.Position
+	Rule    string
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%v: %s (%s)", f.Pos, f.Message, f.Rule)
+}
+
+// Config which rules run, rules it does not mention do
+// as JSON, like {"rules": {"shadow": false}, "max_function_lines": 30}
+type Config struct {
+	Rules            map[string]bool `json:"rules"`
+	MaxFunctionLines int             `json:"max_function_lines"` // longer functions are reported by function-length
+}
+
+// DefaultConfig every rule on, functions up to 50 lines
+func DefaultConfig() Config {
+	return Config{Rules: map[string]bool{}, MaxFunctionLines: 50}
+}
+
+// ReadConfig reads the JSON file at path over the defaults, unknown rules and fields are errors
+func ReadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	for rule := range cfg.Rules {
+		if !known(rule) {
+			return cfg, fmt.Errorf("%s: unknown rule %q", path, rule)
+		}
+	}
+	if cfg.MaxFunctionLines <= 0 {
+		return cfg, fmt.Errorf("%s: max_function_lines has to be positive, is %d", path, cfg.MaxFunctionLines)
+	}
+	return cfg, nil
+}
+
+func known(rule string) bool {
+	for _, r := range Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg Config) enabled(rule string) bool {
+	on, ok := cfg.Rules[rule]
+	return !ok || on
+}
+
+// Lint runs the rules cfg enables over src, builtins are the names that exist without being declared
+// src has to parse, otherwise the syntax errors are returned and nothing is linted
+func Lint(src string, cfg Config, builtins ...string) ([]Finding, []error) {
+	root, errs := cst.Parse(src)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	program := root.AST.(*ast.Program)
+
+	l := &linter{cfg: cfg, builtinNames: builtins, builtins: make(map[string]bool)}
+	for _, name := range builtins {
+		l.builtins[name] = true
+	}
+	l.unused(program)
+	l.scope = &scope{names: make(map[string]*ast.Identifier)}
+	l.shadowing(program)
+	ast.Inspect(program, l.inspect)
+
+	ignored := ignores(root.Tokens())
+	var findings []Finding
+	for _, f := range l.findings {
+		if rules, ok := ignored[f.Pos.Line]; ok && (rules == nil || rules[f.Rule]) {
+			continue
+		}
+		findings = append(findings, f)
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Pos.Offset < findings[j].Pos.Offset })
+	return findings, nil
+}
+
+// ignores the lines lint:ignore comments apply to, with the rules they silence there, nil for all of them
+func ignores(tokens []*cst.Token) map[int]map[string]bool {
+	ignored := make(map[int]map[string]bool)
+	add := func(line int, comment cst.Trivia) {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+		if comment.Kind != cst.Comment || !strings.HasPrefix(text, "lint:ignore") {
+			return
+		}
+		names := strings.FieldsFunc(strings.TrimPrefix(text, "lint:ignore"), func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' })
+		if len(names) == 0 {
+			ignored[line] = nil
+			return
+		}
+		rules, ok := ignored[line]
+		if ok && rules == nil {
+			return
+		}
+		if !ok {
+			rules = make(map[string]bool)
+			ignored[line] = rules
+		}
+		for _, name := range names {
+			rules[name] = true
+		}
+	}
+	for _, tok := range tokens {
+		// leading comments are on lines of their own, trailing ones follow code on the line the token ends on
+		for _, t := range tok.Leading {
+			add(tok.Pos.Line, t)
+		}
+		for _, t := range tok.Trailing {
+			add(tok.End.Line, t)
+		}
+	}
+	return ignored
+}
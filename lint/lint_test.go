@@ -0,0 +1,178 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lints input, the findings as strings
+func lint(t *testing.T, input string, cfg Config) []string {
+	t.Helper()
+	findings, errs := Lint(input, cfg, "len", "print")
+	if len(errs) > 0 {
+		t.Fatalf("input %q: %v", input, errs)
+	}
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.String())
+	}
+	return out
+}
+
+func only(rule string) Config {
+	cfg := DefaultConfig()
+	for _, r := range Rules {
+		cfg.Rules[r] = r == rule
+	}
+	return cfg
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		rule     string
+		input    string
+		expected []string
+	}{
+		{"unused", "let x = 1; print(x)", nil},
+		{"unused", "let x = 1;", []string{"1:5: x is declared and never used (unused)"}},
+		{"unused", "let x = 1; x = 2; x += 3;", []string{"1:5: x is declared and never used (unused)"}},
+		{"unused", "let f = fn() { let y = 2; 3 }; f()", []string{"1:20: y is declared and never used (unused)"}},
+		{"unused", "let x = 1; let x = 2; x", []string{"1:5: x is declared and never used (unused)"}},
+		{"unused", "let _ = 1; let _skip = 2;", nil},
+		{"unused", "let test_sum = fn() { let test_local = 1 };", []string{"1:27: test_local is declared and never used (unused)"}},
+		// used before the declaration, by a function that runs later
+		{"unused", "let f = fn() { g() }; let g = fn() { 1 }; f()", nil},
+		{"unused", "let f = fn(n) { f(n) };", nil},
+
+		{"shadow", "let x = 1; let f = fn() { let x = 2; x }; f()", []string{"1:31: x shadows the declaration at 1:5 (shadow)"}},
+		{"shadow", "let x = 1; let f = fn(x) { x };", []string{"1:23: x shadows the declaration at 1:5 (shadow)"}},
+		{"shadow", "let x = [1]; for (x in x) { x }", []string{"1:19: x shadows the declaration at 1:5 (shadow)"}},
+		{"shadow", "let e = 1; try { 1 } catch (e) { e }", []string{"1:29: e shadows the declaration at 1:5 (shadow)"}},
+		{"shadow", "let len = fn(s) { 0 };", []string{"1:5: len shadows the builtin of that name (shadow)"}},
+		// when blocks share their scope, declaring again there is not shadowing, and neither is a later declaration
+		{"shadow", "let x = 1; when (x) { let x = 2 }; let x = 3;", nil},
+		{"shadow", "let f = fn() { let x = 2 }; let x = 1;", nil},
+		{"shadow", "let f = fn(a, b) { a }; let g = fn(a) { a };", nil},
+
+		{"unreachable", "let f = fn() { send 1; print(2); print(3) };", []string{"1:24: unreachable code after send (unreachable)"}},
+		{"unreachable", "while (yes) { break; 1 }", []string{"1:22: unreachable code after break (unreachable)"}},
+		{"unreachable", "for (x in [1]) { continue\nx }", []string{"2:1: unreachable code after continue (unreachable)"}},
+		{"unreachable", "throw \"no\"; 1", []string{"1:13: unreachable code after throw (unreachable)"}},
+		{"unreachable", "let f = fn() { when (yes) { send 1 } 2 };", nil},
+
+		{"self-compare", "let x = 1; x == x", []string{"1:12: x == x compares a value to itself, always yes (self-compare)"}},
+		{"self-compare", "let a = [1]; (a[0]) < a[0]", []string{"1:14: (a[0]) < (a[0]) compares a value to itself, always no (self-compare)"}},
+		{"self-compare", "let x = 1; x + x; x == 1", nil},
+		{"self-compare", "let f = fn() { 1 }; f() == f()", nil},
+		// a new array every time, so [1] == [1] is no and [1] < [1] a type error
+		{"self-compare", "[1] == [1]; [1] < [1]; {1: 2} != {1: 2}; fn() { 1 } == fn() { 1 }", nil},
+		{"self-compare", "let a = [1]; a == a; a[0] + 1 >= a[0] + 1", []string{
+			"1:14: a == a compares a value to itself, always yes (self-compare)",
+			"1:22: ((a[0]) + 1) >= ((a[0]) + 1) compares a value to itself, always yes (self-compare)",
+		}},
+
+		{"constant-condition", "when (yes) { 1 }", []string{"1:7: when condition is constant, only one branch ever runs (constant-condition)"}},
+		{"constant-condition", "when ((!no)) { 1 }", []string{"1:7: when condition is constant, only one branch ever runs (constant-condition)"}},
+		{"constant-condition", "when (1 < 2) { 1 } otherwise { 2 }", []string{"1:7: when condition is constant, only one branch ever runs (constant-condition)"}},
+		{"constant-condition", "let x = 1; when (x < 2) { 1 }; while (yes) { break }", nil},
+
+		{"function-length", "let f = fn() {\n" + strings.Repeat("1;\n", 48) + "};", nil},
+		{"function-length", "let f = fn() {\n" + strings.Repeat("1;\n", 49) + "};", []string{"1:9: function is 51 lines long, more than 50 (function-length)"}},
+	}
+
+	for _, tt := range tests {
+		have := lint(t, tt.input, only(tt.rule))
+		if strings.Join(have, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s, input %q:\nwant %q\nhave %q", tt.rule, tt.input, tt.expected, have)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	input := "let f = fn() {\n  let x = 1;\n  send 2;\n  3\n};"
+	all := []string{
+		"1:5: f is declared and never used (unused)",
+		"1:9: function is 5 lines long, more than 4 (function-length)",
+		"2:7: x is declared and never used (unused)",
+		"4:3: unreachable code after send (unreachable)",
+	}
+	cfg := DefaultConfig()
+	cfg.MaxFunctionLines = 4
+	if have := lint(t, input, cfg); strings.Join(have, "\n") != strings.Join(all, "\n") {
+		t.Errorf("want %q\nhave %q", all, have)
+	}
+
+	cfg.Rules["unused"] = false
+	if have := lint(t, input, cfg); strings.Join(have, "\n") != strings.Join([]string{all[1], all[3]}, "\n") {
+		t.Errorf("with unused off, have %q", have)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		json string
+		err  string
+	}{
+		{`{"rules": {"shadow": false, "unused": true}, "max_function_lines": 30}`, ""},
+		{`{}`, ""},
+		{`{"rules": {"shade": false}}`, `unknown rule "shade"`},
+		{`{"max_lines": 30}`, `unknown field "max_lines"`},
+		{`{"max_function_lines": 0}`, "max_function_lines has to be positive, is 0"},
+		{`{"rules": `, "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "lint.json")
+		if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := ReadConfig(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: want an error about %q, have %v", tt.json, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+		}
+		if tt.json == `{}` && (cfg.MaxFunctionLines != 50 || !cfg.enabled("shadow")) {
+			t.Errorf("%s: want the defaults, have %+v", tt.json, cfg)
+		}
+		if tt.json != `{}` && (cfg.MaxFunctionLines != 30 || cfg.enabled("shadow") || !cfg.enabled("unused") || !cfg.enabled("unreachable")) {
+			t.Errorf("%s: have %+v", tt.json, cfg)
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; // lint:ignore", nil},
+		{"let x = 1; // lint:ignore unused", nil},
+		{"let x = 1; // lint:ignore shadow", []string{"1:5: x is declared and never used (unused)"}},
+		{"// lint:ignore self-compare, unused\n\nlet x = 1; x == x", nil},
+		{"let x = 1; // lint:ignore\nlet y = 2;", []string{"2:5: y is declared and never used (unused)"}},
+		{"let f = fn(len) { // lint:ignore shadow\n  send len\n  1 // lint:ignore unreachable\n};", []string{"1:5: f is declared and never used (unused)"}},
+		// a lint:ignore in a string is not a comment
+		{"let x = \"// lint:ignore\";", []string{"1:5: x is declared and never used (unused)"}},
+	}
+
+	for _, tt := range tests {
+		have := lint(t, tt.input, DefaultConfig())
+		if strings.Join(have, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("input %q:\nwant %q\nhave %q", tt.input, tt.expected, have)
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	findings, errs := Lint("let = 1; let x = 2;", DefaultConfig())
+	if len(errs) == 0 || findings != nil {
+		t.Errorf("want only syntax errors, have %v %v", findings, errs)
+	}
+}
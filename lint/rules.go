@@ -0,0 +1,210 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/resolver"
+	"strings"
+)
+
+type linter struct {
+	cfg          Config
+	builtinNames []string
+	builtins     map[string]bool
+	findings     []Finding
+	scope        *scope
+}
+
+func (l *linter) report(node ast.Node, rule, format string, a ...interface{}) {
+	if l.cfg.enabled(rule) {
+		l.findings = append(l.findings, Finding{Pos: node.Pos(), Rule: rule, Message: fmt.Sprintf(format, a...)})
+	}
+}
+
+// unused: lets nothing reads, assigning to a binding is not reading it
+// names starting with _ are meant to go unused, and test_ functions at the top level are what monkey test calls
+func (l *linter) unused(program *ast.Program) {
+	r := resolver.New(l.builtinNames...)
+	r.Resolve(program)
+	defs := r.Definitions()
+
+	targets := make(map[*ast.Identifier]bool)
+	ast.Inspect(program, func(node ast.Node) bool {
+		if assign, ok := node.(*ast.AssignStatement); ok {
+			if id, ok := assign.Target.(*ast.Identifier); ok {
+				targets[id] = true
+			}
+		}
+		return true
+	})
+	read := make(map[*ast.Identifier]bool)
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && strings.HasPrefix(let.Name.Value, "test_") {
+			read[let.Name] = true
+		}
+	}
+	for id, def := range defs {
+		if id != def && !targets[id] {
+			read[def] = true
+		}
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if let, ok := node.(*ast.LetStatement); ok && !read[let.Name] && !strings.HasPrefix(let.Name.Value, "_") {
+			l.report(let.Name, "unused", "%s is declared and never used", let.Name.Value)
+		}
+		return true
+	})
+}
+
+// scope the names declared in one environment of the evaluator, the same ones the resolver has
+type scope struct {
+	names map[string]*ast.Identifier
+	outer *scope
+}
+
+func (l *linter) inScope(fn func()) {
+	l.scope = &scope{names: make(map[string]*ast.Identifier), outer: l.scope}
+	defer func() { l.scope = l.scope.outer }()
+	fn()
+}
+
+// shadow: declarations hiding a name of an outer scope or a builtin, declaring a name again in its own scope is fine
+func (l *linter) declare(name *ast.Identifier) {
+	if _, ok := l.scope.names[name.Value]; !ok {
+		if outer := l.scope.outer.lookup(name.Value); outer != nil {
+			l.report(name, "shadow", "%s shadows the declaration at %v", name.Value, outer.Pos())
+		} else if l.builtins[name.Value] {
+			l.report(name, "shadow", "%s shadows the builtin of that name", name.Value)
+		}
+	}
+	l.scope.names[name.Value] = name
+}
+
+func (s *scope) lookup(name string) *ast.Identifier {
+	for ; s != nil; s = s.outer {
+		if id, ok := s.names[name]; ok {
+			return id
+		}
+	}
+	return nil
+}
+
+// walks node declaring names in the order the evaluator does, in the scopes it creates
+func (l *linter) shadowing(node ast.Node) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			l.shadowing(node.Value)
+			l.declare(node.Name)
+		case *ast.FunctionLiteral:
+			l.inScope(func() {
+				for _, param := range node.Parameters {
+					l.declare(param)
+				}
+				l.shadowing(node.Body)
+			})
+		case *ast.WhileStatement:
+			l.shadowing(node.Condition)
+			l.inScope(func() { l.shadowing(node.Body) })
+		case *ast.ForStatement:
+			l.shadowing(node.Iterable)
+			l.inScope(func() {
+				l.declare(node.Variable)
+				l.shadowing(node.Body)
+			})
+		case *ast.TryExpression:
+			l.shadowing(node.Block)
+			l.inScope(func() {
+				if node.Param != nil {
+					l.declare(node.Param)
+				}
+				l.shadowing(node.Handler)
+			})
+		default:
+			return true
+		}
+		return false
+	})
+}
+
+// the rules that look at one node at a time
+func (l *linter) inspect(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.Program:
+		l.unreachable(node.Statements)
+	case *ast.BlockStatement:
+		l.unreachable(node.Statements)
+	case *ast.InfixExpression:
+		l.selfCompare(node)
+	case *ast.WhenExpression:
+		if constant(node.Condition) {
+			l.report(node.Condition, "constant-condition", "when condition is constant, only one branch ever runs")
+		}
+	case *ast.FunctionLiteral:
+		if lines := node.End().Line - node.Pos().Line + 1; lines > l.cfg.MaxFunctionLines {
+			l.report(node, "function-length", "function is %d lines long, more than %d", lines, l.cfg.MaxFunctionLines)
+		}
+	}
+	return true
+}
+
+// unreachable: statements after one that always leaves the block, only the first of them is reported
+func (l *linter) unreachable(stmts []ast.Statement) {
+	for i := 0; i+1 < len(stmts); i++ {
+		var leaves string
+		switch stmts[i].(type) {
+		case *ast.SendStatement:
+			leaves = "send"
+		case *ast.ThrowStatement:
+			leaves = "throw"
+		case *ast.BreakStatement:
+			leaves = "break"
+		case *ast.ContinueStatement:
+			leaves = "continue"
+		default:
+			continue
+		}
+		l.report(stmts[i+1], "unreachable", "unreachable code after %s", leaves)
+		return
+	}
+}
+
+// what comparing a value to itself always gives
+var selfComparisons = map[string]string{"==": "yes", "<=": "yes", ">=": "yes", "!=": "no", "<": "no", ">": "no"}
+
+// self-compare: both sides are spelled the same and neither calls nor builds anything, so they are the same value
+func (l *linter) selfCompare(node *ast.InfixExpression) {
+	result, ok := selfComparisons[node.Operator]
+	if !ok || node.Left.ToString() != node.Right.ToString() || makesValues(node.Left) {
+		return
+	}
+	l.report(node, "self-compare", "%s %s %s compares a value to itself, always %s", node.Left.ToString(), node.Operator, node.Right.ToString(), result)
+}
+
+// whether evaluating exp twice can give two different values: calls may return anything, and array, hash and
+// function literals build a new value every time, which == tells apart from the last one
+func makesValues(exp ast.Expression) bool {
+	found := false
+	ast.Inspect(exp, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.CallExpression, *ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// constant-condition: made of literals only, like yes, !no or 1 < 2
+func constant(exp ast.Expression) bool {
+	switch exp := ast.Unparen(exp).(type) {
+	case *ast.Boolean, *ast.IntegerLiteral, *ast.StringLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return constant(exp.Right)
+	case *ast.InfixExpression:
+		return constant(exp.Left) && constant(exp.Right)
+	}
+	return false
+}
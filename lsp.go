@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/evaluator"
+	"monkey/lsp"
+)
+
+func init() {
+	commands["lsp"] = runLSP
+}
+
+// monkey lsp [--stdio], a language server for editors, reading requests from stdin and answering on stdout
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey lsp [--stdio]")
+		flags.PrintDefaults()
+	}
+	// editors pass --stdio out of habit, stdio is the only transport there is
+	flags.Bool("stdio", true, "speak the protocol over stdin and stdout")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 {
+		if err == nil {
+			flags.Usage()
+		}
+		return 2
+	}
+
+	if err := lsp.New(evaluator.New(io.Discard).BuiltinNames()...).Serve(stdin, stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
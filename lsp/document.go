@@ -0,0 +1,172 @@
+package lsp
+
+import (
+	"errors"
+	"monkey/ast"
+	"monkey/checker"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+	"sort"
+	"unicode/utf8"
+)
+
+// document an open file as of its last change, with everything the requests about it need worked out once
+type document struct {
+	uri     string
+	text    string
+	lines   []int // offsets the lines start at
+	program *ast.Program
+	errs    []error
+	defs    map[*ast.Identifier]*ast.Identifier
+	types   map[*ast.Identifier]*checker.Type
+}
+
+func newDocument(uri, text string, builtins []string) *document {
+	doc := &document{uri: uri, text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lines = append(doc.lines, i+1)
+		}
+	}
+
+	doc.program = parser.New(lexer.NewLexer(text)).ParseProgram()
+	for _, err := range doc.program.Errors {
+		if err != nil {
+			doc.errs = append(doc.errs, err)
+		}
+	}
+	r := resolver.New(builtins...)
+	r.Resolve(doc.program)
+	doc.defs = r.Definitions()
+	doc.types = checker.Types(doc.program)
+	return doc
+}
+
+// the LSP position of a byte offset
+func (doc *document) position(offset int) position {
+	line := sort.Search(len(doc.lines), func(i int) bool { return doc.lines[i] > offset }) - 1
+	character := 0
+	for _, r := range doc.text[doc.lines[line]:offset] {
+		character += utf16Len(r)
+	}
+	return position{Line: line, Character: character}
+}
+
+// the byte offset of an LSP position, positions past the end of a line or the text are moved back onto it
+func (doc *document) offset(pos position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(doc.lines) {
+		return len(doc.text)
+	}
+	offset := doc.lines[pos.Line]
+	for character := 0; offset < len(doc.text) && doc.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(doc.text[offset:])
+		if character+utf16Len(r) > pos.Character {
+			break
+		}
+		character += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (doc *document) textRange(start, end token.Position) textRange {
+	return textRange{Start: doc.position(start.Offset), End: doc.position(end.Offset)}
+}
+
+func (doc *document) location(node ast.Node) location {
+	return location{URI: doc.uri, Range: doc.textRange(node.Pos(), node.End())}
+}
+
+// the syntax errors, at the token the parser stumbled over
+func (doc *document) diagnostics() []diagnostic {
+	diags := []diagnostic{}
+	for _, err := range doc.errs {
+		d := diagnostic{Severity: severityError, Source: "monkey", Message: err.Error()}
+		var perr *parser.Error
+		if errors.As(err, &perr) {
+			d.Range, d.Message = doc.textRange(perr.Pos, perr.End), perr.Message
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// the identifier the cursor at pos is on or right after, nil when there is none
+func (doc *document) identifierAt(pos position) *ast.Identifier {
+	offset := doc.offset(pos)
+	var found *ast.Identifier
+	ast.Inspect(doc.program, func(node ast.Node) bool {
+		if node == nil || found != nil || offset < node.Pos().Offset || offset > node.End().Offset {
+			return false
+		}
+		if id, ok := node.(*ast.Identifier); ok && offset <= id.End().Offset {
+			found = id
+		}
+		return true
+	})
+	return found
+}
+
+// every identifier with the same definition as def, in source order
+func (doc *document) references(def *ast.Identifier) []*ast.Identifier {
+	var refs []*ast.Identifier
+	ast.Inspect(doc.program, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Identifier); ok && doc.defs[id] == def {
+			refs = append(refs, id)
+		}
+		return true
+	})
+	return refs
+}
+
+// the lets under node as a tree, a binding's children are the ones declared inside its value
+func (doc *document) symbols(node ast.Node) []documentSymbol {
+	symbols := []documentSymbol{}
+	ast.Inspect(node, func(n ast.Node) bool {
+		let, ok := n.(*ast.LetStatement)
+		if !ok {
+			return true
+		}
+		symbol := documentSymbol{
+			Name:           let.Name.Value,
+			Kind:           symbolVariable,
+			Range:          doc.textRange(let.Pos(), let.End()),
+			SelectionRange: doc.textRange(let.Name.Pos(), let.Name.End()),
+			Children:       doc.symbols(let.Value),
+		}
+		if typ, ok := doc.types[let.Name]; ok {
+			symbol.Detail = typ.String()
+		}
+		switch {
+		case isFunction(let.Value):
+			symbol.Kind = symbolFunction
+		case let.IsConstant():
+			symbol.Kind = symbolConstant
+		}
+		symbols = append(symbols, symbol)
+		return false
+	})
+	return symbols
+}
+
+func isFunction(exp ast.Expression) bool {
+	_, ok := ast.Unparen(exp).(*ast.FunctionLiteral)
+	return ok
+}
+
+// the edit turning the whole text into formatted
+func (doc *document) replaceAll(formatted string) textEdit {
+	return textEdit{Range: textRange{End: doc.position(len(doc.text))}, NewText: formatted}
+}
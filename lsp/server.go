@@ -0,0 +1,281 @@
+// Package lsp is a language server for monkey scripts, speaking the Language Server Protocol over a pair of streams
+//
+// it reports syntax errors as documents change, lists their let bindings, finds where an identifier is declared
+// and where it is used, shows the types the checker infers on hover and formats documents
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/format"
+	"strings"
+)
+
+// Server keeps the documents a client has open, one client per Serve
+type Server struct {
+	builtins    []string
+	docs        map[string]*document
+	out         io.Writer
+	initialized bool
+	shutdown    bool
+}
+
+// New server, the names exist without being declared in the documents, like builtins do
+func New(builtins ...string) *Server {
+	return &Server{builtins: builtins, docs: make(map[string]*document)}
+}
+
+// Serve answers the requests read from in on out, one at a time, until the client sends exit
+// an exit without shutdown first, like in ending before that, is an error
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.out = out
+	r := bufio.NewReader(in)
+	for {
+		body, err := readMessage(r)
+		switch {
+		case err == io.EOF && s.shutdown:
+			return nil
+		case err == io.EOF:
+			return errors.New("lsp: input ended without shutdown")
+		case err != nil:
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := writeMessage(out, response(nil, nil, errorf(codeParseError, "%v", err))); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit without shutdown")
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(&msg)
+		if msg.ID == nil {
+			// notifications get no answer, not even when they fail
+			continue
+		}
+		if err := writeMessage(out, response(msg.ID, result, rerr)); err != nil {
+			return err
+		}
+	}
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"initialized":                 func(*Server, json.RawMessage) (interface{}, error) { return nil, nil },
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/didOpen":        (*Server).didOpen,
+	"textDocument/didChange":      (*Server).didChange,
+	"textDocument/didClose":       (*Server).didClose,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/formatting":     (*Server).formatting,
+}
+
+func (s *Server) handle(msg *message) (interface{}, *responseError) {
+	h, ok := handlers[msg.Method]
+	switch {
+	case !ok:
+		return nil, errorf(codeMethodNotFound, "method not found: %s", msg.Method)
+	case !s.initialized && msg.Method != "initialize":
+		return nil, errorf(codeServerNotInitialized, "initialize has to come first")
+	case s.shutdown:
+		return nil, errorf(codeInvalidRequest, "the server is shutting down")
+	}
+
+	result, err := h(s, msg.Params)
+	var rerr *responseError
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.As(err, &rerr):
+		return nil, rerr
+	}
+	return nil, errorf(codeInternalError, "%v", err)
+}
+
+// decodes params into v, failing with invalid params
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return errorf(codeInvalidParams, "%v", err)
+	}
+	return nil
+}
+
+// the open document uri names
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, errorf(codeInvalidParams, "document not open: %s", uri)
+	}
+	return doc, nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	s.initialized = true
+	result := initializeResult{Capabilities: serverCapabilities{
+		TextDocumentSync:           syncFull,
+		DocumentSymbolProvider:     true,
+		DefinitionProvider:         true,
+		ReferencesProvider:         true,
+		HoverProvider:              true,
+		DocumentFormattingProvider: true,
+	}}
+	result.ServerInfo.Name = "monkey"
+	return result, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+// analyzes the new text of a document and sends its diagnostics
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text, s.builtins)
+	s.docs[uri] = doc
+	return s.publish(uri, doc.diagnostics())
+}
+
+func (s *Server) publish(uri string, diags []diagnostic) error {
+	return writeMessage(s.out, notification("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags}))
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p didOpenParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p didChangeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+// a closed document is gone, and so are its diagnostics
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p didCloseParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.publish(p.TextDocument.URI, []diagnostic{})
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p documentSymbolParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return doc.symbols(doc.program), nil
+}
+
+// the declaration of the identifier at the position, null for builtins and names declared nowhere
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p textDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	id := doc.identifierAt(p.Position)
+	if id == nil || doc.defs[id] == nil {
+		return nil, nil
+	}
+	return doc.location(doc.defs[id]), nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p referenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	locations := []location{}
+	id := doc.identifierAt(p.Position)
+	if id == nil || doc.defs[id] == nil {
+		return locations, nil
+	}
+	def := doc.defs[id]
+	for _, ref := range doc.references(def) {
+		if ref != def || p.Context.IncludeDeclaration {
+			locations = append(locations, doc.location(ref))
+		}
+	}
+	return locations, nil
+}
+
+// the type the checker infers for the identifier at the position, like x: int
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p textDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	id := doc.identifierAt(p.Position)
+	if id == nil {
+		return nil, nil
+	}
+	typ, ok := doc.types[id]
+	if !ok {
+		return nil, nil
+	}
+	return hover{
+		Contents: markupContent{Kind: "plaintext", Value: fmt.Sprintf("%s: %v", id.Value, typ)},
+		Range:    doc.textRange(id.Pos(), id.End()),
+	}, nil
+}
+
+// the whole document formatted as a single edit, no edits when it is formatted already or does not parse
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p formattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	indent := "\t"
+	if p.Options.InsertSpaces {
+		indent = strings.Repeat(" ", p.Options.TabSize)
+	}
+	formatted, err := format.Source(doc.text, indent)
+	if err != nil || formatted == doc.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{doc.replaceAll(formatted)}, nil
+}
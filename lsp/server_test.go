@@ -0,0 +1,352 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// client talks to a Server running in the same process, the way an editor would over stdio
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan message // everything the server writes, in order
+	done     chan error   // what Serve returned
+	nextID   int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	c := &client{t: t, in: clientOut, messages: make(chan message, 100), done: make(chan error, 1)}
+
+	go func() {
+		err := New("len", "print").Serve(serverIn, serverOut)
+		serverOut.Close()
+		c.done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("server wrote %q: %v", body, err)
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+func (c *client) send(v interface{}) {
+	c.t.Helper()
+	if err := writeMessage(c.in, v); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) next() message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the server stopped writing")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no message from the server")
+	}
+	return message{}
+}
+
+// call sends a request and decodes the result of its response into result, the response's error when there is one
+func (c *client) call(method string, params interface{}, result interface{}) *responseError {
+	c.t.Helper()
+	c.nextID++
+	c.send(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		ID      int         `json:"id"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}{"2.0", c.nextID, method, params})
+
+	msg := c.next()
+	var id int
+	if msg.ID == nil || json.Unmarshal(*msg.ID, &id) != nil || id != c.nextID {
+		c.t.Fatalf("%s: want the response to request %d, have %+v", method, c.nextID, msg)
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatalf("%s: result %s: %v", method, msg.Result, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(notification(method, params))
+}
+
+// the diagnostics the server publishes next
+func (c *client) diagnostics() publishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("want diagnostics, have %+v", msg)
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		c.t.Fatal(err)
+	}
+	return p
+}
+
+func (c *client) initialize() {
+	c.t.Helper()
+	var result initializeResult
+	if err := c.call("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result); err != nil {
+		c.t.Fatal(err)
+	}
+	if !result.Capabilities.HoverProvider || result.Capabilities.TextDocumentSync != syncFull {
+		c.t.Fatalf("wrong capabilities %+v", result.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+}
+
+func (c *client) open(uri, text string) publishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text}})
+	return c.diagnostics()
+}
+
+// shutdown and exit, Serve has to return without an error afterwards
+func (c *client) stop() {
+	c.t.Helper()
+	if err := c.call("shutdown", nil, nil); err != nil {
+		c.t.Fatal(err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("Serve: %v", err)
+	}
+}
+
+func at(line, character int) textDocumentPositionParams {
+	return textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: position{Line: line, Character: character}}
+}
+
+func span(line, start, end int) textRange {
+	return textRange{Start: position{Line: line, Character: start}, End: position{Line: line, Character: end}}
+}
+
+const uri = "file:///tmp/script.mk"
+
+const script = `let total = 0;
+const limit = 10;
+let add = fn(a, b) {
+	let sum = a + b;
+	sum
+};
+total = add(total, limit);
+print(total)`
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+
+	if d := c.open(uri, script); len(d.Diagnostics) != 0 || d.URI != uri {
+		t.Errorf("want no diagnostics, have %+v", d)
+	}
+
+	// every change is checked again, positions count UTF-16 units
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": "let s = \"😀\"; let = 5;\nlet y = @"}},
+	})
+	have := c.diagnostics().Diagnostics
+	want := []diagnostic{
+		{Range: span(0, 18, 19), Severity: severityError, Source: "monkey", Message: `AFTER "let" WANT IDENT, HAVE ASSIGN`},
+		{Range: span(1, 8, 9), Severity: severityError, Source: "monkey", Message: `illegal "@", unexpected character`},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("want %+v\nhave %+v", want, have)
+	}
+
+	c.notify("textDocument/didClose", didCloseParams{TextDocument: textDocumentIdentifier{URI: uri}})
+	if d := c.diagnostics(); len(d.Diagnostics) != 0 {
+		t.Errorf("closing has to clear the diagnostics, have %+v", d)
+	}
+	if err := c.call("textDocument/hover", at(0, 0), nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("want invalid params for a closed document, have %v", err)
+	}
+	c.stop()
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	c.open(uri, script)
+
+	var have []documentSymbol
+	if err := c.call("textDocument/documentSymbol", documentSymbolParams{TextDocument: textDocumentIdentifier{URI: uri}}, &have); err != nil {
+		t.Fatal(err)
+	}
+	want := []documentSymbol{
+		{Name: "total", Detail: "any", Kind: symbolVariable, Range: span(0, 0, 13), SelectionRange: span(0, 4, 9)},
+		{Name: "limit", Detail: "int", Kind: symbolConstant, Range: span(1, 0, 16), SelectionRange: span(1, 6, 11)},
+		{Name: "add", Detail: "fn(any, any) -> any", Kind: symbolFunction,
+			Range: textRange{Start: position{Line: 2, Character: 0}, End: position{Line: 5, Character: 1}}, SelectionRange: span(2, 4, 7),
+			Children: []documentSymbol{
+				{Name: "sum", Detail: "any", Kind: symbolVariable, Range: span(3, 1, 16), SelectionRange: span(3, 5, 8)},
+			}},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("want %+v\nhave %+v", want, have)
+	}
+	c.stop()
+}
+
+func TestNavigation(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	c.open(uri, script)
+
+	// from a use, from right after it and from the declaration itself
+	for _, pos := range []textDocumentPositionParams{at(4, 2), at(4, 4), at(3, 5)} {
+		var have location
+		if err := c.call("textDocument/definition", pos, &have); err != nil {
+			t.Fatal(err)
+		}
+		if want := (location{URI: uri, Range: span(3, 5, 8)}); have != want {
+			t.Errorf("definition at %v: want %+v, have %+v", pos.Position, want, have)
+		}
+	}
+	// builtins and blanks have none
+	for _, pos := range []textDocumentPositionParams{at(7, 2), at(2, 12)} {
+		var have *location
+		if err := c.call("textDocument/definition", pos, &have); err != nil || have != nil {
+			t.Errorf("definition at %v: want null, have %+v %v", pos.Position, have, err)
+		}
+	}
+
+	refs := func(pos textDocumentPositionParams, declaration bool) []textRange {
+		params := referenceParams{textDocumentPositionParams: pos}
+		params.Context.IncludeDeclaration = declaration
+		var locations []location
+		if err := c.call("textDocument/references", params, &locations); err != nil {
+			t.Fatal(err)
+		}
+		ranges := []textRange{}
+		for _, l := range locations {
+			ranges = append(ranges, l.Range)
+		}
+		return ranges
+	}
+	if have, want := refs(at(6, 14), true), []textRange{span(0, 4, 9), span(6, 0, 5), span(6, 12, 17), span(7, 6, 11)}; !reflect.DeepEqual(have, want) {
+		t.Errorf("references of total: want %v, have %v", want, have)
+	}
+	if have, want := refs(at(3, 11), false), []textRange{span(3, 11, 12)}; !reflect.DeepEqual(have, want) {
+		t.Errorf("references of a: want %v, have %v", want, have)
+	}
+	c.stop()
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	c.open(uri, script)
+
+	tests := []struct {
+		pos      textDocumentPositionParams
+		expected string
+	}{
+		{at(1, 7), "limit: int"},
+		{at(6, 9), "add: fn(any, any) -> any"},
+		{at(7, 1), "print: fn() -> null"},
+		{at(3, 12), "a: any"},
+	}
+	for _, tt := range tests {
+		var have hover
+		if err := c.call("textDocument/hover", tt.pos, &have); err != nil {
+			t.Fatal(err)
+		}
+		if have.Contents.Value != tt.expected {
+			t.Errorf("hover at %v: want %q, have %q", tt.pos.Position, tt.expected, have.Contents.Value)
+		}
+	}
+
+	var have *hover
+	if err := c.call("textDocument/hover", at(0, 13), &have); err != nil || have != nil {
+		t.Errorf("hover on a semicolon: want null, have %+v %v", have, err)
+	}
+	c.stop()
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	c.initialize()
+	c.open(uri, "let  x=[1,2];\nlet f = fn(a) {\nsend a*2 // twice\n}\n")
+
+	params := formattingParams{TextDocument: textDocumentIdentifier{URI: uri}}
+	params.Options.TabSize, params.Options.InsertSpaces = 2, true
+	var edits []textEdit
+	if err := c.call("textDocument/formatting", params, &edits); err != nil {
+		t.Fatal(err)
+	}
+	want := []textEdit{{
+		Range:   textRange{End: position{Line: 4, Character: 0}},
+		NewText: "let x = [1, 2];\nlet f = fn(a) {\n  send a * 2 // twice\n}\n",
+	}}
+	if !reflect.DeepEqual(edits, want) {
+		t.Errorf("want %+v\nhave %+v", want, edits)
+	}
+
+	// nothing to do for a document that is formatted already, or one that does not parse
+	for _, text := range []string{want[0].NewText, "let = 1"} {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]string{{"text": text}},
+		})
+		c.diagnostics()
+		if err := c.call("textDocument/formatting", params, &edits); err != nil || len(edits) != 0 {
+			t.Errorf("text %q: want no edits, have %+v %v", text, edits, err)
+		}
+	}
+	c.stop()
+}
+
+func TestProtocol(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("textDocument/hover", at(0, 0), nil); err == nil || err.Code != codeServerNotInitialized {
+		t.Errorf("before initialize: want server not initialized, have %v", err)
+	}
+	c.initialize()
+	if err := c.call("workspace/symbol", map[string]string{"query": "x"}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("want method not found, have %v", err)
+	}
+	// unknown notifications are ignored
+	c.notify("$/cancelRequest", map[string]int{"id": 1})
+	if err := c.call("textDocument/definition", "not params", nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("want invalid params, have %v", err)
+	}
+	c.stop()
+
+	c = newClient(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err == nil {
+		t.Error("exit without shutdown has to fail")
+	}
+}
@@ -1,19 +1,195 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/optimizer"
+	"monkey/parser"
 	"monkey/repl"
+	"monkey/resolver"
+	"monkey/vm"
 	"os"
 	"os/user"
+	"sort"
 )
 
+const usage = `usage: monkey [flags] [file]
+       monkey <command> [arguments]
+
+Runs file, or starts the REPL when no file is given.
+Scripts may print, everything else they reach for has to be allowed:
+
+`
+
+// a command gets the arguments after its name and returns the exit code
+type command func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+
+// commands besides running a file or the REPL, filled in by the files implementing them
+var commands = map[string]command{}
+
 func main() {
-	user, err := user.Current()
-	if err != nil {
-		panic(err)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, name := range sortedCommands() {
+			fmt.Fprintf(stderr, "  %s\n", name)
+		}
+	}
+	caps := capabilityFlags(flags)
+	engine := flags.String("engine", "eval", "what runs files: eval walks the syntax tree, vm compiles it to bytecode first")
+	optimize := flags.Bool("optimize", false, "fold constants and drop dead branches before running a file")
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Fprintf(stderr, "unknown engine %q, want eval or vm\n", *engine)
+		return 2
+	}
+
+	eval := evaluator.New(stdout)
+	eval.Capabilities = caps()
+
+	switch flags.NArg() {
+	case 0:
+		if *engine == "vm" {
+			fmt.Fprintln(stderr, "the REPL always runs on eval, --engine=vm needs a file")
+			return 2
+		}
+		user, err := user.Current()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(stdout, "Welcome %v,\nThis is monke v1.0 REPL, write 'bye' to exit\n", user.Username)
+		repl.StartWith(stdin, stdout, eval)
+		return 0
+	case 1:
+		return runFile(flags.Arg(0), eval, *engine == "vm", *optimize, stderr)
+	default:
+		flags.Usage()
+		return 2
+	}
+}
+
+// registers the --allow-* flags, the returned func gives what they grant once parsed
+func capabilityFlags(flags *flag.FlagSet) func() object.Capability {
+	allowed := map[object.Capability]*bool{
+		object.CapIO:     flags.Bool("allow-io", false, "let scripts read and write files"),
+		object.CapTime:   flags.Bool("allow-time", false, "let scripts read the clock and sleep"),
+		object.CapRandom: flags.Bool("allow-random", false, "let scripts use random numbers"),
+	}
+	all := flags.Bool("allow-all", false, "all of the --allow flags together")
+	noPrint := flags.Bool("no-print", false, "keep scripts from printing")
+
+	return func() object.Capability {
+		caps := object.CapPrint
+		if *all {
+			caps = object.AllCapabilities
+		}
+		for cap, ok := range allowed {
+			if *ok {
+				caps |= cap
+			}
+		}
+		if *noPrint {
+			caps &^= object.CapPrint
+		}
+		return caps
+	}
+}
+
+// runs the script in path on eval, or on the vm with eval's builtins and limits, optimized first when asked to
+// problems go to stderr and make the exit code 1
+func runFile(path string, eval *evaluator.Evaluator, useVM, optimize bool, stderr io.Writer) int {
+	program, ok := parseFile(path, stderr)
+	if !ok {
+		return 1
+	}
+	// warnings are printed too, but only errors keep the script from running
+	diags := resolver.New(eval.BuiltinNames()...).Resolve(program)
+	for _, d := range diags {
+		fmt.Fprintf(stderr, "%s: %v\n", path, d)
+	}
+	if resolver.HasErrors(diags) {
+		return 1
+	}
+	// after resolving, so diagnostics still cover branches the optimizer drops
+	if optimize {
+		optimizer.Optimize(program)
 	}
 
-	fmt.Printf("Welcome %v,\nThis is monke v1.0 REPL, write 'bye' to exit\n", user.Username)
-	repl.Start(os.Stdin, os.Stdout)
+	var result object.Object
+	if useVM {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			return 1
+		}
+		result = vm.New(comp.Bytecode(), eval).Run(context.Background())
+	} else {
+		result = eval.Eval(program, object.NewEnvironment())
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(stderr, "%s: %s\n", path, errObj.Traceback())
+		return 1
+	}
+	return 0
+}
 
+func sortedCommands() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parses the file at path, the parser errors are printed to stderr
+func parseFile(path string, stderr io.Writer) (*ast.Program, bool) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+	program := parser.New(lexer.NewLexer(string(src))).ParseProgram()
+	if hasErrors(program.Errors) {
+		for _, err := range program.Errors {
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			}
+		}
+		return nil, false
+	}
+	return program, true
+}
+
+func hasErrors(errs []error) bool {
+	for _, err := range errs {
+		if err != nil {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,230 @@
+package monkey
+
+import (
+	"fmt"
+	"monkey/evaluator"
+	"monkey/object"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Bind exposes a Go function to scripts as a builtin called name, no wrapper needed
+//
+//	in.Bind("repeat", func(s string, n int) (string, error) { ... })
+//
+// Parameters and results may be integers, bools, strings, slices, arrays and maps of those, or interface{}.
+// A last result of type error turns into a runtime error when it is not nil.
+// Calls with the wrong number or kind of arguments fail with a runtime error pointing at the call.
+func (in *Interpreter) Bind(name string, fn interface{}) error {
+	builtin, err := bindFunc(name, reflect.ValueOf(fn))
+	if err != nil {
+		return fmt.Errorf("monkey: cannot bind %s: %w", name, err)
+	}
+	in.eval.RegisterBuiltin(builtin)
+	in.res.Declare(name)
+	return nil
+}
+
+func bindFunc(name string, fn reflect.Value) (*object.Builtin, error) {
+	if fn.Kind() != reflect.Func || fn.IsNil() {
+		return nil, fmt.Errorf("not a function: %v", fn.Kind())
+	}
+	ft := fn.Type()
+
+	builtin := &object.Builtin{Name: name, Variadic: ft.IsVariadic()}
+	for i := 0; i < ft.NumIn(); i++ {
+		in := ft.In(i)
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			in = in.Elem() // the script passes the elements one by one
+		}
+		types, err := objectTypesFor(in)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %d: %w", i+1, err)
+		}
+		builtin.Params = append(builtin.Params, types)
+	}
+
+	results := ft.NumOut()
+	returnsError := results > 0 && ft.Out(results-1) == errorType
+	if returnsError {
+		results--
+	}
+	if results > 1 {
+		return nil, fmt.Errorf("too many results, want at most one value and an error")
+	}
+	if results == 1 {
+		if _, err := objectTypesFor(ft.Out(0)); err != nil {
+			return nil, fmt.Errorf("result: %w", err)
+		}
+	}
+
+	builtin.Fn = func(args ...object.Object) object.Object {
+		return callFunc(name, fn, returnsError, args)
+	}
+	return builtin, nil
+}
+
+// which object types can be converted to t, an empty list for interface{} which takes them all
+func objectTypesFor(t reflect.Type) ([]object.ObjectType, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []object.ObjectType{object.INTEGER_OBJ}, nil
+	case reflect.Bool:
+		return []object.ObjectType{object.BOOLEAN_OBJ}, nil
+	case reflect.String:
+		return []object.ObjectType{object.STRING_OBJ}, nil
+	case reflect.Slice, reflect.Array:
+		if _, err := objectTypesFor(t.Elem()); err != nil {
+			return nil, err
+		}
+		return []object.ObjectType{object.ARRAY_OBJ}, nil
+	case reflect.Map:
+		if _, err := objectTypesFor(t.Key()); err != nil {
+			return nil, err
+		}
+		if _, err := objectTypesFor(t.Elem()); err != nil {
+			return nil, err
+		}
+		return []object.ObjectType{object.HASH_OBJ}, nil
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return []object.ObjectType{}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// converts the already type checked arguments, calls fn and converts what it returns
+// a panic inside fn is turned into a runtime error instead of taking the host program down
+func callFunc(name string, fn reflect.Value, returnsError bool, args []object.Object) (result object.Object) {
+	ft := fn.Type()
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		t := ft.In(min(i, ft.NumIn()-1))
+		if ft.IsVariadic() && i >= ft.NumIn()-1 {
+			t = t.Elem()
+		}
+		v, err := objectToValue(arg, t)
+		if err != nil {
+			return &object.Error{Message: fmt.Sprintf("argument %d to %s: %v", i+1, name, err)}
+		}
+		in[i] = v
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = &object.Error{Message: fmt.Sprintf("%s panicked: %v", name, r)}
+		}
+	}()
+	out := fn.Call(in)
+
+	if returnsError {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return &object.Error{Message: fmt.Sprintf("%s: %v", name, err.Interface())}
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return evaluator.NULL
+	}
+	obj, err := reflectToObject(out[0])
+	if err != nil {
+		return &object.Error{Message: fmt.Sprintf("result of %s: %v", name, err)}
+	}
+	return obj
+}
+
+// objectToValue converts obj into a Go value of type t
+func objectToValue(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() == reflect.Interface {
+		value, err := fromObject(obj)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if value != nil {
+			return reflect.ValueOf(value), nil
+		}
+		return reflect.Zero(t), nil
+	}
+
+	mismatch := fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		v := reflect.New(t).Elem()
+		if v.OverflowInt(int64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetInt(int64(i.Value))
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		v := reflect.New(t).Elem()
+		if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", i.Value, t)
+		}
+		v.SetUint(uint64(i.Value))
+		return v, nil
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		return reflect.ValueOf(b.Value).Convert(t), nil
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		return reflect.ValueOf(s.Value).Convert(t), nil
+	case reflect.Slice, reflect.Array:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		var v reflect.Value
+		if t.Kind() == reflect.Slice {
+			v = reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		} else if len(arr.Elements) != t.Len() {
+			return reflect.Value{}, fmt.Errorf("want %d elements, got %d", t.Len(), len(arr.Elements))
+		} else {
+			v = reflect.New(t).Elem()
+		}
+		for i, el := range arr.Elements {
+			ev, err := objectToValue(el, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return reflect.Value{}, mismatch
+		}
+		v := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, hk := range hash.Order {
+			pair := hash.Pairs[hk]
+			kv, err := objectToValue(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			vv, err := objectToValue(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("value of %s: %w", pair.Key.Inspect(), err)
+			}
+			v.SetMapIndex(kv, vv)
+		}
+		return v, nil
+	}
+	return reflect.Value{}, mismatch
+}
@@ -0,0 +1,116 @@
+package monkey
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBind(t *testing.T) {
+	in := New()
+	bindings := map[string]interface{}{
+		"repeat": func(s string, n int) (string, error) {
+			if n < 0 {
+				return "", errors.New("negative count")
+			}
+			return strings.Repeat(s, n), nil
+		},
+		"sum": func(nums ...int) int {
+			total := 0
+			for _, n := range nums {
+				total += n
+			}
+			return total
+		},
+		"keys":    func(m map[string]int) []string { return []string{fmt.Sprint(len(m))} },
+		"negate":  func(b bool) bool { return !b },
+		"small":   func(n int8) int8 { return n },
+		"pair":    func(p [2]string) string { return p[0] + p[1] },
+		"any":     func(v interface{}) string { return fmt.Sprintf("%T", v) },
+		"nothing": func() {},
+		"boom":    func() int { panic("kaboom") },
+	}
+	for name, fn := range bindings {
+		if err := in.Bind(name, fn); err != nil {
+			t.Fatalf("Bind(%q): %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		src      string
+		expected interface{}
+	}{
+		{`repeat("ab", 3)`, "ababab"},
+		{`sum()`, 0},
+		{`sum(1, 2, 3)`, 6},
+		{`keys({"a": 1, "b": 2})`, []interface{}{"2"}},
+		{`negate(no)`, true},
+		{`small(-128)`, -128},
+		{`pair(["a", "b"])`, "ab"},
+		{`any([1])`, "[]interface {}"},
+		{`any(first([]))`, "<nil>"},
+		{`nothing()`, nil},
+		{`let repeat = fn(s, n) { "shadowed" }; repeat("a", 1)`, "shadowed"},
+	}
+	for _, tt := range tests {
+		got, err := in.Eval(tt.src)
+		if err != nil {
+			t.Errorf("src %q: unexpected error %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("src %q: want %#v, got %#v", tt.src, tt.expected, got)
+		}
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	in := New()
+	in.Bind("repeat", func(s string, n int) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	})
+	in.Bind("total", func(nums []int) int { return len(nums) })
+	in.Bind("small", func(n uint8) uint8 { return n })
+	in.Bind("boom", func() int { panic("kaboom") })
+
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{`repeat("a", -1)`, "runtime error at 1:7: repeat: negative count"},
+		{`repeat("a")`, "runtime error at 1:7: wrong number of arguments to repeat: want 2, got 1"},
+		{"\n  repeat(1, 2)", "runtime error at 2:9: argument 1 to repeat must be STRING, got INTEGER"},
+		{`total([1, "a"])`, "runtime error at 1:6: argument 1 to total: element 1: cannot use STRING as int"},
+		{`small(256)`, "runtime error at 1:6: argument 1 to small: 256 overflows uint8"},
+		{`small(-1)`, "runtime error at 1:6: argument 1 to small: -1 overflows uint8"},
+		{`boom()`, "runtime error at 1:5: boom panicked: kaboom"},
+	}
+	for _, tt := range tests {
+		_, err := in.Eval(tt.src)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("src %q: want error %q, got %v", tt.src, tt.expected, err)
+		}
+	}
+}
+
+func TestBindRejectsUnsupported(t *testing.T) {
+	in := New()
+	tests := []interface{}{
+		42,
+		func(c chan int) {},
+		func() (int, int) { return 0, 0 },
+		func() func() { return nil },
+		func(m map[string]chan int) {},
+		(func())(nil),
+	}
+	for _, fn := range tests {
+		if err := in.Bind("f", fn); err == nil {
+			t.Errorf("Bind(%T) should fail", fn)
+		}
+	}
+}
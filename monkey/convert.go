@@ -0,0 +1,151 @@
+package monkey
+
+import (
+	"fmt"
+	"monkey/evaluator"
+	"monkey/object"
+	"reflect"
+	"sort"
+)
+
+// toObject converts a Go value into the object a script sees
+// integers of any size, bools, strings, slices, arrays and maps with comparable keys are supported
+func toObject(value interface{}) (object.Object, error) {
+	if value == nil {
+		return evaluator.NULL, nil
+	}
+	if obj, ok := value.(object.Object); ok {
+		return obj, nil
+	}
+	return reflectToObject(reflect.ValueOf(value))
+}
+
+func reflectToObject(v reflect.Value) (object.Object, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.YES, nil
+		}
+		return evaluator.NO, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: int(v.Int())}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &object.Integer{Value: int(v.Uint())}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := reflectToObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return mapToHash(v)
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if obj, ok := v.Interface().(object.Object); ok {
+			return obj, nil
+		}
+		return reflectToObject(v.Elem())
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// go maps have no order, keys are sorted so the hash iterates the same way every time
+func mapToHash(v reflect.Value) (object.Object, error) {
+	type pair struct {
+		key   object.Object
+		value object.Object
+	}
+	pairs := []pair{}
+	for iter := v.MapRange(); iter.Next(); {
+		key, err := reflectToObject(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := key.(object.Hashable); !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+		value, err := reflectToObject(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair{key, value})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].key.Inspect() < pairs[j].key.Inspect()
+	})
+
+	hash := object.NewHash()
+	for _, p := range pairs {
+		hash.Set(p.key.(object.Hashable), p.value)
+	}
+	return hash, nil
+}
+
+// fromObject converts an object back into a plain Go value
+// functions and anything else without a Go counterpart come back as the object itself,
+// an array or hash holding itself is an error since the Go value would have to be infinite
+func fromObject(obj object.Object) (interface{}, error) {
+	return convertObject(obj, map[object.Object]bool{})
+}
+
+// converting holds the arrays and hashes being converted further up
+func convertObject(obj object.Object, converting map[object.Object]bool) (interface{}, error) {
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		if converting[obj] {
+			return nil, fmt.Errorf("monkey: %s holds itself, it has no Go counterpart", obj.Type())
+		}
+		converting[obj] = true
+		defer delete(converting, obj)
+	}
+
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := convertObject(el, converting)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, err := convertObject(pair.Key, converting)
+			if err != nil {
+				return nil, err
+			}
+			value, err := convertObject(pair.Value, converting)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+	return obj, nil
+}
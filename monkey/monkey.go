@@ -0,0 +1,210 @@
+// Package monkey runs scripts from inside a Go program.
+//
+//	in := monkey.New()
+//	in.Set("limit", 10)
+//	in.Eval(`let double = fn(x) { x * 2 };`)
+//	v, err := in.Call("double", 21) // 42
+//
+// Values cross the boundary as plain Go values: int, bool, string, nil, []interface{} and map[interface{}]interface{}.
+// Functions stay objects and are called through Call.
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+)
+
+// Interpreter one isolated script environment, bindings made by one Eval are visible to the next
+// an Interpreter is not safe for concurrent use
+type Interpreter struct {
+	env  *object.Environment
+	eval *evaluator.Evaluator
+	res  *resolver.Resolver
+}
+
+// Option configures an Interpreter in New
+type Option func(*Interpreter)
+
+// WithOutput where print and puts write to, nothing is written anywhere without it
+func WithOutput(w io.Writer) Option {
+	return func(in *Interpreter) { in.eval.Out = w }
+}
+
+// Limits caps what one Eval or Call may do, see evaluator.Limits
+type Limits = evaluator.Limits
+
+// WithLimits replaces the default limits, which only cap the call depth
+func WithLimits(limits Limits) Option {
+	return func(in *Interpreter) { in.eval.Limits = limits }
+}
+
+// Capability something outside the script a builtin reaches for, see object.Capability
+type Capability = object.Capability
+
+const (
+	CapPrint        = object.CapPrint
+	CapIO           = object.CapIO
+	CapTime         = object.CapTime
+	CapRandom       = object.CapRandom
+	NoCapabilities  = object.NoCapabilities
+	AllCapabilities = object.AllCapabilities
+)
+
+// WithCapabilities which builtins scripts may call, by default they may print and nothing else
+func WithCapabilities(caps Capability) Option {
+	return func(in *Interpreter) { in.eval.Capabilities = caps }
+}
+
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{
+		env:  object.NewEnvironment(),
+		eval: evaluator.New(io.Discard),
+	}
+	for _, opt := range opts {
+		opt(in)
+	}
+	in.res = resolver.New(in.eval.BuiltinNames()...)
+	return in
+}
+
+// ErrLimitExceeded matches, through errors.Is, every RuntimeError caused by a limit or a done context
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// RuntimeError a script that parsed fine but failed while running, like a division by zero
+type RuntimeError struct {
+	Message string
+	Pos     token.Position // where in the script, the zero value when not known
+	Limit   bool           // the script was stopped by a limit or its context, not by a mistake of its own
+	Stack   []Frame        // the script functions it unwound through, innermost first
+	cause   error          // the context error when the context stopped it
+}
+
+// Frame one function call a RuntimeError unwound through, see object.Frame
+type Frame = object.Frame
+
+func (e *RuntimeError) Error() string {
+	return (&object.Error{Message: e.Message, Pos: e.Pos}).Inspect()
+}
+
+// Traceback Error followed by one line per function call the error unwound through
+func (e *RuntimeError) Traceback() string {
+	return (&object.Error{Message: e.Message, Pos: e.Pos, Stack: e.Stack}).Traceback()
+}
+
+func (e *RuntimeError) Unwrap() []error {
+	if !e.Limit {
+		return nil
+	}
+	if e.cause != nil {
+		return []error{ErrLimitExceeded, e.cause}
+	}
+	return []error{ErrLimitExceeded}
+}
+
+// Eval parses and runs src, returning the value it ends with
+// syntax errors come back joined into one error, failures while running as *RuntimeError
+func (in *Interpreter) Eval(src string) (interface{}, error) {
+	return in.EvalContext(context.Background(), src)
+}
+
+// EvalContext is Eval that gives up once ctx is done, the error then matches ErrLimitExceeded and ctx.Err()
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (interface{}, error) {
+	program, err := in.parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return in.result(ctx, in.eval.EvalContext(ctx, program, in.env))
+}
+
+// Set binds name to a Go value converted to its script counterpart, like let would
+// a name the script declared with const cannot be set, same as a script cannot redeclare it
+func (in *Interpreter) Set(name string, value interface{}) error {
+	if in.env.IsConstant(name) {
+		return fmt.Errorf("monkey: cannot set %s: it is a constant", name)
+	}
+	obj, err := toObject(value)
+	if err != nil {
+		return fmt.Errorf("monkey: cannot set %s: %w", name, err)
+	}
+	in.env.Set(name, obj)
+	in.res.Declare(name)
+	return nil
+}
+
+// Get value of a binding converted to Go, ok is false when name is not bound
+// an array or hash holding itself cannot be converted and comes back as the object itself
+func (in *Interpreter) Get(name string) (value interface{}, ok bool) {
+	obj, ok := in.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	if value, err := fromObject(obj); err == nil {
+		return value, true
+	}
+	return obj, true
+}
+
+// Call calls the function bound to name (or the builtin called name) with args converted from Go
+func (in *Interpreter) Call(fnName string, args ...interface{}) (interface{}, error) {
+	return in.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is Call that gives up once ctx is done, see EvalContext
+func (in *Interpreter) CallContext(ctx context.Context, fnName string, args ...interface{}) (interface{}, error) {
+	fn, ok := in.env.Get(fnName)
+	if !ok {
+		if fn, ok = in.eval.Builtin(fnName); !ok {
+			return nil, fmt.Errorf("monkey: %s is not defined", fnName)
+		}
+	}
+
+	objs := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := toObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("monkey: argument %d to %s: %w", i+1, fnName, err)
+		}
+		objs[i] = obj
+	}
+	return in.result(ctx, in.eval.ApplyContext(ctx, fn, objs))
+}
+
+// parses and resolves src, every problem found is in the error, resolver warnings are not problems
+func (in *Interpreter) parse(src string) (*ast.Program, error) {
+	program := parser.New(lexer.NewLexer(src)).ParseProgram()
+
+	var errs []error
+	for _, err := range program.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		for _, d := range in.res.Resolve(program) {
+			if d.Severity == resolver.Error {
+				errs = append(errs, d)
+			}
+		}
+	}
+	return program, errors.Join(errs...)
+}
+
+func (in *Interpreter) result(ctx context.Context, obj object.Object) (interface{}, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		err := &RuntimeError{Message: errObj.Message, Pos: errObj.Pos, Limit: errObj.Limit, Stack: errObj.Stack}
+		if err.Limit {
+			err.cause = ctx.Err()
+		}
+		return nil, err
+	}
+	return fromObject(obj)
+}
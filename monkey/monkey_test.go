@@ -0,0 +1,258 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	in := New()
+	tests := []struct {
+		src      string
+		expected interface{}
+	}{
+		{"1 + 2", 3},
+		{`"a" + "b"`, "ab"},
+		{"1 < 2", true},
+		{"let x = 5;", nil},
+		{"x * 2", 10}, // bindings survive between calls
+		{"[1, [2, 3], \"a\"]", []interface{}{1, []interface{}{2, 3}, "a"}},
+		{`{"a": 1, 2: no}`, map[interface{}]interface{}{"a": 1, 2: false}},
+		{"first([])", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := in.Eval(tt.src)
+		if err != nil {
+			t.Errorf("src %q: unexpected error %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("src %q: want %#v, got %#v", tt.src, tt.expected, got)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Eval("let x = ; let y = @;")
+	if err == nil || !strings.Contains(err.Error(), "parser error at 1:9") || !strings.Contains(err.Error(), "parser error at 1:19") {
+		t.Errorf("want both parser errors, got %v", err)
+	}
+
+	_, err = in.Eval("const c = 1; c = 2")
+	if err == nil || err.Error() != "resolver error at 1:14: cannot assign to constant: c" {
+		t.Errorf("want resolver error, got %v", err)
+	}
+
+	_, err = in.Eval("10 / 0")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "division by zero: 10 / 0" {
+		t.Errorf("want a RuntimeError, got %v", err)
+	}
+
+	_, err = in.Eval(`let a = [1]; let h = {"a": a}; a[0] = h; [2, a]`)
+	if err == nil || err.Error() != "monkey: ARRAY holds itself, it has no Go counterpart" {
+		t.Errorf("want an error for a cyclic array, got %v", err)
+	}
+	if got, ok := in.Get("h"); !ok || reflect.TypeOf(got) != reflect.TypeOf(&object.Hash{}) {
+		t.Errorf("Get of a cyclic hash: want the object itself, got %#v", got)
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	in := New()
+	values := map[string]interface{}{
+		"i":     42,
+		"small": int8(-3),
+		"u":     uint16(7),
+		"b":     true,
+		"s":     "hi",
+		"nums":  []int{1, 2, 3},
+		"words": [2]string{"a", "b"},
+		"conf":  map[string]int{"b": 2, "a": 1},
+		"none":  nil,
+	}
+	for name, value := range values {
+		if err := in.Set(name, value); err != nil {
+			t.Fatalf("Set(%q): %v", name, err)
+		}
+	}
+
+	got, err := in.Eval(`i + small + u + len(nums) + len(words) + conf["a"] + conf["b"]`)
+	if err != nil || got != 42-3+7+3+2+1+2 {
+		t.Errorf("want %d, got %v (%v)", 42-3+7+3+2+1+2, got, err)
+	}
+
+	got, err = in.Eval(`let keys = ""; for (k in conf) { keys += k }; keys`)
+	if err != nil || got != "ab" {
+		t.Errorf("map keys should iterate sorted, got %v (%v)", got, err)
+	}
+
+	expected := map[string]interface{}{
+		"i":    42,
+		"b":    true,
+		"s":    "hi",
+		"nums": []interface{}{1, 2, 3},
+		"conf": map[interface{}]interface{}{"a": 1, "b": 2},
+		"none": nil,
+	}
+	for name, want := range expected {
+		got, ok := in.Get(name)
+		if !ok {
+			t.Errorf("Get(%q) not found", name)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%q): want %#v, got %#v", name, want, got)
+		}
+	}
+
+	if _, ok := in.Get("missing"); ok {
+		t.Errorf("Get of an unbound name should not be ok")
+	}
+	if err := in.Set("ch", make(chan int)); err == nil {
+		t.Errorf("Set of a channel should fail")
+	}
+	if err := in.Set("bad", map[interface{}]int{[2]int{}: 1}); err == nil {
+		t.Errorf("Set of a map with array keys should fail")
+	}
+
+	if _, err := in.Eval("const limit = 3; let count = 1;"); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set("limit", 4); err == nil || err.Error() != "monkey: cannot set limit: it is a constant" {
+		t.Errorf("Set of a constant: want an error, got %v", err)
+	}
+	if got, _ := in.Get("limit"); got != 3 {
+		t.Errorf("a failed Set changed the constant to %#v", got)
+	}
+	if err := in.Set("count", 2); err != nil {
+		t.Errorf("Set of a let binding: %v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+	if _, err := in.Eval(`let add = fn(a, b) { a + b }; let greet = fn(name) { "hello " + name }`); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := in.Call("add", 40, 2); err != nil || got != 42 {
+		t.Errorf("add: want 42, got %v (%v)", got, err)
+	}
+	if got, err := in.Call("greet", "monke"); err != nil || got != "hello monke" {
+		t.Errorf("greet: want %q, got %v (%v)", "hello monke", got, err)
+	}
+	if got, err := in.Call("len", []string{"a", "b"}); err != nil || got != 2 {
+		t.Errorf("len: want 2, got %v (%v)", got, err)
+	}
+
+	if _, err := in.Call("add", 1); err == nil {
+		t.Errorf("want an arity error")
+	}
+	if _, err := in.Call("nope"); err == nil || err.Error() != "monkey: nope is not defined" {
+		t.Errorf("want an undefined error, got %v", err)
+	}
+	if _, err := in.Call("add", 1, struct{}{}); err == nil {
+		t.Errorf("want a conversion error")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var out bytes.Buffer
+	in := New(WithOutput(&out))
+	if _, err := in.Eval(`print("hello", 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello 1\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	// without WithOutput nothing is written anywhere
+	if _, err := New().Eval(`print("dropped")`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := New(WithLimits(Limits{MaxSteps: 10000}))
+	_, err := in.Eval("let loop = fn() { loop() }; loop()")
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("want ErrLimitExceeded, got %v", err)
+	}
+
+	// the budget is per run, not per interpreter
+	for i := 0; i < 3; i++ {
+		if _, err := in.Eval("let x = 1 + 2;"); err != nil {
+			t.Fatalf("run %d: unexpected error %v", i, err)
+		}
+	}
+
+	_, err = in.Eval("1 / 0")
+	if errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("division by zero is not a limit: %v", err)
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	in := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := in.EvalContext(ctx, "while (yes) {}")
+	if !errors.Is(err, ErrLimitExceeded) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want ErrLimitExceeded and context.DeadlineExceeded, got %v", err)
+	}
+
+	if _, err := in.Eval("let spin = fn() { while (yes) {} };"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := in.CallContext(ctx, "spin"); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	var out bytes.Buffer
+	in := New(WithOutput(&out), WithCapabilities(NoCapabilities))
+	_, err := in.Eval(`print("hi")`)
+	if err == nil || err.Error() != "runtime error at 1:6: print needs the print capability, which is not granted" {
+		t.Errorf("wrong error: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("printed without the capability: %q", out.String())
+	}
+
+	in = New(WithCapabilities(CapRandom))
+	if got, err := in.Eval("rand(1)"); err != nil || got != 0 {
+		t.Errorf("want 0, got %v (%v)", got, err)
+	}
+}
+
+func TestRuntimeErrorStack(t *testing.T) {
+	in := New()
+	if _, err := in.Eval("let inner = fn() { 1 / 0 };\nlet outer = fn() { inner() + 1 };"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := in.Call("outer")
+	var rerr *RuntimeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("want *RuntimeError, got %T (%v)", err, err)
+	}
+	// the call from Go has no call site, so there is no <program> line
+	want := "runtime error at 1:22: division by zero: 1 / 0\n  at 1:22 in inner\n  at 2:25 in outer"
+	if got := rerr.Traceback(); got != want {
+		t.Errorf("wrong traceback.\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
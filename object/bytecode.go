@@ -0,0 +1,52 @@
+package object
+
+import (
+	"monkey/code"
+	"monkey/token"
+	"strings"
+)
+
+// CompiledFunction a function literal lowered to bytecode, it sits in the constant pool until OpClosure wraps it
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int // parameters included, they take the first slots
+	NumParameters int
+	Name          string // from the literal, empty when anonymous
+
+	// for error messages and tracebacks
+	Positions map[int]token.Position // where the node an instruction was compiled from starts, by offset
+	Locals    []string               // name of every local slot
+	Free      []string               // name of every free variable
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	fn := "fn"
+	if cf.Name != "" {
+		fn += " " + cf.Name
+	}
+	return fn + "(" + strings.Join(cf.Locals[:cf.NumParameters], ", ") + ")"
+}
+
+// Closure a compiled function together with the cells of the variables it captured
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
+
+// Cell a local variable some closure captured, shared so assigning it is seen on both sides
+// Value is nil until the let declaring it ran
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "cell"
+	}
+	return "cell " + c.Value.Inspect()
+}
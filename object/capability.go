@@ -0,0 +1,44 @@
+package object
+
+import "strings"
+
+// Capability something outside the script a builtin reaches for, like files or the clock
+// an evaluator only runs builtins whose capabilities it was granted
+type Capability uint
+
+const (
+	CapPrint  Capability = 1 << iota // writing to the evaluator's output
+	CapIO                            // reading and writing files
+	CapTime                          // the clock, sleeping
+	CapRandom                        // random numbers
+
+	NoCapabilities  Capability = 0
+	AllCapabilities            = CapPrint | CapIO | CapTime | CapRandom
+)
+
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{CapPrint, "print"},
+	{CapIO, "io"},
+	{CapTime, "time"},
+	{CapRandom, "random"},
+}
+
+// Has whether every capability in other is part of c
+func (c Capability) Has(other Capability) bool { return c&other == other }
+
+// String names joined with +, like io+time
+func (c Capability) String() string {
+	var names []string
+	for _, cn := range capabilityNames {
+		if c.Has(cn.cap) {
+			names = append(names, cn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "+")
+}
@@ -0,0 +1,92 @@
+package object
+
+// Environment what identifiers are bound to while evaluating
+// lookups that miss fall through to outer, that is how function bodies see the bindings around them
+type Environment struct {
+	store     map[string]Object
+	constants map[string]bool // names in store that were declared with const
+	outer     *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object), constants: make(map[string]bool)}
+}
+
+// NewEnclosedEnvironment fresh scope on top of outer, used for function calls and loop iterations
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get value bound to name, ok is false when it was never declared
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// GetAt value bound to name exactly depth scopes up, for identifiers the resolver already located
+func (e *Environment) GetAt(depth int, name string) (Object, bool) {
+	for ; depth > 0 && e != nil; depth-- {
+		e = e.outer
+	}
+	if e == nil {
+		return nil, false
+	}
+	obj, ok := e.store[name]
+	return obj, ok
+}
+
+// Set declares (or redeclares) name in this scope, this is what let does
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	delete(e.constants, name)
+	return val
+}
+
+// SetConstant declares name in this scope the way const does, Assign will refuse to rebind it
+func (e *Environment) SetConstant(name string, val Object) Object {
+	e.store[name] = val
+	e.constants[name] = true
+	return val
+}
+
+// Assign rebinds an already declared name in whichever scope declared it
+// returns false when there is nothing to rebind, constants have to be checked with IsConstant first
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
+// IsConstant whether the binding name resolves to was declared with const
+func (e *Environment) IsConstant(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.constants[name]
+	}
+	if e.outer != nil {
+		return e.outer.IsConstant(name)
+	}
+	return false
+}
+
+// Owns whether name is declared in this very scope, not in one of the outer ones
+func (e *Environment) Owns(name string) bool {
+	_, ok := e.store[name]
+	return ok
+}
+
+// Delete forgets name in this scope, constant or not
+// the REPL uses it to let a line explicitly redefine what an earlier one declared
+func (e *Environment) Delete(name string) {
+	delete(e.store, name)
+	delete(e.constants, name)
+}
@@ -0,0 +1,298 @@
+package object
+
+import (
+	"fmt"
+	"hash/fnv"
+	"monkey/ast"
+	"monkey/token"
+	"strconv"
+	"strings"
+)
+
+// ObjectType explicit type so every value the evaluator produces is tagged with one of the kinds below
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	NULL_OBJ         = "NULL"
+	ERROR_OBJ        = "ERROR"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	STRING_OBJ       = "STRING"
+	FUNCTION_OBJ     = "FUNCTION"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	BUILTIN_OBJ      = "BUILTIN"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
+
+	// only the bytecode vm has these
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
+	CELL_OBJ              = "CELL"
+)
+
+// Object = every value that exists while evaluating a program
+//
+//	The AST says what to do, objects are what we get after doing it.
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+// Integer wraps the value of an IntegerLiteral or of any integer arithmetic
+type Integer struct {
+	Value int
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+
+// Boolean printed the same way it is written, yes/no
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string {
+	if b.Value {
+		return "yes"
+	}
+	return "no"
+}
+
+// Null absence of a value
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+// Error runtime error, like division by zero
+// it travels up through the evaluator like any other value and stops evaluation once it reaches the top
+type Error struct {
+	Message string
+	Pos     token.Position // where it happened, the zero value when that is not known
+	Limit   bool           // evaluation was stopped from the outside, by a limit or a cancelled context
+	Stack   []Frame        // the function calls it unwound through, innermost first
+	Value   Object         // what a throw statement threw, nil for errors the evaluator raised itself
+}
+
+// Frame one function call an error unwound through
+type Frame struct {
+	Function string         // name of the called function, empty when anonymous
+	Pos      token.Position // the call site, the zero value when called from Go
+}
+
+// tracebacks longer than this only show their first and last frames
+const maxTracebackFrames = 20
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if e.Pos.Line == 0 {
+		return "runtime error: " + e.Message
+	}
+	return fmt.Sprintf("runtime error at %v: %v", e.Pos, e.Message)
+}
+
+// Traceback Inspect followed by one line per function the error unwound through, innermost first
+//
+//	runtime error at 2:25: type mismatch: INTEGER + STRING
+//	  at 2:25 in add
+//	  at 5:7 in twice
+//	  at 7:6 in <program>
+func (e *Error) Traceback() string {
+	if len(e.Stack) == 0 {
+		return e.Inspect()
+	}
+
+	// each frame ran until the position its callee was called from, the innermost one until the error
+	lines := make([]string, 0, len(e.Stack)+1)
+	pos := e.Pos
+	for _, frame := range e.Stack {
+		name := frame.Function
+		if name == "" {
+			name = "<anonymous fn>"
+		}
+		lines = append(lines, fmt.Sprintf("  at %v in %s", pos, name))
+		pos = frame.Pos
+	}
+	if pos.Line != 0 {
+		lines = append(lines, fmt.Sprintf("  at %v in <program>", pos))
+	}
+
+	if len(lines) > maxTracebackFrames {
+		half := maxTracebackFrames / 2
+		skipped := fmt.Sprintf("  ... %d more calls", len(lines)-2*half)
+		lines = append(append(lines[:half:half], skipped), lines[len(lines)-half:]...)
+	}
+	return e.Inspect() + "\n" + strings.Join(lines, "\n")
+}
+
+// ErrorValue an Error caught by try/catch, wrapped so it is an ordinary value scripts can bind, pass around and throw again
+type ErrorValue struct {
+	Err *Error
+}
+
+func (ev *ErrorValue) Type() ObjectType { return ERROR_VALUE_OBJ }
+func (ev *ErrorValue) Inspect() string  { return "error(" + strconv.Quote(ev.Err.Message) + ")" }
+
+// String immutable, every operation on it makes a new one
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return strconv.Quote(s.Value) }
+
+// Function a function literal together with the environment it was created in, so it can close over it
+type Function struct {
+	Name       string // from the literal, empty when anonymous
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string {
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.ToString())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") " + f.Body.ToString()
+}
+
+// BuiltinFunction Go side of a builtin, the arguments have already been checked against the Builtin's Params
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin a function implemented in Go, scripts call it like any other function
+type Builtin struct {
+	Name string
+	// Params[i] lists the types argument i may have, an empty list accepts any type
+	Params [][]ObjectType
+	// Variadic the last entry of Params applies to any number of trailing arguments, none included,
+	// with no Params at all any number of arguments of any type is accepted
+	Variadic bool
+	// Requires what the builtin reaches for outside the script, calls fail unless the evaluator grants all of it
+	Requires Capability
+	Fn       BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin " + b.Name }
+
+// ReturnValue wraps the value of a send statement while it travels up to the enclosing function call
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Break travels up from a break statement to the innermost loop, same as ReturnValue does for send
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+// Continue travels up from a continue statement to the innermost loop
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// Array elements are shared, arr[0] = 5 is seen by every binding holding the same array
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string  { return inspect(a, map[Object]bool{}) }
+
+// inspect obj, an array or hash already being printed further out is printed as [...] or {...},
+// since index assignment can put a collection inside itself
+func inspect(obj Object, printing map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if printing[obj] {
+			return "[...]"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+		elements := []string{}
+		for _, el := range obj.Elements {
+			elements = append(elements, inspect(el, printing))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Hash:
+		if printing[obj] {
+			return "{...}"
+		}
+		printing[obj] = true
+		defer delete(printing, obj)
+		pairs := []string{}
+		for _, hk := range obj.Order {
+			pair := obj.Pairs[hk]
+			pairs = append(pairs, inspect(pair.Key, printing)+": "+inspect(pair.Value, printing))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	}
+	return obj.Inspect()
+}
+
+// HashKey what a value is stored under in a Hash, two equal integers give the same key
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable implemented by every object that can be used as a hash key
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// HashPair keeps the original key object around, HashKey alone cannot be printed
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash pairs plus the order keys were first inserted in, so printing (and iterating) is deterministic
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Order []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds or overwrites the pair, an overwritten key keeps its original position
+func (h *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	if _, ok := h.Pairs[hk]; !ok {
+		h.Order = append(h.Order, hk)
+	}
+	h.Pairs[hk] = HashPair{Key: key.(Object), Value: value}
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return inspect(h, map[Object]bool{}) }
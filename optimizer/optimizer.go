@@ -0,0 +1,289 @@
+// Package optimizer rewrites a program into one that means the same but does less at runtime:
+// constant operations are folded, identities like x * 1 are dropped and when branches that can never run are pruned
+//
+// a program has to fail the same way after optimizing as before, so an operation that fails is never folded
+// and an identity only goes when the operand it keeps is known to be an integer, "a" * 1 is an error that x * 1 must not hide
+package optimizer
+
+import (
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+	"strconv"
+)
+
+// Optimize rewrites program in place and returns it
+// positions of what is left are kept, a folded literal spans the source of the operation it replaced
+func Optimize(program *ast.Program) *ast.Program {
+	o := &optimizer{ints: make(map[*ast.Identifier]bool), declared: make(map[string]int), assigned: make(map[string]bool)}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			o.declared[node.Name.Value]++
+		case *ast.AssignStatement:
+			if id, ok := node.Target.(*ast.Identifier); ok {
+				o.assigned[id.Value] = true
+			}
+		}
+		return true
+	})
+	o.scope = &scope{names: make(map[string]bool)}
+	o.visit(program)
+
+	return ast.Rewrite(program, o.rewrite).(*ast.Program)
+}
+
+type optimizer struct {
+	ints     map[*ast.Identifier]bool // identifiers that are an integer whenever they have a value at all
+	declared map[string]int           // how many lets anywhere declare a name
+	assigned map[string]bool          // names assigned to anywhere
+	scope    *scope
+}
+
+// scope names declared in a scope of the evaluator, and whether each holds an integer for good
+type scope struct {
+	names map[string]bool
+	outer *scope
+}
+
+func (o *optimizer) lookup(name string) bool {
+	for s := o.scope; s != nil; s = s.outer {
+		if isInt, ok := s.names[name]; ok {
+			return isInt
+		}
+	}
+	return false
+}
+
+func (o *optimizer) inScope(bound []*ast.Identifier, body ast.Node) {
+	o.scope = &scope{names: make(map[string]bool), outer: o.scope}
+	defer func() { o.scope = o.scope.outer }()
+	for _, id := range bound {
+		if id != nil {
+			o.scope.names[id.Value] = false
+		}
+	}
+	o.visit(body)
+}
+
+// visit finds the identifiers that are integers, walking the scopes in the order the evaluator creates them
+// a binding counts when it is the only declaration of its name, holds an integer and is never assigned to,
+// closures may run at any time later, so anything weaker could change under them
+func (o *optimizer) visit(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		o.visit(node.Value)
+		name := node.Name.Value
+		o.scope.names[name] = o.declared[name] == 1 && !o.assigned[name] && o.isInt(node.Value)
+	case *ast.Identifier:
+		if o.lookup(node.Value) {
+			o.ints[node] = true
+		}
+	case *ast.FunctionLiteral:
+		o.inScope(node.Parameters, node.Body)
+	case *ast.WhileStatement:
+		o.visit(node.Condition)
+		o.inScope(nil, node.Body)
+	case *ast.ForStatement:
+		o.visit(node.Iterable)
+		o.inScope([]*ast.Identifier{node.Variable}, node.Body)
+	case *ast.TryExpression:
+		o.visit(node.Block)
+		o.inScope([]*ast.Identifier{node.Param}, node.Handler)
+	default:
+		ast.Inspect(node, func(child ast.Node) bool {
+			if child == node {
+				return true
+			}
+			if child != nil {
+				o.visit(child)
+			}
+			return false
+		})
+	}
+}
+
+// isInt whether exp is an integer whenever it does not fail
+// only integers have -, *, /, %, **, the bitwise operators and ~, so those always give one; + needs both sides to be
+func (o *optimizer) isInt(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return true
+	case *ast.Identifier:
+		return o.ints[exp]
+	case *ast.GroupedExpression:
+		return o.isInt(exp.Expression)
+	case *ast.PrefixExpression:
+		return exp.Operator == "-" || exp.Operator == "~"
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case "-", "*", "/", "%", "**", "&", "|", "^", "<<", ">>":
+			return true
+		case "+":
+			return o.isInt(exp.Left) && o.isInt(exp.Right)
+		}
+	}
+	return false
+}
+
+// isBool whether exp is yes or no whenever it does not fail
+func isBool(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return true
+	case *ast.GroupedExpression:
+		return isBool(exp.Expression)
+	case *ast.PrefixExpression:
+		return exp.Operator == "!"
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case "==", "!=", "<", ">", "<=", ">=":
+			return true
+		}
+	}
+	return false
+}
+
+// identities operator and the operand that leaves the other one as it is, when it may stand on either side
+var identities = map[string]struct {
+	value       int
+	commutative bool
+}{
+	"+":  {0, true},
+	"-":  {0, false},
+	"*":  {1, true},
+	"/":  {1, false},
+	"**": {1, false},
+	"|":  {0, true},
+	"^":  {0, true},
+	"<<": {0, false},
+	">>": {0, false},
+}
+
+// rewrite gets every node after its children were rewritten
+func (o *optimizer) rewrite(node ast.Node) ast.Node {
+	switch node := node.(type) {
+	case *ast.GroupedExpression:
+		if _, ok := constant(node.Expression); ok {
+			return node.Expression
+		}
+	case *ast.PrefixExpression:
+		if right, ok := constant(node.Right); ok {
+			return fold(node, evaluator.Prefix(node.Operator, right))
+		}
+		// !!x is x when x is yes or no already
+		if inner, ok := ast.Unparen(node.Right).(*ast.PrefixExpression); ok && node.Operator == "!" && inner.Operator == "!" && isBool(inner.Right) {
+			return inner.Right
+		}
+	case *ast.InfixExpression:
+		left, lok := constant(node.Left)
+		right, rok := constant(node.Right)
+		if lok && rok {
+			return fold(node, evaluator.Infix(node.Operator, left, right))
+		}
+		if id, ok := identities[node.Operator]; ok {
+			if isValue(right, id.value) && o.isInt(node.Left) {
+				return node.Left
+			}
+			if id.commutative && isValue(left, id.value) && o.isInt(node.Right) {
+				return node.Right
+			}
+		}
+	case *ast.WhenExpression:
+		return prune(node)
+	case *ast.BlockStatement:
+		node.Statements = splice(node.Statements)
+	case *ast.Program:
+		node.Statements = splice(node.Statements)
+	}
+	return node
+}
+
+// the value of a literal, false for anything else
+func constant(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: exp.Value}, true
+	case *ast.Boolean:
+		// booleans compare by identity, so it has to be the evaluator's own
+		if exp.Value {
+			return evaluator.YES, true
+		}
+		return evaluator.NO, true
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}, true
+	}
+	return nil, false
+}
+
+func isValue(obj object.Object, value int) bool {
+	i, ok := obj.(*object.Integer)
+	return ok && i.Value == value
+}
+
+// the literal for what node evaluated to, node itself when that was an error or something without a literal
+func fold(node ast.Expression, result object.Object) ast.Expression {
+	switch result := result.(type) {
+	case *object.Integer:
+		tok := token.Token{Type: token.INT, Literal: strconv.Itoa(result.Value), Pos: node.Pos(), End: node.End()}
+		return &ast.IntegerLiteral{Token: tok, Value: result.Value}
+	case *object.Boolean:
+		return boolean(node, result.Value)
+	}
+	return node
+}
+
+// yes or no in the place of node
+func boolean(node ast.Node, value bool) *ast.Boolean {
+	tok := token.Token{Type: token.NO, Literal: "no", Pos: node.Pos(), End: node.End()}
+	if value {
+		tok.Type, tok.Literal = token.YES, "yes"
+	}
+	return &ast.Boolean{Token: tok, Value: value}
+}
+
+// a when with a constant condition keeps only the branch that runs, as when (yes) { branch }, or when (no) {} for none
+// a branch that is a single expression takes the place of the whole when, when blocks share the scope around them
+func prune(when *ast.WhenExpression) ast.Expression {
+	cond, ok := constant(when.Condition)
+	if !ok {
+		return when
+	}
+	if !evaluator.IsTruthy(cond) {
+		if when.Alternative == nil {
+			when.Consequence.Statements = []ast.Statement{}
+			return when
+		}
+		when.Consequence = when.Alternative
+	}
+	when.Condition, when.Alternative = boolean(when.Condition, true), nil
+	if len(when.Consequence.Statements) == 1 {
+		if es, ok := when.Consequence.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expression
+		}
+	}
+	return when
+}
+
+// statements of whens that always run take the place of the when, whens that never run are dropped
+// the last statement is left alone, it is what the block evaluates to and a when without a branch to run is null
+func splice(stmts []ast.Statement) []ast.Statement {
+	out := make([]ast.Statement, 0, len(stmts))
+	for i, stmt := range stmts {
+		if i < len(stmts)-1 {
+			if es, ok := stmt.(*ast.ExpressionStatement); ok {
+				if when, ok := es.Expression.(*ast.WhenExpression); ok {
+					if cond, ok := constant(when.Condition); ok {
+						if evaluator.IsTruthy(cond) {
+							out = append(out, when.Consequence.Statements...)
+						}
+						continue
+					}
+				}
+			}
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package optimizer
+
+import (
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	return program
+}
+
+// what a program evaluates to, errors with where they happened
+func run(program *ast.Program) string {
+	result := evaluator.New(io.Discard).Eval(program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); ok {
+		return err.Traceback()
+	}
+	if result == nil {
+		return "null"
+	}
+	return result.Inspect()
+}
+
+// the statements of program, expression statements without the token ToString puts in front
+func show(program *ast.Program) string {
+	stmts := make([]string, len(program.Statements))
+	for i, stmt := range program.Statements {
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			stmts[i] = es.Expression.ToString()
+		} else {
+			stmts[i] = strings.TrimSuffix(stmt.ToString(), ";")
+		}
+	}
+	return strings.Join(stmts, "; ")
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// folding
+		{"-(5)", "-5"},
+		{"2 * 3 + 4 ** 2 % 5", "7"},
+		{"1 < 2 == !no", "yes"},
+		{`"a" == "a"`, "yes"},
+		{"~0 >> 1 << 2", "-4"},
+		// what fails is left for the runtime to fail on
+		{"1 / 0", "(1 / 0)"},
+		{"2 ** -1 + 1", "((2 ** -1) + 1)"},
+		{`"a" * 1`, `("a" * 1)`},
+		{`"a" + "b"`, `("a" + "b")`},
+		// identities, only on what is an integer for sure
+		{"let x = 2; 2 * 3 + x * 1", "let x = 2; (6 + x)"},
+		{"const x = 2; 0 + x - 0", "const x = 2; x"},
+		{"let f = fn(a) { (a - 1) * 1 + 0 }", "let f = fn(a) { ( (a - 1); }"},
+		{"let f = fn(a) { a * 1 }", "let f = fn(a) { a (a * 1); }"},
+		{"let x = 2; x = 3; x * 1", "let x = 2; x = 3; (x * 1)"},
+		{"let x = 2; let x = \"s\"; x * 1", `let x = 2; let x = "s"; (x * 1)`},
+		{"let x = 2; let f = fn(x) { x * 1 }", "let x = 2; let f = fn(x) { x (x * 1); }"},
+		{"let s = \"a\" + \"b\"; s + 0", `let s = ("a" + "b"); (s + 0)`},
+		{"!!yes", "yes"},
+		{"let a = 1; !!(a < 2)", "let a = 1; (a < 2)"},
+		{"let a = 1; !!a", "let a = 1; (!(!a))"},
+		// pruning
+		{"when (1 < 2) { 10 } otherwise { 20 }", "10"},
+		{"when (no) { 10 } otherwise { 20 }", "20"},
+		{"when (no) { 10 }", "when no {  }"},
+		{"when (yes) { let a = 1; a }", "when yes { let a = 1;a a; }"},
+		{"when (yes) { let a = 1; a }; a", "let a = 1; a; a"},
+		{"when (no) { let a = 1 }; 5", "5"},
+		{"let f = fn() { 1; when (no) { 2 } }", "let f = fn() { 1 1;when when no {  }; }"},
+	}
+
+	for _, tt := range tests {
+		if have := show(Optimize(parse(t, tt.input))); have != tt.expected {
+			t.Errorf("input %q:\nwant %s\nhave %s", tt.input, tt.expected, have)
+		}
+	}
+}
+
+// optimized or not, a program has to come out the same, failures and where they happen included
+func TestSameResults(t *testing.T) {
+	tests := []string{
+		"-(5) + 2 * 3",
+		"let x = 4; x * 1 + 0 - x / 1",
+		"1 / 0",
+		"let a = 3; a + (2 - 2) / (1 - 1)",
+		`let s = "x"; s * 1`,
+		`let f = fn(a) { a * 1 }; f("s")`,
+		"when (no) { 1 }",
+		"let f = fn() { 1; when (no) { 2 } }; f()",
+		"when (yes) { let a = 7 }; a * 1",
+		"let r = 0; for (i in [1, 2, 3]) { when (1 > 2) { r += 100 } otherwise { r += i * 1 } }; r",
+		"let g = fn() { x * 1 }; let x = 5; g()",
+		"!!yes == !!(1 < 2)",
+		"let y = 1; try { throw y ** 1 } catch (e) { e + 0 }",
+	}
+
+	for _, input := range tests {
+		want := run(parse(t, input))
+		if have := run(Optimize(parse(t, input))); have != want {
+			t.Errorf("input %q: want %q, have %q", input, want, have)
+		}
+	}
+}
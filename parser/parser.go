@@ -17,6 +17,13 @@ type Parser struct {
 	// (infix or prefix) has a parsing function associated with curToken.Type.
 	prefix map[token.TokenType]prefixParseFunc
 	infix  map[token.TokenType]infixParseFunc
+
+	// how many loops deep we are, break/continue are only allowed when > 0
+	// a function body starts over from 0, a loop outside the function cannot be broken from inside it
+	loopDepth int
+
+	// how many { are open at curToken, so synchronize can find the end of a broken statement
+	depth int
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFunc) {
@@ -29,10 +36,18 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFunc) {
 // parses some statement(node) based on what kind of node it is
 func (p *Parser) parseStatement() (ast.Statement, error) {
 	switch p.curToken.Type {
-	case token.LET: // let [HERE] x = 5
+	case token.LET, token.CONST: // let [HERE] x = 5
 		return p.parseLetStatement()
-	case token.SEND: // let [HERE] x = 5
+	case token.SEND: // send [HERE] x + 5
 		return p.parseSendStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -42,18 +57,135 @@ func (p *Parser) parseSendStatement() (*ast.SendStatement, error) {
 	// at this point we know statemetn is "SEND *****", curToken is on SEND
 	// start making SEND statment
 	send := &ast.SendStatement{Token: p.curToken}
+	p.NextToken()
 
-	//todo))  expression code here
-
-	// fixme)) below skips expressions
-	for p.curToken.Type != token.SEMICOLON {
+	var err error
+	if send.Value, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
 		p.NextToken()
 	}
-	// fixme))
 
 	return send, nil
 }
 
+// while (condition) { body }
+func (p *Parser) parseWhileStatement() (*ast.WhileStatement, error) {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if ok, err := p.peekTokenTypeIs(token.LPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	p.NextToken()
+
+	var err error
+	if stmt.Condition, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, err := p.peekTokenTypeIs(token.RPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	if stmt.Body, err = p.parseLoopBody(); err != nil {
+		return nil, err
+	}
+	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
+		p.NextToken()
+	}
+	return stmt, nil
+}
+
+// for (x in collection) { body }
+func (p *Parser) parseForStatement() (*ast.ForStatement, error) {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if ok, err := p.peekTokenTypeIs(token.LPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	if ok, err := p.peekTokenTypeIs(token.IDENT); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if ok, err := p.peekTokenTypeIs(token.IN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	p.NextToken()
+
+	var err error
+	if stmt.Iterable, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, err := p.peekTokenTypeIs(token.RPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	if stmt.Body, err = p.parseLoopBody(); err != nil {
+		return nil, err
+	}
+	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
+		p.NextToken()
+	}
+	return stmt, nil
+}
+
+// parses the { body } of a loop, curToken is on the token right before {
+func (p *Parser) parseLoopBody() (*ast.BlockStatement, error) {
+	if ok, err := p.peekTokenTypeIs(token.LBRACE); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+// break; or continue;
+func (p *Parser) parseLoopControlStatement() (ast.Statement, error) {
+	var stmt ast.Statement = &ast.ContinueStatement{Token: p.curToken}
+	if p.currTokenTypeIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	}
+	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
+		p.NextToken()
+	}
+
+	// the statement itself is fine, it is just in the wrong place, so hand it back along with the error
+	if p.loopDepth == 0 {
+		return stmt, p.errorf(p.curToken, "%v outside of loop", stmt.TokenLiteral())
+	}
+	return stmt, nil
+}
+
+// parses statements up to the matching }, curToken is on { and ends up on }
+func (p *Parser) parseBlockStatement() (*ast.BlockStatement, error) {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+	p.NextToken()
+
+	for !p.currTokenTypeIs(token.RBRACE) {
+		if p.currTokenTypeIs(token.EOF) {
+			return nil, p.errorf(p.curToken, "WANT %v before %v", token.RBRACE, token.EOF)
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		block.Statements = append(block.Statements, stmt)
+		p.NextToken()
+	}
+	block.Rbrace = p.curToken.Pos
+	return block, nil
+}
+
 func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 	// start making let statement
 	// at this point we know statement is "LET *****" nothing beyond LET
@@ -61,14 +193,23 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 
 	// first after "let" should be identifier, fail otherwise
 	if ok, err := p.peekTokenTypeIs(token.IDENT); !ok {
-		fmt.Println("ok,err", ok, err)
 		return nil, err
 	}
+	p.NextToken()
 	let.Name = &ast.Identifier{
 		Token: p.curToken,
-		Value: p.peekToken.Literal,
+		Value: p.curToken.Literal,
+	}
+
+	// "LET x: int" the type is optional
+	if p.peekToken.Type == token.COLON {
+		p.NextToken()
+		p.NextToken()
+		var err error
+		if let.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
 	}
-	p.NextToken()
 
 	// at this point we know, "LET x ****" begins with let and has valid identifier x
 	// next should be assignment operator, fail otherwise
@@ -77,18 +218,62 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, error) {
 	}
 	// fmt.Println("Passing ASSIGN")
 	p.NextToken()
+	p.NextToken()
 
-	//todo))  expression code here
-
-	// fixme)) below skips expressions
-	for p.curToken.Type != token.SEMICOLON {
+	// "LET x = [HERE]" everything up to the semicolon is the value
+	var err error
+	if let.Value, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	// let f = fn() {} names the function, so tracebacks can tell which one failed
+	if fl, ok := ast.Unparen(let.Value).(*ast.FunctionLiteral); ok {
+		fl.Name = let.Name.Value
+	}
+	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
 		p.NextToken()
 	}
-	// fixme))
 
 	return let, nil
 }
 
+// tokens that turn an expression statement into an assignment
+var assignOperators = map[token.TokenType]bool{
+	token.ASSIGN:          true,
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.MULTIPLY_ASSIGN: true,
+	token.DIVIDE_ASSIGN:   true,
+}
+
+// parses "x = 5" / "arr[0] += 1", curToken is on the last token of the already parsed target
+func (p *Parser) parseAssignStatement(target ast.Expression) (*ast.AssignStatement, error) {
+	// (x) = 1 assigns to x, the parentheses do not change what the target is
+	target = ast.Unparen(target)
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		return nil, p.errorf(p.peekToken, "cannot assign to %v", target.ToString())
+	}
+
+	p.NextToken()
+	assign := &ast.AssignStatement{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+	p.NextToken()
+
+	var err error
+	if assign.Value, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
+		p.NextToken()
+	}
+
+	return assign, nil
+}
+
 // func (p *Parser) appendError(tt token.TokenType) {
 // 	p.errors = append(p.errors, fmt.Sprintf()
 // }
@@ -109,7 +294,7 @@ func (p *Parser) peekTokenTypeIs(tt token.TokenType) (bool, error) {
 	if p.peekToken.Type == tt {
 		return true, nil
 	} else {
-		return false, fmt.Errorf("parser error: AFTER %v WANT %v, HAVE %v", p.curToken, tt, p.peekToken.Type)
+		return false, p.errorf(p.peekToken, "AFTER %q WANT %v, HAVE %v", p.curToken.Literal, tt, p.peekToken.Type)
 	}
 }
 
@@ -119,17 +304,35 @@ func New(l *lexer.Lexer) *Parser {
 	p.NextToken()
 	p.NextToken()
 
-	fmt.Println("Registering prefixes for parser")
 	p.prefix = make(map[token.TokenType]prefixParseFunc)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
-	p.registerPrefix(token.NOT, p.parsePrefixExpression)   // like for !5
-	p.registerPrefix(token.MINUS, p.parsePrefixExpression) // like for -15
+	p.registerPrefix(token.NOT, p.parsePrefixExpression)     // like for !5
+	p.registerPrefix(token.MINUS, p.parsePrefixExpression)   // like for -15
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression) // like for ~15
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression) // like for (5 + 5)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)    // like for [1, 2]
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)       // like for {1: 2}
+	p.registerPrefix(token.YES, p.parseBoolean)
+	p.registerPrefix(token.NO, p.parseBoolean)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.WHEN, p.parseWhenExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal) // never valid, but says why instead of "unknown prefix"
+
+	// every operator with an entry in precedences is parsed the same way
+	p.infix = make(map[token.TokenType]infixParseFunc)
+	for tt := range precedences {
+		p.registerInfix(tt, p.parseInfixExpression)
+	}
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression) // like for arr[0]
+	p.registerInfix(token.LPAREN, p.parseCallExpression)    // like for add(1, 2)
 
 	return p
 }
 
-func (p *Parser) parsePrefixExpression() ast.Expression {
+func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -138,30 +341,442 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	var err error
 	expression.Right, err = p.parseExpression(PREFIX)
 	if err != nil {
-		fmt.Errorf("parser error: %v", err)
+		return nil, err
+	}
+	return expression, nil
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) (ast.Expression, error) {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	// ** is right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	// parsing the right side with a lower precedence lets the next ** bind first
+	if p.currTokenTypeIs(token.POWER) {
+		precedence--
+	}
+	p.NextToken()
+
+	var err error
+	expression.Right, err = p.parseExpression(precedence)
+	if err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+func (p *Parser) parseGroupedExpression() (ast.Expression, error) {
+	grouped := &ast.GroupedExpression{Token: p.curToken}
+	p.NextToken()
+
+	var err error
+	if grouped.Expression, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, err := p.peekTokenTypeIs(token.RPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	grouped.Rparen = p.curToken.Pos
+	return grouped, nil
+}
+
+func (p *Parser) parseArrayLiteral() (ast.Expression, error) {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	var err error
+	array.Elements, err = p.parseExpressionList(token.RBRACKET)
+	if err != nil {
+		return nil, err
+	}
+	array.Rbracket = p.curToken.Pos
+	return array, nil
+}
+
+// parses "a, b, c" up to and including the end token, curToken is on the opening token
+func (p *Parser) parseExpressionList(end token.TokenType) ([]ast.Expression, error) {
+	list := []ast.Expression{}
+	if ok, _ := p.peekTokenTypeIs(end); ok {
+		p.NextToken()
+		return list, nil
+	}
+
+	p.NextToken()
+	item, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+	list = append(list, item)
+
+	for p.peekToken.Type == token.COMMA {
+		p.NextToken()
+		p.NextToken()
+		if item, err = p.parseExpression(LOWEST); err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+
+	if ok, err := p.peekTokenTypeIs(end); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	return list, nil
+}
+
+func (p *Parser) parseHashLiteral() (ast.Expression, error) {
+	hash := &ast.HashLiteral{Token: p.curToken}
+
+	for p.peekToken.Type != token.RBRACE {
+		p.NextToken()
+		key, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := p.peekTokenTypeIs(token.COLON); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		p.NextToken()
+		value, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		hash.Keys = append(hash.Keys, key)
+		hash.Values = append(hash.Values, value)
+
+		// either another pair or the closing brace
+		if p.peekToken.Type != token.RBRACE {
+			if ok, err := p.peekTokenTypeIs(token.COMMA); !ok {
+				return nil, err
+			}
+			p.NextToken()
+		}
+	}
+	p.NextToken()
+	hash.Rbrace = p.curToken.Pos
+
+	return hash, nil
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) (ast.Expression, error) {
+	expression := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.NextToken()
+
+	var err error
+	if expression.Index, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, err := p.peekTokenTypeIs(token.RBRACKET); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	expression.Rbracket = p.curToken.Pos
+	return expression, nil
+}
+
+func (p *Parser) parseIllegal() (ast.Expression, error) {
+	return nil, p.errorf(p.curToken, "illegal %q, %v", p.curToken.Literal, p.curToken.Reason)
+}
+
+func (p *Parser) parseBoolean() (ast.Expression, error) {
+	return &ast.Boolean{Token: p.curToken, Value: p.currTokenTypeIs(token.YES)}, nil
+}
+
+func (p *Parser) parseStringLiteral() (ast.Expression, error) {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}, nil
+}
+
+// when (condition) { ... } otherwise { ... }
+func (p *Parser) parseWhenExpression() (ast.Expression, error) {
+	expression := &ast.WhenExpression{Token: p.curToken}
+
+	if ok, err := p.peekTokenTypeIs(token.LPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	p.NextToken()
+
+	var err error
+	if expression.Condition, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, err := p.peekTokenTypeIs(token.RPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+	if ok, err := p.peekTokenTypeIs(token.LBRACE); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	if expression.Consequence, err = p.parseBlockStatement(); err != nil {
+		return nil, err
+	}
+
+	if p.peekToken.Type == token.OTHERWISE {
+		p.NextToken()
+		if ok, err := p.peekTokenTypeIs(token.LBRACE); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		if expression.Alternative, err = p.parseBlockStatement(); err != nil {
+			return nil, err
+		}
+	}
+	return expression, nil
+}
+
+// throw [HERE] "reason"
+func (p *Parser) parseThrowStatement() (*ast.ThrowStatement, error) {
+	throw := &ast.ThrowStatement{Token: p.curToken}
+	p.NextToken()
+
+	var err error
+	if throw.Value, err = p.parseExpression(LOWEST); err != nil {
+		return nil, err
+	}
+	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
+		p.NextToken()
+	}
+	return throw, nil
+}
+
+// try { ... } catch (e) { ... }, the (e) is optional
+func (p *Parser) parseTryExpression() (ast.Expression, error) {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if ok, err := p.peekTokenTypeIs(token.LBRACE); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	var err error
+	if expression.Block, err = p.parseBlockStatement(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.peekTokenTypeIs(token.CATCH); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	if p.peekToken.Type == token.LPAREN {
+		p.NextToken()
+		if ok, err := p.peekTokenTypeIs(token.IDENT); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if ok, err := p.peekTokenTypeIs(token.RPAREN); !ok {
+			return nil, err
+		}
+		p.NextToken()
+	}
+	if ok, err := p.peekTokenTypeIs(token.LBRACE); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	if expression.Handler, err = p.parseBlockStatement(); err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+// fn(x, y) { ... }
+func (p *Parser) parseFunctionLiteral() (ast.Expression, error) {
+	function := &ast.FunctionLiteral{Token: p.curToken}
+
+	if ok, err := p.peekTokenTypeIs(token.LPAREN); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	var err error
+	if function.Parameters, function.ParamTypes, err = p.parseFunctionParameters(); err != nil {
+		return nil, err
+	}
+	if p.peekToken.Type == token.ARROW {
+		p.NextToken()
+		p.NextToken()
+		if function.ReturnType, err = p.parseType(); err != nil {
+			return nil, err
+		}
 	}
-	return expression
+	if ok, err := p.peekTokenTypeIs(token.LBRACE); !ok {
+		return nil, err
+	}
+	p.NextToken()
+
+	// loops around the function literal are not reachable from its body
+	outerLoopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = outerLoopDepth }()
+
+	if function.Body, err = p.parseBlockStatement(); err != nil {
+		return nil, err
+	}
+	return function, nil
+}
+
+// parses "(x, y)", curToken is on ( and ends up on )
+// every parameter may be annotated, "(x: int, y)", types has a nil entry for the ones that are not
+func (p *Parser) parseFunctionParameters() (identifiers []*ast.Identifier, types []*ast.TypeExpression, err error) {
+	identifiers = []*ast.Identifier{}
+	if p.peekToken.Type == token.RPAREN {
+		p.NextToken()
+		return identifiers, nil, nil
+	}
+
+	for {
+		if ok, err := p.peekTokenTypeIs(token.IDENT); !ok {
+			return nil, nil, err
+		}
+		p.NextToken()
+		identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		var typ *ast.TypeExpression
+		if p.peekToken.Type == token.COLON {
+			p.NextToken()
+			p.NextToken()
+			if typ, err = p.parseType(); err != nil {
+				return nil, nil, err
+			}
+		}
+		types = append(types, typ)
+
+		if p.peekToken.Type != token.COMMA {
+			break
+		}
+		p.NextToken()
+	}
+
+	if ok, err := p.peekTokenTypeIs(token.RPAREN); !ok {
+		return nil, nil, err
+	}
+	p.NextToken()
+	return identifiers, types, nil
+}
+
+// parses a type annotation starting at curToken, which ends up on its last token
+//
+//	int   [int]   {str: int}   fn(int, str) -> bool
+func (p *Parser) parseType() (*ast.TypeExpression, error) {
+	typ := &ast.TypeExpression{Token: p.curToken}
+	switch p.curToken.Type {
+	case token.IDENT:
+		typ.Name = p.curToken.Literal
+	case token.LBRACKET:
+		typ.Name = "array"
+		p.NextToken()
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		typ.Params = []*ast.TypeExpression{elem}
+		if ok, err := p.peekTokenTypeIs(token.RBRACKET); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		typ.Close = p.curToken.Pos
+	case token.LBRACE:
+		typ.Name = "hash"
+		p.NextToken()
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := p.peekTokenTypeIs(token.COLON); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		p.NextToken()
+		value, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		typ.Params = []*ast.TypeExpression{key, value}
+		if ok, err := p.peekTokenTypeIs(token.RBRACE); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		typ.Close = p.curToken.Pos
+	case token.FUNCTION:
+		typ.Name = "fn"
+		if ok, err := p.peekTokenTypeIs(token.LPAREN); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		typ.Params = []*ast.TypeExpression{}
+		for p.peekToken.Type != token.RPAREN {
+			p.NextToken()
+			param, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			typ.Params = append(typ.Params, param)
+			if p.peekToken.Type != token.COMMA {
+				break
+			}
+			p.NextToken()
+		}
+		if ok, err := p.peekTokenTypeIs(token.RPAREN); !ok {
+			return nil, err
+		}
+		p.NextToken()
+		typ.Close = p.curToken.Pos
+		if p.peekToken.Type == token.ARROW {
+			p.NextToken()
+			p.NextToken()
+			var err error
+			if typ.Result, err = p.parseType(); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, p.errorf(p.curToken, "want a type, have %v", p.curToken.Type)
+	}
+	return typ, nil
 }
 
-func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+func (p *Parser) parseCallExpression(function ast.Expression) (ast.Expression, error) {
+	call := &ast.CallExpression{Token: p.curToken, Function: function}
+	var err error
+	if call.Arguments, err = p.parseExpressionList(token.RPAREN); err != nil {
+		return nil, err
+	}
+	call.Rparen = p.curToken.Pos
+	return call, nil
 }
 
-func (p *Parser) parseIntegerLiteral() ast.Expression {
+func (p *Parser) parseIdentifier() (ast.Expression, error) {
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}, nil
+}
+
+func (p *Parser) parseIntegerLiteral() (ast.Expression, error) {
 	intlit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.Atoi(p.curToken.Literal)
 	if err != nil {
-		fmt.Printf("could not parse %q as integer", p.curToken.Literal)
-		return nil
+		return nil, p.errorf(p.curToken, "could not parse %q as integer", p.curToken.Literal)
 	}
 
 	intlit.Value = value
-	return intlit
+	return intlit, nil
 }
 
 func (p *Parser) NextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.depth++
+	case token.RBRACE:
+		p.depth--
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -169,32 +784,71 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.currTokenTypeIs(token.EOF) {
+		start, depth := p.curToken, p.outerDepth()
 		stmt, err := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-			program.Errors = append(program.Errors, err)
+		if err != nil {
+			// whatever was parsed is incomplete, keep the place and the error, skip the rest of the statement
+			stmt = &ast.BadStatement{Token: start}
+			p.synchronize(depth)
 		}
+		program.Statements = append(program.Statements, stmt)
+		program.Errors = append(program.Errors, err)
 		p.NextToken()
 	}
 
 	return program
 }
 
+// how many { are open around curToken, not counting curToken itself
+func (p *Parser) outerDepth() int {
+	switch p.curToken.Type {
+	case token.LBRACE:
+		return p.depth - 1
+	case token.RBRACE:
+		return p.depth + 1
+	}
+	return p.depth
+}
+
+// skips to the end of a statement that failed to parse, so its leftovers do not turn into errors of their own
+// the statement ends at a ; or at the } that closes the last block it opened, depth is where it started
+func (p *Parser) synchronize(depth int) {
+	for !p.currTokenTypeIs(token.EOF) {
+		if p.depth <= depth && p.currTokenTypeIs(token.SEMICOLON) {
+			return
+		}
+		if p.depth <= depth && p.currTokenTypeIs(token.RBRACE) {
+			// let f = fn() { ... }; the ; still belongs to the statement
+			if p.peekToken.Type == token.SEMICOLON {
+				p.NextToken()
+			}
+			return
+		}
+		p.NextToken()
+	}
+}
+
 type (
-	prefixParseFunc func() ast.Expression               //something like ++5, here there is nothing to pass as argument
-	infixParseFunc  func(ast.Expression) ast.Expression //something like add(1,5) + 5, there IS A LEFT side
+	prefixParseFunc func() (ast.Expression, error)               //something like ++5, here there is nothing to pass as argument
+	infixParseFunc  func(ast.Expression) (ast.Expression, error) //something like add(1,5) + 5, there IS A LEFT side
 )
 
-func (p *Parser) parseExpressionStatement() (*ast.ExpressionStatement, error) {
+func (p *Parser) parseExpressionStatement() (ast.Statement, error) {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
-	stmt.Expression, _ = p.parseExpression(LOWEST)
+	var err error
+	stmt.Expression, err = p.parseExpression(LOWEST)
+
+	// "x = 5", what we just parsed was the target of an assignment
+	if err == nil && assignOperators[p.peekToken.Type] {
+		return p.parseAssignStatement(stmt.Expression)
+	}
 
 	if ok, _ := p.peekTokenTypeIs(token.SEMICOLON); ok {
 		p.NextToken()
 	}
 
-	return stmt, nil
+	return stmt, err
 }
 
 func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
@@ -202,12 +856,43 @@ func (p *Parser) parseExpression(precedence int) (ast.Expression, error) {
 	if prefix == nil {
 		return nil, p.noPrefix(p.curToken.Type)
 	}
-	leftExpression := prefix()
+	leftExpression, err := prefix()
+	if err != nil {
+		return nil, err
+	}
+
+	// keep folding into the left side while the next operator binds tighter
+	for p.peekToken.Type != token.SEMICOLON && precedence < p.peekPrecedence() {
+		infix := p.infix[p.peekToken.Type]
+		if infix == nil {
+			return leftExpression, nil
+		}
+		p.NextToken()
+		leftExpression, err = infix(leftExpression)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return leftExpression, nil
 }
 
 func (p *Parser) noPrefix(tokenType token.TokenType) error {
-	return fmt.Errorf("parser error: unknown prefix type %v", p.curToken.Type)
+	return p.errorf(p.curToken, "unknown prefix type %v", tokenType)
+}
+
+// Error a syntax error, Pos and End span the token that was not what the parser wanted
+type Error struct {
+	Pos, End token.Position
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("parser error at %v: %v", e.Pos, e.Message)
+}
+
+// every parser error says where in the source it happened, tok is the token that was not what we wanted
+func (p *Parser) errorf(tok token.Token, format string, a ...interface{}) error {
+	return &Error{Pos: tok.Pos, End: tok.End, Message: fmt.Sprintf(format, a...)}
 }
 
 const (
@@ -215,8 +900,50 @@ const (
 	LOWEST
 	EQUALITY
 	LESSMORE
+	BITOR
+	BITXOR
+	BITAND
+	SHIFT
 	PLUS
 	MULTIPLY
 	PREFIX
+	POWER // above PREFIX so -2 ** 2 is -(2 ** 2)
 	CALL
+	INDEX
 )
+
+var precedences = map[token.TokenType]int{
+	token.EQUALITY:    EQUALITY,
+	token.NEQUALITY:   EQUALITY,
+	token.LESSTHAN:    LESSMORE,
+	token.MORETHAN:    LESSMORE,
+	token.EQ_OR_LESS:  LESSMORE,
+	token.EQ_OR_MORE:  LESSMORE,
+	token.BIT_OR:      BITOR,
+	token.BIT_XOR:     BITXOR,
+	token.BIT_AND:     BITAND,
+	token.SHIFT_LEFT:  SHIFT,
+	token.SHIFT_RIGHT: SHIFT,
+	token.PLUS:        PLUS,
+	token.MINUS:       PLUS,
+	token.MULTIPLY:    MULTIPLY,
+	token.DIVIDE:      MULTIPLY,
+	token.MODULO:      MULTIPLY,
+	token.POWER:       POWER,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
+}
+
+func (p *Parser) peekPrecedence() int {
+	if pr, ok := precedences[p.peekToken.Type]; ok {
+		return pr
+	}
+	return LOWEST
+}
+
+func (p *Parser) curPrecedence() int {
+	if pr, ok := precedences[p.curToken.Type]; ok {
+		return pr
+	}
+	return LOWEST
+}
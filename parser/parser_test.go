@@ -292,3 +292,336 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int) bool {
 	return true
 
 }
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-a * b", "((-a) * b)"},
+		{"a + b * c", "(a + (b * c))"},
+		{"a + b % c - d", "((a + (b % c)) - d)"},
+		{"a * b / c", "((a * b) / c)"},
+		{"(a + b) * c", "((a + b) * c)"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"2 ** -1", "(2 ** (-1))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"~a & b", "((~a) & b)"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a << 1 + b", "(a << (1 + b))"},
+		{"a & 1 == 0", "((a & 1) == 0)"},
+		{"a < b == c >= d", "((a < b) == (c >= d))"},
+		{"1 << 2 >> 3", "((1 << 2) >> 3)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewLexer(tt.input))
+		program := p.ParseProgram()
+		if len(program.Statements) != 1 {
+			t.Fatalf("parsed program has invalid num of statements: want %v, got %v", 1, len(program.Statements))
+		}
+		if err := program.Errors[0]; err != nil {
+			t.Errorf("input %q: unexpected error %v", tt.input, err)
+			continue
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("parsed statement is not ExpressionStatement. got=%T", program.Statements[0])
+		}
+		if got := stmt.Expression.ToString(); got != tt.expected {
+			t.Errorf("input %q: want %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		target   string
+		value    string
+	}{
+		{"x = 10;", "=", "x", "10"},
+		{"x += 1", "+=", "x", "1"},
+		{"x -= y * 2;", "-=", "x", "(y * 2)"},
+		{"x *= 3;", "*=", "x", "3"},
+		{"x /= 3;", "/=", "x", "3"},
+		{"arr[0] = 5;", "=", "(arr[0])", "5"},
+		{"h[k][1] += 2;", "+=", "((h[k])[1])", "2"},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.NewLexer(tt.input)).ParseProgram()
+		if len(program.Statements) != 1 {
+			t.Fatalf("input %q: want 1 statement, got %v", tt.input, len(program.Statements))
+		}
+		if err := program.Errors[0]; err != nil {
+			t.Errorf("input %q: unexpected error %v", tt.input, err)
+			continue
+		}
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Errorf("input %q: statement is not AssignStatement. got=%T", tt.input, program.Statements[0])
+			continue
+		}
+		if stmt.Operator != tt.operator {
+			t.Errorf("input %q: operator want %q, got %q", tt.input, tt.operator, stmt.Operator)
+		}
+		if got := stmt.Target.ToString(); got != tt.target {
+			t.Errorf("input %q: target want %q, got %q", tt.input, tt.target, got)
+		}
+		if got := stmt.Value.ToString(); got != tt.value {
+			t.Errorf("input %q: value want %q, got %q", tt.input, tt.value, got)
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	program := New(lexer.NewLexer("5 = 6;")).ParseProgram()
+	if len(program.Errors) == 0 || program.Errors[0] == nil {
+		t.Fatalf("expected an error assigning to a literal")
+	}
+}
+
+func TestCollectionLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2 * 2, 3 + 3]", "[1, (2 * 2), (3 + 3)]"},
+		{"[]", "[]"},
+		{"{1: 2, a: b + 1}", "{1: 2, a: (b + 1)}"},
+		{"{}", "{}"},
+		{"a * [1, 2][b + c]", "(a * ([1, 2][(b + c)]))"},
+		{"-a[0]", "(-(a[0]))"},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.NewLexer(tt.input)).ParseProgram()
+		if err := program.Errors[0]; err != nil {
+			t.Errorf("input %q: unexpected error %v", tt.input, err)
+			continue
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if got := stmt.Expression.ToString(); got != tt.expected {
+			t.Errorf("input %q: want %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestControlFlowParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"when (x < y) { x }", "when (x < y) { x x; }"},
+		{"when (x) { x } otherwise { y }", "when x { x x; } otherwise { y y; }"},
+		{"fn(x, y) { send x + y; }", "fn(x, y) { send (x + y); }"},
+		{"fn() { 1 }", "fn() { 1 1; }"},
+		{"add(1, 2 * 3, fn(x) { x })", "add(1, (2 * 3), fn(x) { x x; })"},
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{`"hello world"`, `"hello world"`},
+		{"!yes == no", "((!yes) == no)"},
+		{"try { f() } catch (e) { e }", "try { f f(); } catch (e) { e e; }"},
+		{"try { 1 } catch { 2 } + 1", "(try { 1 1; } catch { 2 2; } + 1)"},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.NewLexer(tt.input)).ParseProgram()
+		if err := program.Errors[0]; err != nil {
+			t.Errorf("input %q: unexpected error %v", tt.input, err)
+			continue
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if got := stmt.Expression.ToString(); got != tt.expected {
+			t.Errorf("input %q: want %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLoopParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (i < 10) { i += 1; }", "while (i < 10) { i += 1; }"},
+		{"for (x in [1, 2]) { break; }", "for (x in [1, 2]) { break; }"},
+		{"for (x in xs) { when (x) { continue } }", "for (x in xs) { when when x { continue; }; }"},
+		{"while (yes) { fn() { 1 }; break }", "while yes { fn fn() { 1 1; };break; }"},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.NewLexer(tt.input)).ParseProgram()
+		if len(program.Statements) != 1 {
+			t.Fatalf("input %q: want 1 statement, got %v", tt.input, len(program.Statements))
+		}
+		if err := program.Errors[0]; err != nil {
+			t.Errorf("input %q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got := program.Statements[0].ToString(); got != tt.expected {
+			t.Errorf("input %q: want %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	inputs := []string{
+		"break;",
+		"continue",
+		"when (yes) { break }",
+		"while (yes) { fn() { break } }",
+		"for (x in xs) { let f = fn() { continue; }; }",
+	}
+
+	for _, input := range inputs {
+		program := New(lexer.NewLexer(input)).ParseProgram()
+		if len(program.Errors) == 0 || program.Errors[0] == nil {
+			t.Errorf("input %q: expected a parser error", input)
+		}
+	}
+}
+
+func TestConstStatements(t *testing.T) {
+	program := New(lexer.NewLexer("const pi = 3; let e = 2;")).ParseProgram()
+	if len(program.Statements) != 2 {
+		t.Fatalf("want 2 statements, got %v", len(program.Statements))
+	}
+	tests := []struct {
+		constant bool
+		expected string
+	}{
+		{true, "const pi = 3;"},
+		{false, "let e = 2;"},
+	}
+	for i, tt := range tests {
+		if err := program.Errors[i]; err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		let, ok := program.Statements[i].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("statement is not LetStatement. got=%T", program.Statements[i])
+		}
+		if let.IsConstant() != tt.constant {
+			t.Errorf("statement %d: IsConstant want %v, got %v", i, tt.constant, let.IsConstant())
+		}
+		if got := let.ToString(); got != tt.expected {
+			t.Errorf("statement %d: want %q, got %q", i, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // ToString of each statement, errors are checked to be present exactly for bad ones
+	}{
+		{"let x = @@@ + 1; let y = 2;", []string{"<bad statement>", "let y = 2;"}},
+		{"let f = fn() { let = 5; x }; let z = 1;", []string{"<bad statement>", "let z = 1;"}},
+		{"let x = {1 2; 3}; x", []string{"<bad statement>", "x x;"}},
+		{"when (x) { 1 } otherwise { let 5 }\nlet q = 1", []string{"<bad statement>", "let q = 1;"}},
+		{"}\nlet a = 1;", []string{"<bad statement>", "let a = 1;"}},
+		{"let a = ; let b = ; let c = 3;", []string{"<bad statement>", "<bad statement>", "let c = 3;"}},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.NewLexer(tt.input)).ParseProgram()
+		if len(program.Statements) != len(tt.expected) {
+			t.Errorf("input %q: want %d statements, got %d: %v", tt.input, len(tt.expected), len(program.Statements), program.Errors)
+			continue
+		}
+		for i, stmt := range program.Statements {
+			if got := stmt.ToString(); got != tt.expected[i] {
+				t.Errorf("input %q: statement %d want %q, got %q", tt.input, i, tt.expected[i], got)
+			}
+			_, bad := stmt.(*ast.BadStatement)
+			if bad != (program.Errors[i] != nil) {
+				t.Errorf("input %q: statement %d bad=%v but error=%v", tt.input, i, bad, program.Errors[i])
+			}
+		}
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = @@@;", `parser error at 1:9: illegal "@@@", unexpected characters`},
+		{"let 5 = 1;", `parser error at 1:5: AFTER "let" WANT IDENT, HAVE INT`},
+		{"let x = 1;\n  )", "parser error at 2:3: unknown prefix type RPAREN"},
+		{`"abc`, `parser error at 1:1: illegal "abc", unterminated string`},
+		{"try { 1 } otherwise { 2 }", `parser error at 1:11: AFTER "}" WANT CATCH, HAVE OTHERWISE`},
+		{"try { 1 } catch (5) { 2 }", `parser error at 1:18: AFTER "(" WANT IDENT, HAVE INT`},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.NewLexer(tt.input)).ParseProgram()
+		var got error
+		for _, err := range program.Errors {
+			if err != nil {
+				got = err
+				break
+			}
+		}
+		if got == nil || got.Error() != tt.expected {
+			t.Errorf("input %q: want error %q, got %v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFunctionLiteralName(t *testing.T) {
+	program := New(lexer.NewLexer("let add = fn(a, b) { a + b }; let f = add; fn() {}")).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	if name := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral).Name; name != "add" {
+		t.Errorf("want name add, got %q", name)
+	}
+	anonymous := program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if anonymous.Name != "" {
+		t.Errorf("anonymous function got name %q", anonymous.Name)
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5;", "let x: int = 5;"},
+		{"let x = 5;", "let x = 5;"},
+		{"const xs: [str] = [];", "const xs: [str] = [];"},
+		{`let h: {str: [int]} = {};`, "let h: {str: [int]} = {};"},
+		{"let f: fn(int, str) -> bool = g;", "let f: fn(int, str) -> bool = g;"},
+		{"let f: fn() = g;", "let f: fn() = g;"},
+		{"let add = fn(a: int, b) -> int { a + b };", "let add = fn(a: int, b) -> int { a (a + b); };"},
+		{"let f = fn() -> fn(int) -> int { g };", "let f = fn() -> fn(int) -> int { g g; };"},
+	}
+
+	for _, tt := range tests {
+		program := New(lexer.NewLexer(tt.input)).ParseProgram()
+		if err := program.Errors[0]; err != nil {
+			t.Errorf("input %q: unexpected error %v", tt.input, err)
+			continue
+		}
+		if got := program.Statements[0].ToString(); got != tt.expected {
+			t.Errorf("input %q: want %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+
+	program := New(lexer.NewLexer("fn(a: int, b) {}")).ParseProgram()
+	fl := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(fl.ParamTypes) != 2 || fl.ParamTypes[0].Name != "int" || fl.ParamTypes[1] != nil || fl.ReturnType != nil {
+		t.Errorf("wrong annotations: %+v, %+v", fl.ParamTypes, fl.ReturnType)
+	}
+
+	program = New(lexer.NewLexer("let x: 5 = 1;")).ParseProgram()
+	if err := program.Errors[0]; err == nil || err.Error() != "parser error at 1:8: want a type, have INT" {
+		t.Errorf("wrong error: %v", err)
+	}
+}
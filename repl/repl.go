@@ -4,18 +4,33 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/evaluator"
 	"monkey/lexer"
-	"monkey/token"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"strings"
 )
 
+// lines starting with this may redefine what earlier lines declared, constants included
+const overridePrefix = ":override "
+
 func Start(in io.Reader, out io.Writer) {
+	StartWith(in, out, evaluator.New(out))
+}
+
+// StartWith runs the session on eval, so the caller decides its capabilities and limits
+func StartWith(in io.Reader, out io.Writer, eval *evaluator.Evaluator) {
 	prompt_in := "monke<< "
 	prompt_out := "monke > "
 
+	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment() // bindings live as long as the session
+	res := resolver.New(eval.BuiltinNames()...)
 	for line := ""; ; {
 		// fmt.Println(line)
 		fmt.Printf("%v", prompt_in)
-		scanner := bufio.NewScanner(in)
 		if !scanner.Scan() {
 			return
 		}
@@ -24,10 +39,108 @@ func Start(in io.Reader, out io.Writer) {
 			fmt.Fprintf(out, "%v%v\n", prompt_out, "Goodbye !")
 			return
 		}
+		override := strings.HasPrefix(line, overridePrefix)
+		line = strings.TrimPrefix(line, overridePrefix)
+
 		lex := lexer.NewLexer(line)
+		p := parser.New(lex)
+
+		program := p.ParseProgram()
+		if printErrors(out, prompt_out, program.Errors) {
+			continue
+		}
+		declared := declarations(program)
+		if override {
+			for _, name := range declared {
+				res.Forget(name)
+			}
+		}
+		diags := res.Resolve(program)
+		for _, d := range diags {
+			fmt.Fprintf(out, "%v%v\n", prompt_out, d)
+		}
+		if resolver.HasErrors(diags) {
+			continue
+		}
+		var saved []binding
+		if override {
+			saved = forget(env, declared)
+		}
+
+		evaluated := eval.Eval(program, env)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			restore(env, saved)
+			fmt.Fprintf(out, "%v%v\n", prompt_out, errObj.Traceback())
+		} else if evaluated != evaluator.NULL || endsInValue(program) {
+			fmt.Fprintf(out, "%v%v\n", prompt_out, evaluated.Inspect())
+		}
+	}
+}
+
+// prints every non-nil error, returns whether there was any
+func printErrors(out io.Writer, prompt string, errors []error) bool {
+	found := false
+	for _, err := range errors {
+		if err != nil {
+			fmt.Fprintf(out, "%v%v\n", prompt, err)
+			found = true
+		}
+	}
+	return found
+}
+
+// whether the last statement of program has a value worth echoing, a line ending in a let or a loop is null
+// without anybody asking for it
+func endsInValue(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+	switch program.Statements[len(program.Statements)-1].(type) {
+	case *ast.ExpressionStatement, *ast.SendStatement:
+		return true
+	}
+	return false
+}
+
+// names the top level let/const statements of program declare, the ones :override lets it redefine
+func declarations(program *ast.Program) []string {
+	var names []string
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			names = append(names, let.Name.Value)
+		}
+	}
+	return names
+}
+
+// binding what a name was bound to before :override deleted it
+type binding struct {
+	name     string
+	value    object.Object
+	constant bool
+}
+
+// deletes names from env, returning what they were bound to
+func forget(env *object.Environment, names []string) []binding {
+	var saved []binding
+	for _, name := range names {
+		if value, ok := env.Get(name); ok && env.Owns(name) {
+			saved = append(saved, binding{name, value, env.IsConstant(name)})
+		}
+		env.Delete(name)
+	}
+	return saved
+}
 
-		for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
-			fmt.Fprintf(out, "%v %+v\n", prompt_out, tok)
+// puts back the bindings a failed :override line did not get to declare again
+func restore(env *object.Environment, saved []binding) {
+	for _, b := range saved {
+		switch {
+		case env.Owns(b.name):
+		case b.constant:
+			env.SetConstant(b.name, b.value)
+		default:
+			env.Set(b.name, b.value)
 		}
 	}
 }
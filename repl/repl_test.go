@@ -0,0 +1,69 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/evaluator"
+	"strings"
+	"testing"
+)
+
+// runs a session reading lines, what it answered, one line per answer without the prompt
+func session(t *testing.T, lines ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	StartWith(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out, evaluator.New(&out))
+	var answers []string
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		answers = append(answers, strings.TrimPrefix(line, "monke > "))
+	}
+	return answers
+}
+
+func TestOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{
+			"redeclaring a constant",
+			[]string{"const x = 1", "let x = 2", "x"},
+			[]string{"resolver error at 1:5: cannot redeclare constant: x", "1"},
+		},
+		{
+			"overriding a constant",
+			[]string{"const x = 1", ":override let x = 2", "x = x + 1", "x"},
+			[]string{"3"},
+		},
+		{
+			"overriding with a constant",
+			[]string{"let x = 1", ":override const x = 2", "x = 3", "x"},
+			[]string{"resolver error at 1:1: cannot assign to constant: x", "2"},
+		},
+		{
+			"failing to resolve",
+			[]string{"const x = 1", ":override let x = 2; const c = 1; c = 2", "x = 3", "x"},
+			[]string{
+				"resolver error at 1:25: cannot assign to constant: c",
+				"resolver error at 1:1: cannot assign to constant: x",
+				"1",
+			},
+		},
+		{
+			"failing at runtime",
+			[]string{"const x = 1", ":override let x = y", "x", "x = 2"},
+			[]string{
+				"runtime error at 1:9: identifier not found: y",
+				"1",
+				"runtime error at 1:3: cannot assign to constant: x",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		have := session(t, tt.lines...)
+		if strings.Join(have, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s:\nwant %q\nhave %q", tt.name, tt.expected, have)
+		}
+	}
+}
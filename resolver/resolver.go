@@ -0,0 +1,343 @@
+package resolver
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+	"sort"
+)
+
+// Severity how bad a diagnostic is, only errors keep a program from running
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic something found without running the program, Pos is the identifier it is about
+type Diagnostic struct {
+	Pos      token.Position
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("resolver %v at %v: %v", d.Severity, d.Pos, d.Message)
+}
+
+// HasErrors whether any of diags is an error rather than a warning
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// binding one declared name, slot is its index among the declarations of its scope
+type binding struct {
+	slot     int
+	constant bool
+	used     bool
+	name     *ast.Identifier // where it was declared
+	local    bool            // declared with let/const inside a function or loop, the ones unused warnings are about
+}
+
+// scope mirrors the environments the evaluator creates: the program, every function call, every loop iteration
+// and every catch handler
+// when and try blocks do not get one, they share the scope around them same as at runtime
+type scope struct {
+	bindings map[string]*binding
+	slots    int
+	function bool // a function body or the program, names used before being declared only matter up to here
+	outer    *scope
+
+	// names used while undeclared, reported if the scope declares them afterwards
+	pending map[string]token.Position
+	// references that resolved past this scope, a later declaration here would shadow them at runtime
+	crossed map[string][]*ast.Identifier
+}
+
+func newScope(outer *scope, function bool) *scope {
+	return &scope{
+		bindings: make(map[string]*binding),
+		function: function,
+		outer:    outer,
+		pending:  make(map[string]token.Position),
+		crossed:  make(map[string][]*ast.Identifier),
+	}
+}
+
+// lookup finds the binding name resolves to and how many scopes up it is
+func (s *scope) lookup(name string) (*binding, int) {
+	for depth := 0; s != nil; s, depth = s.outer, depth+1 {
+		if b, ok := s.bindings[name]; ok {
+			return b, depth
+		}
+	}
+	return nil, -1
+}
+
+// Resolver walks programs before they run: it checks constants, reports names used before their definition,
+// duplicate parameters and unused locals, and annotates every identifier with where its binding lives
+// it keeps the top level scope between calls, so the lines of a REPL session can be resolved one by one
+type Resolver struct {
+	root     *scope
+	universe map[string]bool // names that exist without a declaration, like builtins
+	scope    *scope
+	diags    []Diagnostic
+
+	defs map[*ast.Identifier]*ast.Identifier
+	late []lateReference
+
+	forgotten []string // names the next program may declare afresh, see Forget
+}
+
+// lateReference an identifier nothing was declared for yet when it was resolved, at runtime it is looked up by name
+type lateReference struct {
+	id    *ast.Identifier
+	scope *scope
+}
+
+// New resolver, the names exist without being declared, like builtins do
+func New(predeclared ...string) *Resolver {
+	r := &Resolver{root: newScope(nil, true), universe: make(map[string]bool)}
+	for _, name := range predeclared {
+		r.Declare(name)
+	}
+	return r
+}
+
+// Declare makes name known without a let, for bindings the host sets and builtins registered later
+func (r *Resolver) Declare(name string) {
+	r.universe[name] = true
+}
+
+// Forget drops what earlier programs declared at the top level as name before the next program is resolved,
+// the REPL's :override uses it; when that program has errors the binding is kept after all
+func (r *Resolver) Forget(name string) {
+	r.forgotten = append(r.forgotten, name)
+}
+
+// Resolve resolves one program in the top level scope left by the previous ones
+// a program with errors leaves that scope as it found it, since it will not run
+func Resolve(program *ast.Program) []Diagnostic {
+	return New().Resolve(program)
+}
+
+func (r *Resolver) Resolve(program *ast.Program) []Diagnostic {
+	saved := make(map[string]*binding, len(r.root.bindings))
+	for name, b := range r.root.bindings {
+		copied := *b
+		saved[name] = &copied
+	}
+	savedSlots := r.root.slots
+	for _, name := range r.forgotten {
+		delete(r.root.bindings, name)
+	}
+	r.forgotten = nil
+
+	r.scope, r.diags = r.root, nil
+	r.defs, r.late = make(map[*ast.Identifier]*ast.Identifier), nil
+	for _, stmt := range program.Statements {
+		r.resolve(stmt)
+	}
+	// by the time a function runs, the names it uses late are usually declared
+	for _, ref := range r.late {
+		if b, _ := ref.scope.lookup(ref.id.Value); b != nil {
+			r.defs[ref.id] = b.name
+		}
+	}
+	// names a program uses before declaring them may be declared by the host or an earlier program, not a later one
+	r.root.pending = make(map[string]token.Position)
+
+	diags := r.diags
+	sort.SliceStable(diags, func(i, j int) bool { return diags[i].Pos.Offset < diags[j].Pos.Offset })
+	if HasErrors(diags) {
+		r.root.bindings, r.root.slots = saved, savedSlots
+	}
+	return diags
+}
+
+// Definitions maps the identifiers of the last program resolved to the identifier declaring their binding,
+// declarations map to themselves
+// a name used before it is declared maps to the declaration its scopes have once the whole program is resolved,
+// which is what a function using it finds when it is called afterwards; builtins and undeclared names are left out
+func (r *Resolver) Definitions() map[*ast.Identifier]*ast.Identifier {
+	return r.defs
+}
+
+func (r *Resolver) report(pos token.Position, severity Severity, format string, a ...interface{}) {
+	r.diags = append(r.diags, Diagnostic{Pos: pos, Severity: severity, Message: fmt.Sprintf(format, a...)})
+}
+
+// declares name in the current scope, local says whether an unused warning applies
+func (r *Resolver) declare(name *ast.Identifier, constant, local bool) {
+	s := r.scope
+	if pos, ok := s.pending[name.Value]; ok {
+		r.report(pos, Error, "used before definition: %s", name.Value)
+		delete(s.pending, name.Value)
+	}
+	// at runtime these now find the new binding instead of the outer one they were resolved to,
+	// they go back to looking their name up by walking the scopes
+	for _, id := range s.crossed[name.Value] {
+		id.Resolved = false
+		r.defs[id] = name
+	}
+	delete(s.crossed, name.Value)
+
+	b, ok := s.bindings[name.Value]
+	if !ok {
+		b = &binding{slot: s.slots}
+		s.slots++
+		s.bindings[name.Value] = b
+	}
+	b.constant, b.used, b.name, b.local = constant, false, name, local
+	name.Resolved, name.Depth, name.Slot = true, 0, b.slot
+	r.defs[name] = name
+}
+
+// resolves id where it is not declared, use says whether it reads the binding (assigning does not)
+// returns the binding, nil when there is none yet
+func (r *Resolver) reference(id *ast.Identifier, use bool) *binding {
+	b, depth := r.scope.lookup(id.Value)
+	if b == nil {
+		id.Resolved = false
+		r.late = append(r.late, lateReference{id: id, scope: r.scope})
+		if r.universe[id.Value] {
+			return nil
+		}
+		for s := r.scope; s != nil; s = s.outer {
+			if _, ok := s.pending[id.Value]; !ok {
+				s.pending[id.Value] = id.Token.Pos
+			}
+			if s.function {
+				break
+			}
+		}
+		return nil
+	}
+
+	b.used = b.used || use
+	id.Resolved, id.Depth, id.Slot = true, depth, b.slot
+	r.defs[id] = b.name
+	for s, i := r.scope, 0; i < depth; s, i = s.outer, i+1 {
+		s.crossed[id.Value] = append(s.crossed[id.Value], id)
+	}
+	return b
+}
+
+func (r *Resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		r.reference(node, true)
+	case *ast.LetStatement:
+		r.resolve(node.Value)
+		name := node.Name.Value
+		if b, ok := r.scope.bindings[name]; ok && b.constant {
+			r.report(node.Name.Token.Pos, Error, "cannot redeclare constant: %s", name)
+			return
+		}
+		r.declare(node.Name, node.IsConstant(), r.scope != r.root)
+	case *ast.AssignStatement:
+		if id, ok := node.Target.(*ast.Identifier); ok {
+			// assigning is not using, so the binding is not marked
+			if b := r.reference(id, false); b != nil && b.constant {
+				r.report(id.Token.Pos, Error, "cannot assign to constant: %s", id.Value)
+			}
+		} else {
+			r.resolve(node.Target)
+		}
+		r.resolve(node.Value)
+	case *ast.SendStatement:
+		r.resolve(node.Value)
+	case *ast.ThrowStatement:
+		r.resolve(node.Value)
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			r.resolve(stmt)
+		}
+	case *ast.WhileStatement:
+		r.resolve(node.Condition)
+		r.inScope(false, func() { r.resolve(node.Body) })
+	case *ast.ForStatement:
+		r.resolve(node.Iterable)
+		r.inScope(false, func() {
+			r.declare(node.Variable, false, false)
+			r.resolve(node.Body)
+		})
+	case *ast.GroupedExpression:
+		r.resolve(node.Expression)
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+	case *ast.WhenExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		if node.Alternative != nil {
+			r.resolve(node.Alternative)
+		}
+	case *ast.TryExpression:
+		r.resolve(node.Block)
+		r.inScope(false, func() {
+			if node.Param != nil {
+				r.declare(node.Param, false, false)
+			}
+			r.resolve(node.Handler)
+		})
+	case *ast.FunctionLiteral:
+		r.inScope(true, func() {
+			for _, param := range node.Parameters {
+				if _, ok := r.scope.bindings[param.Value]; ok {
+					r.report(param.Token.Pos, Error, "duplicate parameter: %s", param.Value)
+					continue
+				}
+				r.declare(param, false, false)
+			}
+			r.resolve(node.Body)
+		})
+	case *ast.CallExpression:
+		r.resolve(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.resolve(el)
+		}
+	case *ast.HashLiteral:
+		for i, key := range node.Keys {
+			r.resolve(key)
+			r.resolve(node.Values[i])
+		}
+	case *ast.IndexExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Index)
+	}
+}
+
+// runs fn with a fresh scope on top of the current one, then reports the locals it never used
+func (r *Resolver) inScope(function bool, fn func()) {
+	r.scope = newScope(r.scope, function)
+	defer func() { r.scope = r.scope.outer }()
+	fn()
+
+	for name, b := range r.scope.bindings {
+		if b.local && !b.used {
+			r.report(b.name.Token.Pos, Warning, "declared and not used: %s", name)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package resolver
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"const x = 1; x", nil},
+		{"const x = 1; x = 2;", []string{"resolver error at 1:14: cannot assign to constant: x"}},
+		{"const x = 1; x += 2;", []string{"resolver error at 1:14: cannot assign to constant: x"}},
+		{"const x = 1; let x = 2;", []string{"resolver error at 1:18: cannot redeclare constant: x"}},
+		{"const x = 1; const x = 2;", []string{"resolver error at 1:20: cannot redeclare constant: x"}},
+		{"let x = 1; const x = 2; x = 3", []string{"resolver error at 1:25: cannot assign to constant: x"}},
+		{"const x = 1; when (yes) { x = 2 }", []string{"resolver error at 1:27: cannot assign to constant: x"}},
+		{"const x = 1; let f = fn() { x = 2 };", []string{"resolver error at 1:29: cannot assign to constant: x"}},
+		{"const x = [1]; x[0] = 2;", nil},
+		// shadowing in a new scope is fine
+		{"const x = 1; let f = fn(x) { x = 2 };", nil},
+		{"const x = 1; let f = fn() { let x = 2; x = 3 };", nil},
+		{"const x = 1; for (x in [1, 2]) { x = 3 }", nil},
+		{"const x = 1; while (yes) { const x = 2; break }", nil},
+		{"while (yes) { const y = 2; y = 3; break }", []string{"resolver error at 1:28: cannot assign to constant: y"}},
+		{"const e = 1; try { 1 } catch (e) { e = 2 }", nil},
+		{"const x = 1; try { x = 2 } catch { 0 }", []string{"resolver error at 1:20: cannot assign to constant: x"}},
+		{"try { 1 } catch { const x = 2; x = 3 }", []string{"resolver error at 1:32: cannot assign to constant: x"}},
+		// unknown names are left for the evaluator
+		{"y = 2", nil},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.NewLexer(tt.input)).ParseProgram()
+		for _, err := range program.Errors {
+			if err != nil {
+				t.Fatalf("input %q: parser error %v", tt.input, err)
+			}
+		}
+
+		var errors []Diagnostic // the warnings are not what this test is about
+		for _, d := range Resolve(program) {
+			if d.Severity == Error {
+				errors = append(errors, d)
+			}
+		}
+		if len(errors) != len(tt.expected) {
+			t.Errorf("input %q: want %d errors, got %v", tt.input, len(tt.expected), errors)
+			continue
+		}
+		for i, err := range errors {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("input %q: want %q, got %q", tt.input, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	return program
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		{"x; let x = 1;", []string{"resolver error at 1:1: used before definition: x"}},
+		{"let x = x + 1;", []string{"resolver error at 1:9: used before definition: x"}},
+		{"while (yes) { print(y); let y = 1; y; break }", []string{"resolver error at 1:21: used before definition: y"}},
+		// function bodies run later, by then the name is there
+		{"let f = fn() { g() }; let g = fn() { 1 }; f()", nil},
+		{"let f = fn(n) { f(n - 1) };", nil},
+		// names that are never declared are left for the evaluator, they may be builtins or come from the host
+		{"len([]); nothing", nil},
+		{"let add = fn(a, b, a) { a };", []string{"resolver error at 1:20: duplicate parameter: a"}},
+		{"let f = fn() { let unused = 1; 2 };", []string{"resolver warning at 1:20: declared and not used: unused"}},
+		{"let f = fn() { let x = 1; x = 2 };", []string{"resolver warning at 1:20: declared and not used: x"}},
+		{"for (x in [1]) { let y = x; }", []string{"resolver warning at 1:22: declared and not used: y"}},
+		// parameters, loop variables and the top level are not reported
+		{"let f = fn(a) { 1 }; for (x in [1]) { 1 }; let top = 1;", nil},
+		{"try { 1 } catch (e) { let msg = e; msg }", nil},
+	}
+
+	for _, tt := range tests {
+		diags := Resolve(parse(t, tt.input))
+		if len(diags) != len(tt.expected) {
+			t.Errorf("input %q: want %d diagnostics, got %v", tt.input, len(tt.expected), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Error() != tt.expected[i] {
+				t.Errorf("input %q: want %q, got %q", tt.input, tt.expected[i], d.Error())
+			}
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	program := parse(t, "let a = 1; let b = 2; let f = fn(x) { while (yes) { let y = x + b; y; break } }; len(a)")
+	Resolve(program)
+
+	want := map[string][3]int{ // Resolved as 0/1, Depth, Slot
+		"a":   {1, 0, 0},
+		"b":   {1, 2, 1},
+		"x":   {1, 1, 0},
+		"y":   {1, 0, 0},
+		"len": {0, 0, 0},
+	}
+	got := map[string][3]int{}
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Program:
+			for _, stmt := range node.Statements {
+				visit(stmt)
+			}
+		case *ast.LetStatement:
+			visit(node.Value)
+		case *ast.ExpressionStatement:
+			visit(node.Expression)
+		case *ast.FunctionLiteral:
+			visit(node.Body)
+		case *ast.WhileStatement:
+			visit(node.Body)
+		case *ast.BlockStatement:
+			for _, stmt := range node.Statements {
+				visit(stmt)
+			}
+		case *ast.InfixExpression:
+			visit(node.Left)
+			visit(node.Right)
+		case *ast.CallExpression:
+			visit(node.Function)
+			for _, arg := range node.Arguments {
+				visit(arg)
+			}
+		case *ast.Identifier:
+			resolved := 0
+			if node.Resolved {
+				resolved = 1
+			}
+			got[node.Value] = [3]int{resolved, node.Depth, node.Slot}
+		}
+	}
+	visit(program)
+
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("%s: want resolved/depth/slot %v, got %v", name, w, got[name])
+		}
+	}
+}
+
+// slots count the declarations of a scope, declaring a name again keeps its slot
+func TestSlots(t *testing.T) {
+	program := parse(t, "let a = 1; let b = 2; let a = 3; let f = fn(x, y, z) { let w = z; let x = w; x + a }; f(b)")
+	Resolve(program)
+
+	var got []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Identifier); ok && id.Resolved {
+			got = append(got, fmt.Sprintf("%s:%d:%d", id.Value, id.Depth, id.Slot))
+		}
+		return true
+	})
+	want := []string{
+		"a:0:0", "b:0:1", "a:0:0", "f:0:2",
+		"x:0:0", "y:0:1", "z:0:2", "w:0:3", "z:0:2", "x:0:0", "w:0:3", "x:0:0", "a:1:0",
+		"f:0:2", "b:0:1",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("want %v\nhave %v", want, got)
+	}
+}
+
+// a persistent resolver is how the REPL resolves one line at a time
+func TestResolverKeepsTopLevel(t *testing.T) {
+	r := New("len")
+
+	steps := []struct {
+		input    string
+		expected []string
+	}{
+		{"const c = 1; let x = 2;", nil},
+		{"let x = x + 1;", nil}, // x is the one from the line before
+		{"c = 2", []string{"resolver error at 1:1: cannot assign to constant: c"}},
+		{"let z = len(z); const d = 1;", []string{"resolver error at 1:13: used before definition: z"}},
+		{"let d = 2;", nil}, // the failed line did not declare d
+	}
+	for _, step := range steps {
+		diags := r.Resolve(parse(t, step.input))
+		if len(diags) != len(step.expected) {
+			t.Errorf("input %q: want %d diagnostics, got %v", step.input, len(step.expected), diags)
+			continue
+		}
+		for i, d := range diags {
+			if d.Error() != step.expected[i] {
+				t.Errorf("input %q: want %q, got %q", step.input, step.expected[i], d.Error())
+			}
+		}
+	}
+}
+
+// what :override does in the REPL, a line failing to resolve keeps what it would have redeclared
+func TestForget(t *testing.T) {
+	r := New()
+	steps := []struct {
+		forget   string
+		input    string
+		expected []string
+	}{
+		{"", "const c = 1; const e = 1;", nil},
+		{"e", "let e = 2; c = 3;", []string{"resolver error at 1:12: cannot assign to constant: c"}},
+		{"", "e = 3", []string{"resolver error at 1:1: cannot assign to constant: e"}},
+		{"e", "let e = 4;", nil},
+		{"", "e = 5", nil},
+	}
+	for _, step := range steps {
+		if step.forget != "" {
+			r.Forget(step.forget)
+		}
+		var have []string
+		for _, d := range r.Resolve(parse(t, step.input)) {
+			have = append(have, d.Error())
+		}
+		if strings.Join(have, "\n") != strings.Join(step.expected, "\n") {
+			t.Errorf("input %q: want %q, got %q", step.input, step.expected, have)
+		}
+	}
+}
+
+// identifiers the resolver annotated have to find the same values as a walk through the scopes does
+func TestAnnotationsMatchRuntime(t *testing.T) {
+	inputs := []string{
+		"let x = 1; let f = fn(y) { x + y }; f(2)",
+		"let y = 0; let n = 0; let r = []; while (n < 2) { let g = fn() { y }; r = push(r, g()); let y = 5; r = push(r, g()); n += 1 }; r",
+		"let x = 1; let f = fn() { when (no) { let x = 2 }; x }; f()",
+		"let s = 0; for (i in [1, 2, 3]) { let s = s + i; s }; s",
+		"let e = 1; try { throw 2 } catch (e) { e[\"value\"] + 1 }",
+		"let make = fn(n) { fn() { n } }; let a = make(1); let b = make(2); a() + b()",
+	}
+
+	for _, input := range inputs {
+		plain := evaluator.Eval(parse(t, input), object.NewEnvironment())
+		program := parse(t, input)
+		Resolve(program)
+		resolved := evaluator.Eval(program, object.NewEnvironment())
+		if plain.Inspect() != resolved.Inspect() {
+			t.Errorf("input %q: without resolving %s, resolved %s", input, plain.Inspect(), resolved.Inspect())
+		}
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // every identifier as name@column, followed by the column of its definition
+	}{
+		{"let x = 1; x + x", "x@5:5 x@12:5 x@16:5"},
+		{"let x = 1; let f = fn(x) { x }; x", "x@5:5 f@16:16 x@23:23 x@28:23 x@33:5"},
+		{"let x = 1; x = 2; when (yes) { let x = 3 }; x", "x@5:5 x@12:5 x@36:36 x@45:36"},
+		{"for (i in [1]) { i }; try { 1 } catch (e) { e }", "i@6:6 i@18:6 e@40:40 e@45:40"},
+		// used before it is declared, found once the program is done
+		{"let f = fn() { g() }; let g = fn() { 1 }", "f@5:5 g@16:27 g@27:27"},
+		{"len(y)", "len@1:- y@5:-"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		r := New("len")
+		r.Resolve(program)
+		defs := r.Definitions()
+
+		var have []string
+		ast.Inspect(program, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Identifier); ok {
+				def := "-"
+				if d, ok := defs[id]; ok {
+					def = strconv.Itoa(d.Token.Pos.Column)
+				}
+				have = append(have, fmt.Sprintf("%s@%d:%s", id.Value, id.Token.Pos.Column, def))
+			}
+			return true
+		})
+		if got := strings.Join(have, " "); got != tt.expected {
+			t.Errorf("input %q:\nwant %s\nhave %s", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package scripttest runs tests written in monkey: files named *_test.mk whose top level lets bind functions named
+// test_something, which fail by failing, usually through assert, assert_eq or assert_error
+//
+// every test gets a fresh run of its file's top level before it is called, so what one test changes the next does
+// not see, and tests run in the order they are declared
+package scripttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Suffix what the names of test files end in
+const Suffix = "_test.mk"
+
+// Files the test files paths name: directories are searched for files ending in Suffix, files are taken as they are
+func Files(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(path, Suffix) {
+				found = append(found, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+	return files, nil
+}
+
+// Tests the names of the test functions in program, in the order they are declared
+func Tests(program *ast.Program) []string {
+	var names []string
+	for _, let := range tests(program) {
+		names = append(names, let.Name.Value)
+	}
+	return names
+}
+
+// the lets declaring the test functions in program
+func tests(program *ast.Program) []*ast.LetStatement {
+	var lets []*ast.LetStatement
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, "test_") {
+			continue
+		}
+		if _, ok := ast.Unparen(let.Value).(*ast.FunctionLiteral); ok {
+			lets = append(lets, let)
+		}
+	}
+	return lets
+}
+
+// Result what one test did, Err is nil when it passed
+type Result struct {
+	Name     string
+	Err      *object.Error
+	Duration time.Duration
+}
+
+// Runner runs test files the way monkey runs scripts, with the same builtins, capabilities and limits
+type Runner struct {
+	Out          io.Writer // where tests print
+	Capabilities object.Capability
+	Limits       evaluator.Limits // the zero value keeps the evaluator's defaults
+	VM           bool             // run on the vm instead of the evaluator
+	Timeout      time.Duration    // how long a single test may take, no limit when 0
+}
+
+// RunFile runs every test in the file at path
+// the errors are about the file as a whole: it does not parse, does not resolve, declares a test taking
+// parameters or its top level fails, then no test runs
+func (r *Runner) RunFile(path string) ([]Result, []error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+	program := parser.New(lexer.NewLexer(string(src))).ParseProgram()
+	var errs []error
+	for _, err := range program.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	for _, d := range resolver.New(r.evaluator().BuiltinNames()...).Resolve(program) {
+		if d.Severity == resolver.Error {
+			errs = append(errs, d)
+		}
+	}
+	// tests are called with no arguments, one wanting some could only fail
+	for _, let := range tests(program) {
+		if params := ast.Unparen(let.Value).(*ast.FunctionLiteral).Parameters; len(params) > 0 {
+			errs = append(errs, fmt.Errorf("%v: test function %s takes parameters, tests are called with none", let.Name.Pos(), let.Name.Value))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var bytecode *compiler.Bytecode
+	if r.VM {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return nil, []error{err}
+		}
+		bytecode = comp.Bytecode()
+	}
+
+	var results []Result
+	for _, name := range Tests(program) {
+		start := time.Now()
+		setup, err := r.run(program, bytecode, name)
+		if setup != nil {
+			return nil, []error{errors.New(setup.Traceback())}
+		}
+		results = append(results, Result{Name: name, Err: err, Duration: time.Since(start)})
+	}
+	return results, nil
+}
+
+func (r *Runner) evaluator() *evaluator.Evaluator {
+	eval := evaluator.New(r.Out)
+	if r.Out == nil {
+		eval.Out = io.Discard
+	}
+	eval.Capabilities = r.Capabilities
+	if r.Limits != (evaluator.Limits{}) {
+		eval.Limits = r.Limits
+	}
+	return eval
+}
+
+// runs the top level of program, then the test called name
+// setup is the error of the top level, err the one of the test
+func (r *Runner) run(program *ast.Program, bytecode *compiler.Bytecode, name string) (setup, err *object.Error) {
+	ctx := context.Background()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	eval := r.evaluator()
+
+	var fn object.Object
+	var ok bool
+	var call func() object.Object
+	if bytecode != nil {
+		machine := vm.New(bytecode, eval)
+		if err, ok := machine.Run(ctx).(*object.Error); ok {
+			return err, nil
+		}
+		fn, ok = machine.Global(name)
+		call = func() object.Object { return machine.Call(ctx, fn, nil) }
+	} else {
+		env := object.NewEnvironment()
+		if err, ok := eval.EvalContext(ctx, program, env).(*object.Error); ok {
+			return err, nil
+		}
+		fn, ok = env.Get(name)
+		call = func() object.Object { return eval.ApplyContext(ctx, fn, nil) }
+	}
+	// a send at the top level ends it before the rest is declared
+	if !ok {
+		return nil, &object.Error{Message: "not declared once the top level has run: " + name}
+	}
+	err, _ = call().(*object.Error)
+	return nil, err
+}
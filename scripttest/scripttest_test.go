@@ -0,0 +1,161 @@
+package scripttest
+
+import (
+	"bytes"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writes files, named by their path under a new directory, and returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFiles(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"b_test.mk":       "",
+		"a_test.mk":       "",
+		"lib.mk":          "",
+		"sub/c_test.mk":   "",
+		"sub/c_test.mk.x": "",
+	})
+	have, err := Files(dir, filepath.Join(dir, "lib.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a_test.mk", "b_test.mk", "sub/c_test.mk", "lib.mk"}
+	for i := range want {
+		want[i] = filepath.Join(dir, want[i])
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("want %v\nhave %v", want, have)
+	}
+
+	if _, err := Files(filepath.Join(dir, "missing")); err == nil {
+		t.Error("want an error for a path that does not exist")
+	}
+}
+
+const script = `let total = 0;
+let add = fn(n) { total += n; total };
+let check = fn(n) { assert_eq(n, 2, "check") };
+
+let test_add = fn() {
+	assert_eq(add(2), 2);
+	print("added");
+};
+// total starts over for every test
+let test_isolated = fn() {
+	assert_eq(add(3), 3)
+};
+let test_fails = fn() {
+	assert(yes);
+	assert(total > 0, "total is positive");
+};
+let test_helper = fn() {
+	check(add(1));
+	assert(yes)
+};
+let test_errors = fn() {
+	assert_error(fn() { add("a") }, "type mismatch")
+};
+let not_a_test = fn() { assert(no) };
+let test_value = 5;
+`
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(writeFiles(t, map[string]string{"math_test.mk": script}), "math_test.mk")
+	for _, useVM := range []bool{false, true} {
+		var out bytes.Buffer
+		r := &Runner{Out: &out, Capabilities: object.CapPrint, VM: useVM}
+		results, errs := r.RunFile(path)
+		if errs != nil {
+			t.Fatalf("vm %v: %v", useVM, errs)
+		}
+
+		var have []string
+		for _, result := range results {
+			line := result.Name + ": ok"
+			if result.Err != nil {
+				line = result.Name + ": " + result.Err.Traceback()
+			}
+			have = append(have, line)
+		}
+		want := []string{
+			"test_add: ok",
+			"test_isolated: ok",
+			"test_fails: runtime error at 15:8: assertion failed: total is positive\n  at 15:8 in test_fails",
+			"test_helper: runtime error at 3:30: assert_eq failed: check: have 1, want 2\n  at 3:30 in check\n  at 18:7 in test_helper",
+			"test_errors: ok",
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("vm %v:\nwant %q\nhave %q", useVM, want, have)
+		}
+		if out.String() != "added\n" {
+			t.Errorf("vm %v: printed %q", useVM, out.String())
+		}
+	}
+}
+
+func TestRunFileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"let test_a = fn() { 1 ", "parser error"},
+		{"const x = 1; x = 2; let test_a = fn() { 1 };", "cannot assign to constant: x"},
+		{"let test_a = fn() { 1 }; 1 + no;", "runtime error at 1:28: type mismatch: INTEGER + BOOLEAN"},
+		{"let test_a = fn() { 1 };\nlet test_args = fn(x) { x };", "2:5: test function test_args takes parameters, tests are called with none"},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(writeFiles(t, map[string]string{"x_test.mk": tt.src}), "x_test.mk")
+		results, errs := (&Runner{}).RunFile(path)
+		if results != nil || len(errs) == 0 || !strings.Contains(errs[0].Error(), tt.err) {
+			t.Errorf("input %q: want an error about %q, have %v %v", tt.src, tt.err, results, errs)
+		}
+	}
+}
+
+func TestRunFileLimits(t *testing.T) {
+	src := `send 1;
+let test_late = fn() { 1 };
+`
+	early := filepath.Join(writeFiles(t, map[string]string{"x_test.mk": src}), "x_test.mk")
+	loop := filepath.Join(writeFiles(t, map[string]string{"x_test.mk": "let test_loop = fn() { while (yes) { 1 } };"}), "x_test.mk")
+	deep := filepath.Join(writeFiles(t, map[string]string{"x_test.mk": "let f = fn() { 1 + f() }; let test_deep = fn() { f() };"}), "x_test.mk")
+
+	for _, useVM := range []bool{false, true} {
+		r := &Runner{VM: useVM, Timeout: 500 * time.Millisecond}
+		tests := []struct {
+			path string
+			err  string
+		}{
+			{early, "not declared once the top level has run: test_late"},
+			{loop, "limit exceeded: context deadline exceeded"},
+			{deep, "limit exceeded: calls nested more than"},
+		}
+		for _, tt := range tests {
+			results, errs := r.RunFile(tt.path)
+			if len(errs) > 0 || len(results) != 1 || results[0].Err == nil || !strings.Contains(results[0].Err.Message, tt.err) {
+				t.Errorf("vm %v, %s: want a failure about %q, have %+v %v", useVM, tt.path, tt.err, results, errs)
+			}
+		}
+	}
+}
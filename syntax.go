@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/token"
+	"os"
+)
+
+func init() {
+	commands["tokens"] = runTokens
+	commands["ast"] = runAST
+}
+
+// monkey tokens [--json] file, prints what the lexer makes of file, one token per line or as a JSON array
+func runTokens(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	path, asJSON, ok := syntaxArgs("tokens", args, stderr)
+	if !ok {
+		return 2
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	// the EOF token is part of the stream too, it marks where the input ends
+	tokens := []token.Token{}
+	for lex := lexer.NewLexer(string(src)); ; {
+		tok := lex.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(tokens, "", "  ")
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintln(stdout, string(data))
+		return 0
+	}
+	for _, tok := range tokens {
+		fmt.Fprintf(stdout, "%v\t%s\t%q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+	return 0
+}
+
+// monkey ast [--json] file, prints the syntax tree of file, a statement per line or as JSON that ast.FromJSON reads back
+func runAST(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	path, asJSON, ok := syntaxArgs("ast", args, stderr)
+	if !ok {
+		return 2
+	}
+	program, ok := parseFile(path, stderr)
+	if !ok {
+		return 1
+	}
+
+	if asJSON {
+		data, err := ast.ToJSON(program)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintln(stdout, string(data))
+		return 0
+	}
+	for _, stmt := range program.Statements {
+		fmt.Fprintln(stdout, stmt.ToString())
+	}
+	return 0
+}
+
+// the flags tokens and ast share, false when they are not usable
+func syntaxArgs(name string, args []string, stderr io.Writer) (path string, asJSON bool, ok bool) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: monkey %s [--json] file\n", name)
+		flags.PrintDefaults()
+	}
+	jsonFlag := flags.Bool("json", false, "print JSON instead of text")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		if err == nil {
+			flags.Usage()
+		}
+		return "", false, false
+	}
+	return flags.Arg(0), *jsonFlag, true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"monkey/scripttest"
+	"strings"
+	"time"
+)
+
+func init() {
+	commands["test"] = runTest
+}
+
+// monkey test [flags] [path...], runs the test_ functions of the *_test.mk files under the paths, the working
+// directory when there are none; failures are reported with where they happened and make the exit code 1
+func runTest(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey test [flags] [path...]")
+		flags.PrintDefaults()
+	}
+	caps := capabilityFlags(flags)
+	engine := flags.String("engine", "eval", "what runs tests: eval walks the syntax tree, vm compiles it to bytecode first")
+	timeout := flags.Duration("timeout", 10*time.Second, "how long a single test may run, 0 for no limit")
+	verbose := flags.Bool("v", false, "list the tests that pass too")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Fprintf(stderr, "unknown engine %q, want eval or vm\n", *engine)
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := scripttest.Files(paths...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	r := &scripttest.Runner{Out: stdout, Capabilities: caps(), VM: *engine == "vm", Timeout: *timeout}
+	status, passed, failed := 0, 0, 0
+	for _, path := range files {
+		results, errs := r.RunFile(path)
+		for _, err := range errs {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+			status = 1
+		}
+		for _, result := range results {
+			if result.Err == nil {
+				passed++
+				if *verbose {
+					fmt.Fprintf(stdout, "--- PASS: %s (%s, %v)\n", result.Name, path, result.Duration.Round(time.Millisecond))
+				}
+				continue
+			}
+			failed++
+			status = 1
+			fmt.Fprintf(stdout, "--- FAIL: %s (%s, %v)\n", result.Name, path, result.Duration.Round(time.Millisecond))
+			// the position first, the way compilers print them, then the calls leading there
+			traceback := strings.Split(result.Err.Traceback(), "\n")
+			fmt.Fprintf(stdout, "    %s:%v: %s\n", path, result.Err.Pos, result.Err.Message)
+			for _, line := range traceback[1:] {
+				fmt.Fprintf(stdout, "    %s\n", line)
+			}
+		}
+	}
+
+	switch {
+	case failed > 0:
+		fmt.Fprintf(stdout, "FAIL: %d of %d tests failed\n", failed, passed+failed)
+	case status != 0:
+		fmt.Fprintln(stdout, "FAIL")
+	case passed == 0:
+		fmt.Fprintln(stdout, "no tests to run")
+	default:
+		fmt.Fprintf(stdout, "ok: %d tests passed\n", passed)
+	}
+	return status
+}
@@ -1,11 +1,27 @@
 package token
 
+import "fmt"
+
 // explicit type instead of directly using string as it offers a way to limit possibilities of tokentype, unless author explicitly typecasts
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Pos     Position  `json:"pos"`              // where the token starts in the source
+	End     Position  `json:"end"`              // right after its last character, a string's closing quote included
+	Reason  string    `json:"reason,omitempty"` // why the token is ILLEGAL, empty for every other type
+}
+
+// Position byte offset of a token in the source, plus the line and column it sits at, both counted from 1
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
@@ -14,8 +30,9 @@ const (
 	EOF     = "EOF"
 
 	// Identifiers
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	STRING = "STRING"
 
 	// Operators
 	ASSIGN     = "ASSIGN"
@@ -31,22 +48,51 @@ const (
 	EQ_OR_LESS = "EQ_OR_LESS"
 	EQ_OR_MORE = "EQ_OR_MORE"
 
+	// Arithmetic and bitwise operators
+	MODULO      = "MODULO"
+	POWER       = "POWER"
+	BIT_AND     = "BIT_AND"
+	BIT_OR      = "BIT_OR"
+	BIT_XOR     = "BIT_XOR"
+	BIT_NOT     = "BIT_NOT"
+	SHIFT_LEFT  = "SHIFT_LEFT"
+	SHIFT_RIGHT = "SHIFT_RIGHT"
+
+	// Compound assignment
+	PLUS_ASSIGN     = "PLUS_ASSIGN"
+	MINUS_ASSIGN    = "MINUS_ASSIGN"
+	MULTIPLY_ASSIGN = "MULTIPLY_ASSIGN"
+	DIVIDE_ASSIGN   = "DIVIDE_ASSIGN"
+
 	//Separators
 	COMMA     = "COMMA"
 	SEMICOLON = "SEMICOLON"
+	COLON     = "COLON"
+	ARROW     = "ARROW" // -> before the result type of a function
 
 	// Parenthesis
-	LPAREN = "LPAREN"
-	RPAREN = "RPAREN"
-	LBRACE = "LBRACE"
-	RBRACE = "RBRACE"
+	LPAREN   = "LPAREN"
+	RPAREN   = "RPAREN"
+	LBRACE   = "LBRACE"
+	RBRACE   = "RBRACE"
+	LBRACKET = "LBRACKET"
+	RBRACKET = "RBRACKET"
 
 	// Keywords
 	FUNCTION  = "FUNCTION"
 	LET       = "LET"
+	CONST     = "CONST"
 	WHEN      = "WHEN"
 	OTHERWISE = "OTHERWISE"
 	YES       = "YES"
 	NO        = "NO"
 	SEND      = "SEND"
+	WHILE     = "WHILE"
+	FOR       = "FOR"
+	IN        = "IN"
+	BREAK     = "BREAK"
+	CONTINUE  = "CONTINUE"
+	THROW     = "THROW"
+	TRY       = "TRY"
+	CATCH     = "CATCH"
 )
@@ -0,0 +1,77 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/evaluator"
+	"monkey/object"
+)
+
+// the operator each instruction stands for, the evaluator knows what they mean for every type
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+	code.OpMinus:        "-",
+	code.OpBang:         "!",
+	code.OpBitNot:       "~",
+}
+
+// binary applies the operator of op, integer arithmetic and comparisons that cannot fail are done right here
+// since they are what hot loops are made of, everything else is left to the evaluator
+func (vm *VM) binary(op code.Opcode, left, right object.Object) (object.Object, *object.Error) {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				return &object.Integer{Value: l.Value + r.Value}, nil
+			case code.OpSub:
+				return &object.Integer{Value: l.Value - r.Value}, nil
+			case code.OpMul:
+				return &object.Integer{Value: l.Value * r.Value}, nil
+			case code.OpEqual:
+				return nativeBool(l.Value == r.Value), nil
+			case code.OpNotEqual:
+				return nativeBool(l.Value != r.Value), nil
+			case code.OpLessThan:
+				return nativeBool(l.Value < r.Value), nil
+			case code.OpGreaterThan:
+				return nativeBool(l.Value > r.Value), nil
+			case code.OpLessEqual:
+				return nativeBool(l.Value <= r.Value), nil
+			case code.OpGreaterEqual:
+				return nativeBool(l.Value >= r.Value), nil
+			}
+		}
+	}
+
+	// + joins strings
+	if err := vm.eval.CheckInfix(operators[op], left, right); err != nil {
+		return nil, err
+	}
+	result := evaluator.Infix(operators[op], left, right)
+	if err, ok := result.(*object.Error); ok {
+		return nil, err
+	}
+	return result, nil
+}
+
+func nativeBool(b bool) *object.Boolean {
+	if b {
+		return evaluator.YES
+	}
+	return evaluator.NO
+}
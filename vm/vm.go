@@ -0,0 +1,514 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+)
+
+const (
+	// the stack grows past this when calls nest deeper
+	initialStackSize = 2048
+	// how many instructions go by between two looks at the context
+	contextCheckInterval = 1024
+)
+
+// Frame one call being run, the program itself is the bottom one
+type Frame struct {
+	cl    *object.Closure
+	ip    int // next instruction
+	start int // the instruction being run, errors point at it
+	bp    int // the stack slot of the first local
+}
+
+func (f *Frame) position() token.Position {
+	return f.cl.Fn.Positions[f.start]
+}
+
+// handler an open try block: where its handler starts and what the stack looked like when it was entered
+type handler struct {
+	frame int
+	sp    int
+	ip    int
+}
+
+// iterator what a for loop steps through, it sits on the stack for as long as the loop runs
+type iterator struct {
+	items []object.Object
+	next  int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// VM runs bytecode, builtins, capabilities and limits are the ones of the evaluator it is given
+// so a program can do the same on both engines
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+	eval        *evaluator.Evaluator
+
+	stack    []object.Object
+	sp       int // the next free slot, the top of the stack is stack[sp-1]
+	frames   []*Frame
+	handlers []handler
+
+	ctx   context.Context
+	steps int
+}
+
+func New(bytecode *compiler.Bytecode, eval *evaluator.Evaluator) *VM {
+	main := &Frame{cl: &object.Closure{Fn: bytecode.Main}}
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, len(bytecode.Globals)),
+		globalNames: bytecode.Globals,
+		eval:        eval,
+		stack:       make([]object.Object, initialStackSize),
+		sp:          bytecode.Main.NumLocals,
+		frames:      []*Frame{main},
+	}
+}
+
+// Run runs the program, it evaluates to its last statement like with the evaluator, or an *object.Error
+// the run stops with an error once ctx is done
+func (vm *VM) Run(ctx context.Context) object.Object {
+	vm.ctx = ctx
+	// builtins calling a closure, like assert_error, come back here to run it
+	foreign := vm.eval.Foreign
+	vm.eval.Foreign = func(fn object.Object, args []object.Object) object.Object { return vm.Call(ctx, fn, args) }
+	defer func() { vm.ctx, vm.eval.Foreign = nil, foreign }()
+
+	for {
+		result, err := vm.run()
+		if err == nil {
+			return result
+		}
+		if !vm.fail(err) {
+			return err
+		}
+	}
+}
+
+// Global the value of the top level binding name after Run, false when the program has none by that name
+func (vm *VM) Global(name string) (object.Object, bool) {
+	for i, global := range vm.globalNames {
+		if global == name && vm.globals[i] != nil {
+			return vm.globals[i], true
+		}
+	}
+	return nil, false
+}
+
+// Call calls fn (a closure or a builtin) with args to completion, on a stack of its own next to the one Run uses,
+// so it works after Run as well as from a builtin while Run is still going; the steps count against the same limit
+func (vm *VM) Call(ctx context.Context, fn object.Object, args []object.Object) object.Object {
+	// the caller is a function making the call and returning what it gives
+	caller := &object.CompiledFunction{Instructions: append(code.Make(code.OpCall, len(args)), code.Make(code.OpReturnValue)...)}
+	sub := &VM{
+		constants:   vm.constants,
+		globals:     vm.globals,
+		globalNames: vm.globalNames,
+		eval:        vm.eval,
+		stack:       make([]object.Object, initialStackSize),
+		frames:      []*Frame{{cl: &object.Closure{Fn: caller}}},
+		steps:       vm.steps,
+	}
+	sub.push(fn)
+	for _, arg := range args {
+		sub.push(arg)
+	}
+	result := sub.Run(ctx)
+	vm.steps = sub.steps
+	return result
+}
+
+// runs instructions until the program returns or one of them fails
+func (vm *VM) run() (object.Object, *object.Error) {
+	for {
+		if err := vm.step(); err != nil {
+			return nil, err
+		}
+
+		f := vm.frames[len(vm.frames)-1]
+		ins := f.cl.Fn.Instructions
+		f.start = f.ip
+		op := code.Opcode(ins[f.ip])
+		f.ip++
+
+		switch op {
+		case code.OpConstant:
+			vm.push(vm.constants[vm.operand16(f)])
+		case code.OpPop:
+			vm.sp--
+		case code.OpTrue:
+			vm.push(evaluator.YES)
+		case code.OpFalse:
+			vm.push(evaluator.NO)
+		case code.OpNull:
+			vm.push(evaluator.NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan, code.OpLessEqual, code.OpGreaterEqual:
+			right, left := vm.pop(), vm.pop()
+			result, err := vm.binary(op, left, right)
+			if err != nil {
+				return nil, err
+			}
+			vm.push(result)
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			result := evaluator.Prefix(operators[op], vm.pop())
+			if err, ok := result.(*object.Error); ok {
+				return nil, err
+			}
+			vm.push(result)
+
+		case code.OpJump:
+			f.ip = vm.operand16(f)
+		case code.OpJumpNotTruthy:
+			target := vm.operand16(f)
+			if !evaluator.IsTruthy(vm.pop()) {
+				f.ip = target
+			}
+
+		case code.OpGetGlobal:
+			i := vm.operand16(f)
+			val := vm.globals[i]
+			if val == nil {
+				builtin, ok := vm.eval.Builtin(vm.globalNames[i])
+				if !ok {
+					return nil, notFound(vm.globalNames[i])
+				}
+				val = builtin
+			}
+			vm.push(val)
+		case code.OpSetGlobal:
+			vm.globals[vm.operand16(f)] = vm.pop()
+		case code.OpAssignGlobal:
+			i, operator := vm.operand16(f), vm.operand8(f)
+			if err := vm.assign(&vm.globals[i], vm.globalNames[i], operator); err != nil {
+				return nil, err
+			}
+
+		case code.OpGetLocal:
+			i := vm.operand16(f)
+			val := vm.stack[f.bp+i]
+			if val == nil {
+				return nil, notFound(f.cl.Fn.Locals[i])
+			}
+			vm.push(val)
+		case code.OpSetLocal:
+			vm.stack[f.bp+vm.operand16(f)] = vm.pop()
+		case code.OpAssignLocal:
+			i, operator := vm.operand16(f), vm.operand8(f)
+			if err := vm.assign(&vm.stack[f.bp+i], f.cl.Fn.Locals[i], operator); err != nil {
+				return nil, err
+			}
+
+		case code.OpNewCell:
+			vm.stack[f.bp+vm.operand16(f)] = &object.Cell{}
+		case code.OpBoxParam:
+			i := f.bp + vm.operand16(f)
+			vm.stack[i] = &object.Cell{Value: vm.stack[i]}
+		case code.OpGetCell:
+			i := vm.operand16(f)
+			val := vm.stack[f.bp+i].(*object.Cell).Value
+			if val == nil {
+				return nil, notFound(f.cl.Fn.Locals[i])
+			}
+			vm.push(val)
+		case code.OpSetCell:
+			vm.stack[f.bp+vm.operand16(f)].(*object.Cell).Value = vm.pop()
+		case code.OpAssignCell:
+			i, operator := vm.operand16(f), vm.operand8(f)
+			if err := vm.assign(&vm.stack[f.bp+i].(*object.Cell).Value, f.cl.Fn.Locals[i], operator); err != nil {
+				return nil, err
+			}
+		case code.OpGetFree:
+			i := vm.operand8(f)
+			val := f.cl.Free[i].Value
+			if val == nil {
+				return nil, notFound(f.cl.Fn.Free[i])
+			}
+			vm.push(val)
+		case code.OpAssignFree:
+			i, operator := vm.operand8(f), vm.operand8(f)
+			if err := vm.assign(&f.cl.Free[i].Value, f.cl.Fn.Free[i], operator); err != nil {
+				return nil, err
+			}
+		case code.OpLoadCell:
+			vm.push(vm.stack[f.bp+vm.operand16(f)])
+		case code.OpLoadFree:
+			vm.push(f.cl.Free[vm.operand8(f)])
+
+		case code.OpArray:
+			n := vm.operand16(f)
+			if err := vm.eval.CheckLength(object.ARRAY_OBJ, n); err != nil {
+				return nil, err
+			}
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			vm.push(&object.Array{Elements: elements})
+		case code.OpHash:
+			n := vm.operand16(f)
+			hash := object.NewHash()
+			for i := vm.sp - n; i < vm.sp; i += 2 {
+				key, ok := vm.stack[i].(object.Hashable)
+				if !ok {
+					return nil, newError("unusable as hash key: %s", vm.stack[i].Type())
+				}
+				hash.Set(key, vm.stack[i+1])
+			}
+			vm.sp -= n
+			if err := vm.eval.CheckSize(hash); err != nil {
+				return nil, err
+			}
+			vm.push(hash)
+		case code.OpIndex:
+			index, left := vm.pop(), vm.pop()
+			result := evaluator.Index(left, index)
+			if err, ok := result.(*object.Error); ok {
+				return nil, err
+			}
+			vm.push(result)
+		case code.OpSetIndex:
+			operator := vm.operand8(f)
+			index, left, val := vm.pop(), vm.pop(), vm.pop()
+			if operator != 0 {
+				current := evaluator.Index(left, index)
+				if err, ok := current.(*object.Error); ok {
+					return nil, err
+				}
+				var err *object.Error
+				if val, err = vm.binary(code.Opcode(operator), current, val); err != nil {
+					return nil, err
+				}
+			}
+			if err := evaluator.SetIndex(left, index, val); err != nil {
+				return nil, err
+			}
+			// a hash grows when a new key is assigned
+			if err := vm.eval.CheckSize(left); err != nil {
+				return nil, err
+			}
+
+		case code.OpCall:
+			if err := vm.call(vm.operand8(f)); err != nil {
+				return nil, err
+			}
+		case code.OpTailCall:
+			// the callee and its arguments take the place of the current function on the stack, then its frame goes,
+			// so tail recursion neither grows the frames nor counts against MaxCallDepth
+			// the compiler only emits it in functions, never in the program or a try block
+			n := vm.operand8(f)
+			vm.leaveFrame()
+			copy(vm.stack[f.bp-1:], vm.stack[vm.sp-1-n:vm.sp])
+			vm.sp = f.bp + n
+			if err := vm.call(n); err != nil {
+				// it failed at the call, which is still in the function making it
+				vm.frames = append(vm.frames, f)
+				return nil, err
+			}
+		case code.OpReturnValue:
+			result := vm.pop()
+			if len(vm.frames) == 1 {
+				return result, nil
+			}
+			vm.leaveFrame()
+			vm.sp = f.bp - 1
+			vm.push(result)
+		case code.OpClosure:
+			fn, n := vm.constants[vm.operand16(f)].(*object.CompiledFunction), vm.operand8(f)
+			free := make([]*object.Cell, n)
+			for i := range free {
+				free[i] = vm.stack[vm.sp-n+i].(*object.Cell)
+			}
+			vm.sp -= n
+			vm.push(&object.Closure{Fn: fn, Free: free})
+
+		case code.OpIter:
+			items, err := evaluator.Items(vm.pop())
+			if err != nil {
+				return nil, err
+			}
+			vm.push(&iterator{items: items})
+		case code.OpIterNext:
+			target := vm.operand16(f)
+			it := vm.stack[vm.sp-1].(*iterator)
+			if it.next == len(it.items) {
+				f.ip = target
+				continue
+			}
+			vm.push(it.items[it.next])
+			it.next++
+
+		case code.OpThrow:
+			return nil, evaluator.Thrown(vm.pop())
+		case code.OpTry:
+			vm.handlers = append(vm.handlers, handler{frame: len(vm.frames) - 1, sp: vm.sp, ip: vm.operand16(f)})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		default:
+			return nil, newError("unknown opcode %d", op)
+		}
+	}
+}
+
+// calls the function below the n arguments on top of the stack
+func (vm *VM) call(n int) *object.Error {
+	switch callee := vm.stack[vm.sp-1-n].(type) {
+	case *object.Closure:
+		fn := callee.Fn
+		if n != fn.NumParameters {
+			return newError("wrong number of arguments: want %d, got %d", fn.NumParameters, n)
+		}
+		if max := vm.eval.Limits.MaxCallDepth; max > 0 && len(vm.frames) > max {
+			return limitError("calls nested more than %d deep", max)
+		}
+
+		bp := vm.sp - n
+		vm.grow(bp + fn.NumLocals)
+		// unset locals are nil, whatever an earlier call left in these slots must not show through
+		clear(vm.stack[vm.sp : bp+fn.NumLocals])
+		vm.sp = bp + fn.NumLocals
+		vm.frames = append(vm.frames, &Frame{cl: callee, bp: bp})
+		return nil
+	case *object.Builtin:
+		args := make([]object.Object, n)
+		copy(args, vm.stack[vm.sp-n:vm.sp])
+		vm.sp -= n + 1
+		result := vm.eval.Apply(callee, args)
+		if err, ok := result.(*object.Error); ok {
+			return err
+		}
+		if err := vm.eval.CheckSize(result); err != nil {
+			return err
+		}
+		vm.push(result)
+		return nil
+	default:
+		return newError("not a function: %s", callee.Type())
+	}
+}
+
+// drops the current frame with the try blocks still open in it
+func (vm *VM) leaveFrame() {
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].frame == len(vm.frames)-1 {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+	vm.frames = vm.frames[:len(vm.frames)-1]
+}
+
+// assigns the value on top of the stack to the binding in slot, applying operator to its current value first
+func (vm *VM) assign(slot *object.Object, name string, operator int) *object.Error {
+	val := vm.pop()
+	if *slot == nil {
+		return newError("cannot assign to undeclared identifier: %s", name)
+	}
+	if operator != 0 {
+		var err *object.Error
+		if val, err = vm.binary(code.Opcode(operator), *slot, val); err != nil {
+			return err
+		}
+	}
+	*slot = val
+	return nil
+}
+
+// fail hands err to the innermost try block around the instruction that failed, unwinding the calls in between
+// without one (or for a limit) every call is unwound and false is returned, the run is over
+func (vm *VM) fail(err *object.Error) bool {
+	if err.Pos.Line == 0 {
+		err.Pos = vm.frames[len(vm.frames)-1].position()
+	}
+
+	target := 0
+	caught := len(vm.handlers) > 0 && !err.Limit
+	if caught {
+		target = vm.handlers[len(vm.handlers)-1].frame
+	}
+	for len(vm.frames)-1 > target {
+		callee := vm.frames[len(vm.frames)-1]
+		vm.frames = vm.frames[:len(vm.frames)-1]
+		caller := vm.frames[len(vm.frames)-1]
+		err.Stack = append(err.Stack, object.Frame{Function: callee.cl.Fn.Name, Pos: caller.position()})
+	}
+	if !caught {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.sp = h.sp
+	vm.push(&object.ErrorValue{Err: err})
+	vm.frames[len(vm.frames)-1].ip = h.ip
+	return true
+}
+
+// counts one instruction, returns an error once the run is over its budget or its context is done
+func (vm *VM) step() *object.Error {
+	vm.steps++
+	if max := vm.eval.Limits.MaxSteps; max > 0 && vm.steps > max {
+		return limitError("more than %d steps", max)
+	}
+	if vm.ctx != nil && vm.steps%contextCheckInterval == 0 {
+		if err := vm.ctx.Err(); err != nil {
+			return limitError("%v", err)
+		}
+	}
+	return nil
+}
+
+func (vm *VM) operand16(f *Frame) int {
+	operand := int(code.ReadUint16(f.cl.Fn.Instructions[f.ip:]))
+	f.ip += 2
+	return operand
+}
+
+func (vm *VM) operand8(f *Frame) int {
+	operand := int(f.cl.Fn.Instructions[f.ip])
+	f.ip++
+	return operand
+}
+
+func (vm *VM) push(obj object.Object) {
+	vm.grow(vm.sp + 1)
+	vm.stack[vm.sp] = obj
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// makes room for size slots
+func (vm *VM) grow(size int) {
+	if size > len(vm.stack) {
+		stack := make([]object.Object, 2*size)
+		copy(stack, vm.stack[:vm.sp])
+		vm.stack = stack
+	}
+}
+
+func notFound(name string) *object.Error {
+	return newError("identifier not found: %s", name)
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func limitError(format string, a ...interface{}) *object.Error {
+	err := newError("limit exceeded: "+format, a...)
+	err.Limit = true
+	return err
+}
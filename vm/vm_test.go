@@ -0,0 +1,262 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+	"time"
+)
+
+// runs input on the vm with eval's builtins and limits
+func testRun(t *testing.T, ctx context.Context, eval *evaluator.Evaluator, input string) object.Object {
+	t.Helper()
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	for _, err := range program.Errors {
+		if err != nil {
+			t.Fatalf("input %q: parser error %v", input, err)
+		}
+	}
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("input %q: %v", input, err)
+	}
+	return New(comp.Bytecode(), eval).Run(ctx)
+}
+
+// runs input on both engines and fails unless they agree on the result and on what was printed
+func testEngines(t *testing.T, input string) string {
+	t.Helper()
+	var evalOut, vmOut bytes.Buffer
+
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+	evaluated := show(evaluator.New(&evalOut).Eval(program, object.NewEnvironment()))
+	run := show(testRun(t, context.Background(), evaluator.New(&vmOut), input))
+
+	if evaluated != run {
+		t.Errorf("input %q: engines disagree\nevaluator %s\nvm        %s", input, evaluated, run)
+	}
+	if evalOut.String() != vmOut.String() {
+		t.Errorf("input %q: engines printed differently\nevaluator %q\nvm        %q", input, evalOut.String(), vmOut.String())
+	}
+	return run
+}
+
+// errors with their traceback, a program ending in a statement without a value is null on both engines
+func show(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "null"
+	case *object.Error:
+		return obj.Traceback()
+	}
+	return obj.Inspect()
+}
+
+func TestEngines(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// operators
+		{"5 + 5 * 2 - 10 / 2", "10"},
+		{"-7 % 3; 2 ** 10", "1024"},
+		{"(6 & 3) | (1 << 4) ^ ~0 >> 1", "-17"},
+		{"1 < 2 == yes", "yes"},
+		{"!(3 >= 4) != no", "yes"},
+		{`"mon" + "key" == "monkey"`, "yes"},
+		{"[1, 2] == [1, 2]", "no"},
+		{"when (1 > 2) { 10 }", "null"},
+		{"when (0) { 1 } otherwise { 2 }", "1"},
+
+		// bindings
+		{"let a = 5; let b = a * 2; a + b", "15"},
+		{"let a = 1; a += 2; a *= 10; a", "30"},
+		{"const c = 1; let d = when (c == 1) { let e = 2; e + c }; d + e", "5"},
+		{"let x = 1; let f = fn() { let x = x + 1; x }; [f(), x]", "[2, 1]"},
+		{"let arr = [1, 2, 3]; arr[1] = 5; arr[2] += 10; arr", "[1, 5, 13]"},
+		{`let h = {"a": 1}; h["a"] -= 2; h["b"] = yes; h`, `{"a": -1, "b": yes}`},
+		{`{"a": [1, {2: "two"}]}["a"][1][2]`, `"two"`},
+		// collections holding themselves print the inner occurrence as [...] or {...}
+		{`let a = [1, 2]; a[0] = a; let h = {"a": a}; h["h"] = h; print(a, h); [a, [a, a], h]`,
+			`[[[...], 2], [[[...], 2], [[...], 2]], {"a": [[...], 2], "h": {...}}]`},
+		{"[1, 2][5]", "null"},
+
+		// functions and closures
+		{"let add = fn(a, b) { a + b }; add(2, add(3, 4))", "9"},
+		{"let f = fn() { send 1; 2 }; f()", "1"},
+		{"let fib = fn(n) { when (n < 2) { send n }; fib(n - 1) + fib(n - 2) }; fib(15)", "610"},
+		{"let adder = fn(x) { fn(y) { x + y } }; adder(2)(3)", "5"},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()", "3"},
+		{"let f = fn() { let x = 1; let g = fn() { x }; x = 2; g() }; f()", "2"},
+		{"let f = fn() { let g = fn() { h() }; let h = fn() { 7 }; g() }; f()", "7"},
+		{"let f = fn(a) { fn() { fn() { a = a * 2; a } } }; let g = f(4)(); g(); g()", "16"},
+		{"let f = fn() { let even = fn(n) { when (n == 0) { yes } otherwise { odd(n - 1) } }; let odd = fn(n) { when (n == 0) { no } otherwise { even(n - 1) } }; even(10) }; f()", "yes"},
+		{"let f = fn(a, a) { a }; f(1, 2)", "2"},
+		{"fn() { 1; }()", "1"},
+		{"fn() { let a = 1 }()", "null"},
+		// bodies and blocks without a value are null, not nothing
+		{"print(fn() {}()); print(when (yes) {}); print(try {} catch (e) { 1 })", "null"},
+		{"[type(fn() { let a = 1; a = 2 }()), type(fn() { while (no) {} }())]", `["null", "null"]`},
+		{"fn() { let x = 5 }() == 1", "runtime error at 1:22: type mismatch: NULL == INTEGER"},
+		{"len(fn() {}())", "runtime error at 1:4: argument 1 to len must be STRING, ARRAY or HASH, got NULL"},
+		{"assert(fn() { for (x in []) {} }())", "runtime error at 1:7: assertion failed"},
+		{"let f = fn(n) { when (n == 0) { 0 } otherwise { 1 + f(n - 1) } }; f(5000)", "5000"},
+		{"let loop = fn(n) { when (n == 0) { send 0 } otherwise { send loop(n - 1) } }; loop(1000000)", "0"},
+		{"let count = fn(n, acc) { when (n == 0) { acc } otherwise { count(n - 1, acc + 1) } }; count(100000, 0)", "100000"},
+		{"let f = fn(n) { for (x in [1, 2]) { when (n > 0) { send f(n - 1) } }; n }; f(100000)", "0"},
+		{"let g = fn() { throw 1 }; let f = fn() { try { send g() } catch { 2 } }; f()", "2"},
+		{"let f = fn() { send len([1]) }; f() + 1", "2"},
+		{"send 5; 6", "5"},
+		// a send, break or continue in a when or try used as a value leaves what it is part of
+		{"let g = fn() { let y = when (yes) { send 5 } otherwise { 1 }; send 99 }; g()", "5"},
+		{"let i = 0; while (i < 5) { i += 1; let z = when (i == 2) { break } otherwise { 0 } }; i", "2"},
+		{"let i = 0; let n = 0; while (i < 5) { i += 1; n += when (i % 2 == 0) { continue } otherwise { 1 } }; n", "3"},
+		{"fn() { let r = try { send 1 } catch (e) { 2 }; send 3 }()", "1"},
+		{"fn() { print(when (yes) { send 5 }); send 7 }()", "5"},
+		{"fn() { [1, when (yes) { send 2 }] + 3 }()", "2"},
+
+		// builtins
+		{`len("héllo") + len([1, 2]) + len({1: 2})`, "8"},
+		{"push(rest([1, 2, 3]), first([4]))", "[2, 3, 4]"},
+		{`[type(1), type(fn() {}), type(len), str(12) + "!", int("42")]`, `["int", "fn", "fn", "12!", 42]`},
+		{`print("a", 1, [yes]); puts("b")`, "null"},
+		{"let len = fn(x) { 0 }; len([1])", "0"},
+		{`assert(yes); assert_eq([1, {"a": 2}], [1, {"a": 2}]); assert_eq(2, 3, "two")`,
+			"runtime error at 1:64: assert_eq failed: two: have 2, want 3"},
+		{`let n = 0; let e = assert_error(fn() { n += 1; [1][n] + 1 }); [n, e["message"]]`, `[1, "type mismatch: NULL + INTEGER"]`},
+		{`let f = fn() { assert_error(fn() { 1 }) }; f()`,
+			"runtime error at 1:28: assert_error failed: no error, the function gave 1\n  at 1:28 in f\n  at 1:45 in <program>"},
+		{`assert_error(len)["message"]`, `"wrong number of arguments to len: want 1, got 0"`},
+
+		// loops
+		{"let i = 0; let sum = 0; while (i < 10) { i += 1; when (i % 2 == 0) { continue }; sum += i }; sum", "25"},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { when (x == 3) { break }; sum += x }; sum", "3"},
+		{`let s = ""; for (c in "abc") { s = c + s }; s`, `"cba"`},
+		{`let keys = []; for (k in {"x": 1, "y": 2}) { keys = push(keys, k) }; keys`, `["x", "y"]`},
+		{"let fs = []; for (i in [1, 2, 3]) { fs = push(fs, fn() { i * 10 }) }; fs[0]() + fs[2]()", "40"},
+		{"let fs = []; let i = 0; while (i < 3) { let j = i; fs = push(fs, fn() { j }); i += 1 }; fs[1]()", "1"},
+		{"let arr = [1, 2]; let n = 0; for (x in arr) { arr[0] = 9; n += x }; [n, arr]", "[3, [9, 2]]"},
+		{"let f = fn() { for (x in [1, 2, 3]) { when (x == 2) { send x * 100 } }; 0 }; f()", "200"},
+		{"for (x in [1]) { let y = x }; y", "runtime error at 1:31: identifier not found: y"},
+
+		// errors
+		{"5 + yes", "runtime error at 1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"-[1]", "runtime error at 1:1: unknown operator: -ARRAY"},
+		{"foobar", "runtime error at 1:1: identifier not found: foobar"},
+		{"let x = 1; x = x / 0", "runtime error at 1:18: division by zero: 1 / 0"},
+		{"y = 3", "runtime error at 1:3: cannot assign to undeclared identifier: y"},
+		{"{[1]: 2}", "runtime error at 1:1: unusable as hash key: ARRAY"},
+		{"let a = [1]; a[3] = 2", "runtime error at 1:19: index out of range: 3, length 1"},
+		{"let f = fn(a) { a }; f()", "runtime error at 1:23: wrong number of arguments: want 1, got 0"},
+		{"5()", "runtime error at 1:2: not a function: INTEGER"},
+		{"len(1)", "runtime error at 1:4: argument 1 to len must be STRING, ARRAY or HASH, got INTEGER"},
+		{"for (x in 5) { x }", "runtime error at 1:1: cannot iterate over INTEGER"},
+		{"let f = fn() { g() + 1 }; let g = fn() { 1 + no }; f()",
+			"runtime error at 1:44: type mismatch: INTEGER + BOOLEAN\n  at 1:44 in g\n  at 1:17 in f\n  at 1:53 in <program>"},
+		{"let f = fn() { g() }; let g = fn() { 1 + no }; f()",
+			"runtime error at 1:40: type mismatch: INTEGER + BOOLEAN\n  at 1:40 in g\n  at 1:49 in <program>"},
+		{"let f = fn() { send len(1) }; f()",
+			"runtime error at 1:24: argument 1 to len must be STRING, ARRAY or HASH, got INTEGER\n  at 1:24 in f\n  at 1:32 in <program>"},
+
+		// throw and try
+		{`try { throw "boom" } catch (e) { e["message"] }`, `"boom"`},
+		{`try { 1 } catch { 2 }`, "1"},
+		{`let f = fn() { throw {"code": 7} }; try { f(); 1 } catch (e) { e["value"]["code"] + 1 }`, "8"},
+		{`try { 1 + "a" } catch (e) { [e["message"], e["position"]] }`, `["type mismatch: INTEGER + STRING", "1:9"]`},
+		{`try { try { throw 1 } catch (e) { throw e } } catch (e) { e["value"] }`, "1"},
+		{`let n = 0; while (n < 5) { try { n += 1; when (n < 3) { continue }; break } catch { 0 } }; n`, "3"},
+		{`let f = fn() { try { send 1 } catch { 2 } }; f(); try { throw 5 } catch (e) { e["value"] }`, "5"},
+		{`let f = fn(n) { when (n == 0) { throw "deep" }; f(n - 1) }; try { f(3) } catch (e) { e["message"] }`, `"deep"`},
+		{`throw "up"`, "runtime error at 1:1: up"},
+		{`let x = try { 1 + [] } catch { "caught" }; x`, `"caught"`},
+	}
+
+	for _, tt := range tests {
+		if run := testEngines(t, tt.input); run != tt.expected {
+			t.Errorf("input %q: want %s, have %s", tt.input, tt.expected, run)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		limits   evaluator.Limits
+		input    string
+		expected string
+	}{
+		{evaluator.Limits{MaxSteps: 100}, "while (yes) {}", "limit exceeded: more than 100 steps"},
+		{evaluator.Limits{MaxCallDepth: 50}, "let f = fn() { 1 + f() }; f()", "limit exceeded: calls nested more than 50 deep"},
+		{evaluator.Limits{MaxSteps: 100}, "let f = fn() { f() }; f()", "limit exceeded: more than 100 steps"},
+		{evaluator.Limits{MaxCollectionSize: 3}, "[1, 2, 3, 4]", "limit exceeded: ARRAY of size 4, the maximum is 3"},
+		{evaluator.Limits{MaxCollectionSize: 3}, "push([1, 2, 3], 4)", "limit exceeded: ARRAY of size 4, the maximum is 3"},
+		{evaluator.Limits{MaxCollectionSize: 3}, `let s = "ab"; s + s`, "limit exceeded: STRING of size 4, the maximum is 3"},
+		{evaluator.Limits{MaxCollectionSize: 1}, `let h = {}; h[1] = 1; h[2] = 2`, "limit exceeded: HASH of size 2, the maximum is 1"},
+		{evaluator.Limits{MaxSteps: 100}, "try { while (yes) {} } catch { 1 }", "limit exceeded: more than 100 steps"},
+	}
+
+	for _, tt := range tests {
+		eval := evaluator.New(&bytes.Buffer{})
+		eval.Limits = tt.limits
+		err, ok := testRun(t, context.Background(), eval, tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("input %q: no error", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("input %q: want %q, have %q", tt.input, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result := testRun(t, ctx, evaluator.New(&bytes.Buffer{}), "while (yes) {}")
+	err, ok := result.(*object.Error)
+	if !ok || !err.Limit {
+		t.Fatalf("want a limit error, have %v", result)
+	}
+	if err.Message != "limit exceeded: context deadline exceeded" {
+		t.Errorf("wrong message: %q", err.Message)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	eval := evaluator.New(&bytes.Buffer{})
+	eval.Capabilities = object.NoCapabilities
+	result := testRun(t, context.Background(), eval, `print("hi")`)
+	err, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("want an error, have %v", result)
+	}
+	if err.Message != "print needs the print capability, which is not granted" {
+		t.Errorf("wrong message: %q", err.Message)
+	}
+}
+
+func BenchmarkEngines(b *testing.B) {
+	input := "let fib = fn(n) { when (n < 2) { send n }; fib(n - 1) + fib(n - 2) }; fib(20)"
+	program := parser.New(lexer.NewLexer(input)).ParseProgram()
+
+	b.Run("evaluator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			evaluator.New(&bytes.Buffer{}).Eval(program, object.NewEnvironment())
+		}
+	})
+	b.Run("vm", func(b *testing.B) {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			b.Fatal(err)
+		}
+		bytecode := comp.Bytecode()
+		for i := 0; i < b.N; i++ {
+			New(bytecode, evaluator.New(&bytes.Buffer{})).Run(context.Background())
+		}
+	})
+}